@@ -1,35 +1,206 @@
 package main
 
 import (
-	"log"
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
 
 	"fintech-backend/internal/config"
 	"fintech-backend/internal/db"
+	"fintech-backend/internal/health"
+	"fintech-backend/internal/logging"
+	"fintech-backend/internal/metrics"
+	"fintech-backend/internal/migrate"
+	"fintech-backend/internal/provider"
+	"fintech-backend/internal/ratelimit"
+	"fintech-backend/internal/repository"
 	"fintech-backend/internal/router"
+	"fintech-backend/internal/service"
+	"fintech-backend/internal/tracing"
+	"fintech-backend/internal/webhook"
+	"fintech-backend/migrations"
 )
 
 func main() {
+	// JSON until the config says otherwise, so even startup failures are
+	// structured.
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, nil)))
+
 	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found, reading from environment")
+		slog.Info("no .env file found, reading from environment")
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:]); err != nil {
+			fatal("migrate", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "seed" {
+		if err := runSeed(os.Args[2:]); err != nil {
+			fatal("seed", err)
+		}
+		return
 	}
 
 	cfg, err := config.Load()
 	if err != nil {
-		log.Fatalf("config error: %v", err)
+		fatal("config error", err)
+	}
+	slog.SetDefault(logging.New(os.Stderr, cfg.Log))
+	slog.Info("config loaded", "env", cfg.Env, "config", cfg.String())
+	if cfg.Auth.Mode == config.AuthJWKS {
+		slog.Warn("AUTH_MODE=jwks is not enforced yet; /api still requires API_KEY", "jwks_url", cfg.Auth.JWKSURL)
 	}
 
-	pool, err := db.NewPool(cfg.DatabaseURL)
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
 	if err != nil {
-		log.Fatalf("db error: %v", err)
+		fatal("tracing error", err)
+	}
+
+	pool, err := db.NewPool(cfg.DB)
+	if err != nil {
+		fatal("db error", err)
 	}
 	defer pool.Close()
 
-	app := router.New(cfg, pool)
+	metrics.RegisterPool(pool)
+
+	runner, err := migrate.New(pool, migrations.FS)
+	if err != nil {
+		fatal("migrations error", err)
+	}
+
+	payouts, err := provider.New(cfg.Provider.Name, cfg.Provider.MockDelay)
+	if err != nil {
+		fatal("provider error", err)
+	}
+
+	var coach service.CoachEngine = service.RulesEngine{}
+	if cfg.Coach.Engine == "llm" {
+		coach = service.NewLLMEngine(cfg.Coach.LLMURL, cfg.Coach.LLMAPIKey, cfg.Coach.LLMModel, cfg.Coach.LLMTimeout)
+	}
+
+	hooks := webhook.NewSender(cfg.Webhooks.Timeout, cfg.Env == config.EnvDev)
+
+	repo := repository.New(pool, cfg.DB.QueryTimeout, cfg.DB.TxTimeout)
+	svc := service.New(repo, coach, payouts, hooks)
+
+	// Background workers stop when workerCtx is cancelled; wg tracks them so
+	// the pool is only closed once they have returned.
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	workers := []func(context.Context){
+		func(ctx context.Context) { purgeIdempotencyKeys(ctx, repo, time.Hour) },
+	}
+	var limits ratelimit.Store = ratelimit.NewMemoryStore()
+	if cfg.RateLimit.Store == "postgres" {
+		shared := ratelimit.NewPostgresStore(pool, cfg.DB.QueryTimeout)
+		limits = shared
+		workers = append(workers, func(ctx context.Context) { purgeRateLimitBuckets(ctx, shared, time.Minute) })
+	}
+	if cfg.Features.Payouts {
+		workers = append(workers, func(ctx context.Context) { svc.RunPayoutReconciler(ctx, cfg.Provider.ReconcileInterval) })
+	}
+	if cfg.Features.Webhooks {
+		workers = append(workers, func(ctx context.Context) { svc.RunWebhookDispatcher(ctx, cfg.Webhooks.DispatchInterval) })
+	}
+	for _, run := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			run(workerCtx)
+		}()
+	}
+
+	// Requests derive from reqCtx; it is cancelled only after the grace
+	// period, to abort whatever is still running.
+	reqCtx, cancelRequests := context.WithCancel(context.Background())
+	app := router.New(reqCtx, cfg, svc, repo, limits, health.New(pool, runner))
+
+	// Catch signals before listening, so one sent during startup still
+	// drains instead of killing the process.
+	sig, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	listenErr := make(chan error, 1)
+	go func() {
+		slog.Info("server starting", "port", cfg.Port, "payout_provider", payouts.Name())
+		listenErr <- app.Listen(":" + cfg.Port)
+	}()
+
+	// A listen error (port in use, say) still stops the workers cleanly, but
+	// the process exits non-zero so supervisors see the failure.
+	var serveErr error
+	select {
+	case serveErr = <-listenErr:
+		slog.Error("server error", "err", serveErr)
+	case <-sig.Done():
+		slog.Info("shutting down, draining requests", "grace", cfg.HTTP.ShutdownGrace)
+		if err := app.ShutdownWithTimeout(cfg.HTTP.ShutdownGrace); err != nil {
+			slog.Error("shutdown", "err", err)
+		}
+	}
+
+	cancelRequests()
+	stopWorkers()
+	wg.Wait()
+
+	// Spans from the drained requests are still buffered.
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelFlush()
+	if err := shutdownTracing(flushCtx); err != nil {
+		slog.Error("tracing: flush", "err", err)
+	}
+	if serveErr != nil {
+		pool.Close()
+		fatal("server stopped", serveErr)
+	}
+	slog.Info("workers stopped, closing database pool")
+}
+
+// fatal logs err and exits; deferred calls do not run.
+func fatal(msg string, err error) {
+	slog.Error(msg, "err", err)
+	os.Exit(1)
+}
+
+// purgeIdempotencyKeys deletes expired Idempotency-Key records every interval.
+func purgeIdempotencyKeys(ctx context.Context, repo *repository.Repository, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := repo.DeleteExpiredIdempotencyKeys(ctx); err != nil && ctx.Err() == nil {
+				slog.Error("idempotency: purge", "err", err)
+			}
+		}
+	}
+}
+
+// purgeRateLimitBuckets deletes refilled rate-limit buckets every interval.
+func purgeRateLimitBuckets(ctx context.Context, limits *ratelimit.PostgresStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
 
-	log.Printf("Server starting on :%s ...", cfg.Port)
-	if err := app.Listen(":" + cfg.Port); err != nil {
-		log.Fatalf("server error: %v", err)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := limits.DeleteFull(ctx); err != nil && ctx.Err() == nil {
+				slog.Error("ratelimit: purge", "err", err)
+			}
+		}
 	}
 }
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"fintech-backend/internal/config"
+	"fintech-backend/internal/db"
+	"fintech-backend/internal/migrate"
+	"fintech-backend/migrations"
+)
+
+const migrateUsage = `usage: vantro migrate <command>
+
+  up [version]   apply pending migrations, up to version if given
+  down [steps]   revert the newest steps migrations (default 1)
+  status         list migrations and when each was applied
+  version        print the newest applied version`
+
+// runMigrate implements the migrate subcommand. It needs only DATABASE_URL.
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+	switch args[0] {
+	case "up", "down", "status", "version":
+	default:
+		return errors.New(migrateUsage)
+	}
+	dbCfg := config.Default().DB
+	dbCfg.URL = os.Getenv("DATABASE_URL")
+	if dbCfg.URL == "" {
+		return errors.New("missing DATABASE_URL in env")
+	}
+
+	pool, err := db.NewPool(dbCfg)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	runner, err := migrate.New(pool, migrations.FS)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	switch cmd, n := args[0], 0; cmd {
+	case "up", "down":
+		if len(args) > 1 {
+			if n, err = strconv.Atoi(args[1]); err != nil || n < 0 {
+				return fmt.Errorf("migrate %s: invalid number %q", cmd, args[1])
+			}
+		}
+		if cmd == "up" {
+			applied, err := runner.Up(ctx, n)
+			for _, m := range applied {
+				fmt.Printf("applied  %04d_%s\n", m.Version, m.Name)
+			}
+			if err == nil && len(applied) == 0 {
+				fmt.Println("no pending migrations")
+			}
+			return err
+		}
+		if n == 0 {
+			n = 1
+		}
+		reverted, err := runner.Down(ctx, n)
+		for _, m := range reverted {
+			fmt.Printf("reverted %04d_%s\n", m.Version, m.Name)
+		}
+		return err
+	case "status":
+		statuses, err := runner.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.Local().Format(time.RFC3339)
+			}
+			fmt.Printf("%04d_%-24s %s\n", s.Version, s.Name, applied)
+		}
+		return nil
+	case "version":
+		v, err := runner.Version(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%d (latest %d)\n", v, runner.Latest())
+	}
+	return nil
+}
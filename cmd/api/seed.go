@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"fintech-backend/internal/auth"
+	"fintech-backend/internal/config"
+	"fintech-backend/internal/db"
+	"fintech-backend/internal/dto"
+	"fintech-backend/internal/repository"
+	"fintech-backend/internal/service"
+)
+
+const seedUsage = `usage: vantro seed
+
+Creates the demo user (demo@example.com) with a shop and two products, and
+prints how to get a token acting as it. It only runs with ENV=dev.`
+
+// runSeed implements the seed subcommand. It is safe to rerun: the user is
+// reused, and the shop and products are only created for a user without
+// shops.
+func runSeed(args []string) error {
+	if len(args) != 0 {
+		return errors.New(seedUsage)
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	if cfg.Env != config.EnvDev {
+		return fmt.Errorf("refusing to seed with ENV=%s; demo data is only for ENV=%s", cfg.Env, config.EnvDev)
+	}
+
+	pool, err := db.NewPool(cfg.DB)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	repo := repository.New(pool, cfg.DB.QueryTimeout, cfg.DB.TxTimeout)
+	svc := service.New(repo, nil, nil, nil)
+	ctx := context.Background()
+
+	user, err := repo.EnsureUser(ctx, auth.DemoEmail, "Demo")
+	if err != nil {
+		return err
+	}
+	shops, err := repo.ListShopsByUser(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	if len(shops) == 0 {
+		shop, err := svc.CreateShop(ctx, dto.CreateShopRequest{
+			Name:       "Bablu Enterprises",
+			Address:    "Janakpuri, New Delhi",
+			OwnerEmail: user.Email,
+		})
+		if err != nil {
+			return err
+		}
+		products := []dto.CreateProductRequest{
+			{Name: "Jack F4 Sewing Machine", SKU: "JACK-F4", Stock: 5, CostPrice: 18000, SellingPrice: 22000, LowStockThreshold: 2, HSNCode: "8452", GSTRate: 18},
+			{Name: "Bobbin Case", SKU: "BOB-CASE", Stock: 40, CostPrice: 120, SellingPrice: 180, LowStockThreshold: 10, HSNCode: "8452", GSTRate: 18},
+		}
+		for _, p := range products {
+			p.ShopID = shop.ID.String()
+			if _, err := svc.CreateProduct(ctx, p); err != nil {
+				return err
+			}
+		}
+		fmt.Printf("created shop %s with %d products\n", shop.ID, len(products))
+	}
+	// The user's own api_key column is not a credential: /api accepts only
+	// API_KEY and, in dev, tokens from /dev/token, which act as this user.
+	fmt.Printf("demo user: %s\nget a token: curl -s -X POST http://localhost:%s/dev/token\n"+
+		"then send it on /api as \"Authorization: Bearer <token>\"\n", user.Email, cfg.Port)
+	return nil
+}
@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDemoTokens(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	d := NewDemoTokens(15 * time.Minute)
+	d.now = func() time.Time { return now }
+
+	token, exp := d.Issue(DemoEmail)
+	if !IsDemo(token) || !exp.Equal(now.Add(15*time.Minute)) {
+		t.Fatalf("Issue = %q, %s", token, exp)
+	}
+	if email, err := d.Verify(token); err != nil || email != DemoEmail {
+		t.Fatalf("Verify = %q, %v", email, err)
+	}
+
+	other := NewDemoTokens(15 * time.Minute)
+	other.now = d.now
+	forged, _ := other.Issue(DemoEmail)
+	body, _, _ := strings.Cut(token, ".")
+	_, forgedSig, _ := strings.Cut(forged, ".")
+
+	for name, bad := range map[string]string{
+		"other key":     forged,
+		"swapped sig":   body + "." + forgedSig,
+		"no signature":  body,
+		"not demo":      strings.TrimPrefix(token, demoPrefix),
+		"empty":         "",
+		"garbage":       "demo_abc.def",
+		"tampered body": demoPrefix + "eyJlbWFpbCI6ImFkbWluQGV4YW1wbGUuY29tIiwiZXhwIjo5OTk5OTk5OTk5fQ." + strings.SplitN(token, ".", 2)[1],
+	} {
+		if _, err := d.Verify(bad); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: err = %v, want ErrInvalidToken", name, err)
+		}
+	}
+
+	now = exp
+	if _, err := d.Verify(token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expired token: err = %v, want ErrInvalidToken", err)
+	}
+}
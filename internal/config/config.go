@@ -1,28 +1,477 @@
+// Package config loads the server configuration from defaults, an optional
+// YAML file (CONFIG_FILE) and environment variables, in increasing order of
+// precedence, and validates the result.
 package config
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
+	"io"
+	"net/url"
 	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Environments.
+const (
+	EnvDev     = "dev"
+	EnvStaging = "staging"
+	EnvProd    = "prod"
+)
+
+// Auth modes.
+const (
+	// AuthAPIKey authenticates callers with the server API key.
+	AuthAPIKey = "api_key"
+	// AuthJWKS authenticates /api callers with JWTs signed by a key in the
+	// JWKS at Auth.JWKSURL, such as a Supabase project's.
+	AuthJWKS = "jwks"
 )
 
+// Config is the server configuration. Each field is set from the environment
+// variable in its env tag and the YAML key in its yaml tag. Fields tagged
+// secret are redacted by String.
 type Config struct {
-	Port        string
-	DatabaseURL string
-	APIKey      string
+	// Env is dev, staging or prod.
+	Env  string `yaml:"env" env:"ENV"`
+	Port string `yaml:"port" env:"PORT"`
+
+	DB        DB        `yaml:"db"`
+	HTTP      HTTP      `yaml:"http"`
+	Auth      Auth      `yaml:"auth"`
+	RateLimit RateLimit `yaml:"rate_limit"`
+	Provider  Provider  `yaml:"provider"`
+	Webhooks  Webhooks  `yaml:"webhooks"`
+	Coach     Coach     `yaml:"coach"`
+	Features  Features  `yaml:"features"`
+	Metrics   Metrics   `yaml:"metrics"`
+	Log       Log       `yaml:"log"`
+	Tracing   Tracing   `yaml:"tracing"`
+}
+
+type DB struct {
+	URL      string `yaml:"url" env:"DATABASE_URL" secret:"true"`
+	MaxConns int32  `yaml:"max_conns" env:"DB_MAX_CONNS"`
+	MinConns int32  `yaml:"min_conns" env:"DB_MIN_CONNS"`
+
+	// Connections are closed after MaxConnLifetime, or MaxConnIdleTime
+	// unused; idle ones are checked every HealthCheckPeriod.
+	MaxConnLifetime   time.Duration `yaml:"max_conn_lifetime" env:"DB_MAX_CONN_LIFETIME"`
+	MaxConnIdleTime   time.Duration `yaml:"max_conn_idle_time" env:"DB_MAX_CONN_IDLE_TIME"`
+	HealthCheckPeriod time.Duration `yaml:"health_check_period" env:"DB_HEALTH_CHECK_PERIOD"`
+	// ConnectTimeout bounds the retries of the first ping at startup.
+	ConnectTimeout time.Duration `yaml:"connect_timeout" env:"DB_CONNECT_TIMEOUT"`
+
+	// QueryTimeout and TxTimeout cap single statements and transactions.
+	QueryTimeout time.Duration `yaml:"query_timeout" env:"DB_QUERY_TIMEOUT"`
+	TxTimeout    time.Duration `yaml:"tx_timeout" env:"DB_TX_TIMEOUT"`
+}
+
+type HTTP struct {
+	// RequestTimeout is the deadline of each HTTP request's context.
+	RequestTimeout time.Duration `yaml:"request_timeout" env:"REQUEST_TIMEOUT"`
+	// ShutdownGrace is how long in-flight requests may run after SIGTERM.
+	ShutdownGrace time.Duration `yaml:"shutdown_grace" env:"SHUTDOWN_GRACE"`
+	// IdempotencyTTL is how long Idempotency-Key responses are replayed.
+	IdempotencyTTL time.Duration `yaml:"idempotency_ttl" env:"IDEMPOTENCY_TTL"`
+	// CORSOrigins are the browser origins allowed to call the API, such as
+	// https://app.example.com, or https://*.example.com for any subdomain.
+	// "*" allows any origin and is only accepted in dev, where it is also
+	// the default; elsewhere no origin is allowed unless listed.
+	CORSOrigins []string `yaml:"cors_origins" env:"CORS_ORIGINS"`
+	// CORSAllowCredentials lets browsers send cookies and Authorization
+	// headers cross-origin. It needs an explicit origin list.
+	CORSAllowCredentials bool `yaml:"cors_allow_credentials" env:"CORS_ALLOW_CREDENTIALS"`
+	// CORSMaxAge is how long browsers may cache a preflight response.
+	CORSMaxAge time.Duration `yaml:"cors_max_age" env:"CORS_MAX_AGE"`
+}
+
+// Auth selects how /api callers authenticate. The server API key is always
+// required: /api takes it in X-API-Key and the /v1 payouts API as a bearer
+// token. In jwks mode JWKSURL is the key set tokens are checked against.
+type Auth struct {
+	Mode    string `yaml:"mode" env:"AUTH_MODE"`
+	APIKey  string `yaml:"api_key" env:"API_KEY" secret:"true"`
+	JWKSURL string `yaml:"jwks_url" env:"SUPABASE_JWKS_URL"`
+}
+
+// RateLimit sets each caller's token buckets: reads and writes (any method
+// other than GET, HEAD and OPTIONS) refill at their per-minute rate and
+// allow bursts of up to their burst size. The IP bucket is taken before
+// authentication, so it also limits requests with bad credentials. Store is
+// memory, for a single instance, or postgres, to share the buckets between
+// instances.
+type RateLimit struct {
+	Enabled         bool   `yaml:"enabled" env:"RATE_LIMIT_ENABLED"`
+	Store           string `yaml:"store" env:"RATE_LIMIT_STORE"`
+	ReadsPerMinute  int    `yaml:"reads_per_minute" env:"RATE_LIMIT_READS_PER_MINUTE"`
+	ReadBurst       int    `yaml:"read_burst" env:"RATE_LIMIT_READ_BURST"`
+	WritesPerMinute int    `yaml:"writes_per_minute" env:"RATE_LIMIT_WRITES_PER_MINUTE"`
+	WriteBurst      int    `yaml:"write_burst" env:"RATE_LIMIT_WRITE_BURST"`
+	IPPerMinute     int    `yaml:"ip_per_minute" env:"RATE_LIMIT_IP_PER_MINUTE"`
+	IPBurst         int    `yaml:"ip_burst" env:"RATE_LIMIT_IP_BURST"`
+}
+
+type Provider struct {
+	// Name selects the payout rail; only "mock" (sandbox) ships today.
+	Name              string        `yaml:"name" env:"PROVIDER"`
+	MockDelay         time.Duration `yaml:"mock_delay" env:"MOCK_PROVIDER_DELAY"`
+	ReconcileInterval time.Duration `yaml:"reconcile_interval" env:"PAYOUT_RECONCILE_INTERVAL"`
+}
+
+type Webhooks struct {
+	DispatchInterval time.Duration `yaml:"dispatch_interval" env:"WEBHOOK_DISPATCH_INTERVAL"`
+	Timeout          time.Duration `yaml:"timeout" env:"WEBHOOK_TIMEOUT"`
 }
 
+type Coach struct {
+	// Engine selects the coach implementation: "rules" or "llm".
+	Engine     string        `yaml:"engine" env:"COACH_ENGINE"`
+	LLMURL     string        `yaml:"llm_url" env:"COACH_LLM_URL"`
+	LLMAPIKey  string        `yaml:"llm_api_key" env:"COACH_LLM_API_KEY" secret:"true"`
+	LLMModel   string        `yaml:"llm_model" env:"COACH_LLM_MODEL"`
+	LLMTimeout time.Duration `yaml:"llm_timeout" env:"COACH_LLM_TIMEOUT"`
+}
+
+// Features switch whole subsystems off: their routes answer 404 and their
+// background workers do not start.
+type Features struct {
+	Payouts  bool `yaml:"payouts" env:"FEATURE_PAYOUTS"`
+	Webhooks bool `yaml:"webhooks" env:"FEATURE_WEBHOOKS"`
+	Coach    bool `yaml:"coach" env:"FEATURE_COACH"`
+	GST      bool `yaml:"gst" env:"FEATURE_GST"`
+}
+
+// Metrics controls the Prometheus endpoint at /metrics. When Token is set,
+// scrapers must send it as a bearer token.
+type Metrics struct {
+	Enabled bool   `yaml:"enabled" env:"METRICS_ENABLED"`
+	Token   string `yaml:"token" env:"METRICS_TOKEN" secret:"true"`
+}
+
+// Log selects the log level (debug, info, warn or error) and format: json
+// for one object per line, or text for logfmt-style lines.
+type Log struct {
+	Level  string `yaml:"level" env:"LOG_LEVEL"`
+	Format string `yaml:"format" env:"LOG_FORMAT"`
+}
+
+// Tracing selects where OpenTelemetry spans go: nowhere (none), stdout,
+// or an OTLP/HTTP collector at Endpoint (http://localhost:4318 when empty).
+// SampleRatio is the share of new traces recorded; requests arriving with a
+// traceparent follow the caller's decision.
+type Tracing struct {
+	Exporter    string  `yaml:"exporter" env:"TRACING_EXPORTER"`
+	Endpoint    string  `yaml:"endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT"`
+	ServiceName string  `yaml:"service_name" env:"OTEL_SERVICE_NAME"`
+	SampleRatio float64 `yaml:"sample_ratio" env:"TRACING_SAMPLE_RATIO"`
+}
+
+// Default is the configuration before any file or environment is applied.
+func Default() Config {
+	return Config{
+		Env:  EnvProd,
+		Port: "8080",
+		DB: DB{
+			MaxConns:          10,
+			MinConns:          0,
+			MaxConnLifetime:   time.Hour,
+			MaxConnIdleTime:   30 * time.Minute,
+			HealthCheckPeriod: time.Minute,
+			ConnectTimeout:    time.Minute,
+			QueryTimeout:      5 * time.Second,
+			TxTimeout:         10 * time.Second,
+		},
+		HTTP: HTTP{
+			RequestTimeout: 15 * time.Second,
+			ShutdownGrace:  20 * time.Second,
+			IdempotencyTTL: 24 * time.Hour,
+			CORSMaxAge:     10 * time.Minute,
+		},
+		Auth: Auth{
+			Mode: AuthAPIKey,
+		},
+		RateLimit: RateLimit{
+			Enabled:         true,
+			Store:           "memory",
+			ReadsPerMinute:  300,
+			ReadBurst:       100,
+			WritesPerMinute: 60,
+			WriteBurst:      20,
+			IPPerMinute:     600,
+			IPBurst:         200,
+		},
+		Provider: Provider{
+			Name:              "mock",
+			MockDelay:         3 * time.Second,
+			ReconcileInterval: 2 * time.Second,
+		},
+		Webhooks: Webhooks{
+			DispatchInterval: 2 * time.Second,
+			Timeout:          10 * time.Second,
+		},
+		Coach: Coach{
+			Engine:     "rules",
+			LLMURL:     "https://api.openai.com/v1",
+			LLMModel:   "gpt-4o-mini",
+			LLMTimeout: 8 * time.Second,
+		},
+		Features: Features{Payouts: true, Webhooks: true, Coach: true, GST: true},
+		Metrics:  Metrics{Enabled: true},
+		Log:      Log{Level: "info", Format: "json"},
+		Tracing:  Tracing{Exporter: "none", ServiceName: "vantro-api", SampleRatio: 1},
+	}
+}
+
+// Load builds the configuration from Default, the YAML file named by
+// CONFIG_FILE if set, and the environment, then validates it.
 func Load() (*Config, error) {
-	port := os.Getenv("PORT")
-	dbURL := os.Getenv("DATABASE_URL")
-	apiKey := os.Getenv("API_KEY")
+	cfg := Default()
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		if err := loadFile(&cfg, path); err != nil {
+			return nil, err
+		}
+	}
+	if err := loadEnv(reflect.ValueOf(&cfg).Elem()); err != nil {
+		return nil, err
+	}
+	if len(cfg.HTTP.CORSOrigins) == 0 && cfg.Env == EnvDev {
+		cfg.HTTP.CORSOrigins = []string{"*"}
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func loadFile(cfg *Config, path string) error {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(body))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// loadEnv sets every field with an env tag whose variable is non-empty.
+func loadEnv(v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f, fv := t.Field(i), v.Field(i)
+		if f.Type.Kind() == reflect.Struct {
+			if err := loadEnv(fv); err != nil {
+				return err
+			}
+			continue
+		}
+		key := f.Tag.Get("env")
+		raw := strings.TrimSpace(os.Getenv(key))
+		if key == "" || raw == "" {
+			continue
+		}
+		if err := setField(fv, raw); err != nil {
+			return fmt.Errorf("invalid %s: %v", key, err)
+		}
+	}
+	return nil
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func setField(fv reflect.Value, raw string) error {
+	switch {
+	case fv.Type() == durationType:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(int64(d))
+	case fv.Kind() == reflect.String:
+		fv.SetString(raw)
+	case fv.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return errors.New("must be true or false")
+		}
+		fv.SetBool(b)
+	case fv.Kind() == reflect.Int || fv.Kind() == reflect.Int32:
+		n, err := strconv.ParseInt(raw, 10, fv.Type().Bits())
+		if err != nil {
+			return errors.New("must be a whole number")
+		}
+		fv.SetInt(n)
+	case fv.Kind() == reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return errors.New("must be a number")
+		}
+		fv.SetFloat(f)
+	case fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() == reflect.String:
+		var items []string
+		for _, s := range strings.Split(raw, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				items = append(items, s)
+			}
+		}
+		fv.Set(reflect.ValueOf(items))
+	default:
+		panic("config: unsupported field type " + fv.Type().String())
+	}
+	return nil
+}
+
+// Validate reports every invalid setting, one per line, named by its
+// environment variable.
+func (c *Config) Validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(oneOf(c.Env, EnvDev, EnvStaging, EnvProd), "ENV must be dev, staging or prod, got %q", c.Env)
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		check(false, "PORT must be a port number, got %q", c.Port)
+	}
+
+	check(c.DB.URL != "", "DATABASE_URL is required")
+	check(c.DB.MaxConns >= 1, "DB_MAX_CONNS must be at least 1")
+	check(c.DB.MinConns >= 0 && c.DB.MinConns <= c.DB.MaxConns, "DB_MIN_CONNS must be between 0 and DB_MAX_CONNS (%d)", c.DB.MaxConns)
+	check(c.DB.MaxConnLifetime > 0, "DB_MAX_CONN_LIFETIME must be positive")
+	check(c.DB.MaxConnIdleTime > 0, "DB_MAX_CONN_IDLE_TIME must be positive")
+	check(c.DB.HealthCheckPeriod > 0, "DB_HEALTH_CHECK_PERIOD must be positive")
+	check(c.DB.ConnectTimeout > 0, "DB_CONNECT_TIMEOUT must be positive")
+	check(c.DB.QueryTimeout > 0, "DB_QUERY_TIMEOUT must be positive")
+	check(c.DB.TxTimeout > 0, "DB_TX_TIMEOUT must be positive")
+
+	check(c.HTTP.RequestTimeout > 0, "REQUEST_TIMEOUT must be positive")
+	check(c.HTTP.ShutdownGrace > 0, "SHUTDOWN_GRACE must be positive")
+	check(c.HTTP.IdempotencyTTL > 0, "IDEMPOTENCY_TTL must be positive")
+	for _, o := range c.HTTP.CORSOrigins {
+		if o == "*" {
+			check(c.Env == EnvDev, "CORS_ORIGINS may only be * in dev; list the origins allowed")
+			check(!c.HTTP.CORSAllowCredentials, "CORS_ORIGINS may not be * when CORS_ALLOW_CREDENTIALS is true")
+			continue
+		}
+		check(validOrigin(o), "CORS_ORIGINS entry %q must be an origin such as https://app.example.com or https://*.example.com", o)
+	}
+	check(c.HTTP.CORSMaxAge >= 0, "CORS_MAX_AGE must not be negative")
+
+	check(oneOf(c.Auth.Mode, AuthAPIKey, AuthJWKS), "AUTH_MODE must be %s or %s, got %q", AuthAPIKey, AuthJWKS, c.Auth.Mode)
+	check(c.Auth.APIKey != "", "API_KEY is required")
+	if c.Auth.Mode == AuthJWKS {
+		check(validURL(c.Auth.JWKSURL, false), "SUPABASE_JWKS_URL must be an https URL when AUTH_MODE is %s", AuthJWKS)
+	}
+
+	if c.RateLimit.Enabled {
+		check(oneOf(c.RateLimit.Store, "memory", "postgres"), "RATE_LIMIT_STORE must be memory or postgres, got %q", c.RateLimit.Store)
+		check(c.RateLimit.ReadsPerMinute >= 1, "RATE_LIMIT_READS_PER_MINUTE must be at least 1")
+		check(c.RateLimit.ReadBurst >= 1, "RATE_LIMIT_READ_BURST must be at least 1")
+		check(c.RateLimit.WritesPerMinute >= 1, "RATE_LIMIT_WRITES_PER_MINUTE must be at least 1")
+		check(c.RateLimit.WriteBurst >= 1, "RATE_LIMIT_WRITE_BURST must be at least 1")
+		check(c.RateLimit.IPPerMinute >= 1, "RATE_LIMIT_IP_PER_MINUTE must be at least 1")
+		check(c.RateLimit.IPBurst >= 1, "RATE_LIMIT_IP_BURST must be at least 1")
+	}
 
-	if port == "" || dbURL == "" || apiKey == "" {
-		return nil, errors.New("missing PORT, DATABASE_URL or API_KEY in env")
+	check(c.Provider.Name == "mock", "PROVIDER must be mock, got %q", c.Provider.Name)
+	check(c.Provider.MockDelay >= 0, "MOCK_PROVIDER_DELAY must not be negative")
+	check(c.Provider.ReconcileInterval > 0, "PAYOUT_RECONCILE_INTERVAL must be positive")
+
+	check(c.Webhooks.DispatchInterval > 0, "WEBHOOK_DISPATCH_INTERVAL must be positive")
+	check(c.Webhooks.Timeout > 0, "WEBHOOK_TIMEOUT must be positive")
+
+	check(oneOf(c.Coach.Engine, "rules", "llm"), "COACH_ENGINE must be rules or llm, got %q", c.Coach.Engine)
+	if c.Coach.Engine == "llm" {
+		check(validURL(c.Coach.LLMURL, true), "COACH_LLM_URL must be an http(s) URL")
+		check(c.Coach.LLMTimeout > 0, "COACH_LLM_TIMEOUT must be positive")
+	}
+
+	check(oneOf(c.Log.Level, "debug", "info", "warn", "error"), "LOG_LEVEL must be debug, info, warn or error, got %q", c.Log.Level)
+	check(oneOf(c.Log.Format, "json", "text"), "LOG_FORMAT must be json or text, got %q", c.Log.Format)
+
+	check(oneOf(c.Tracing.Exporter, "none", "stdout", "otlp"), "TRACING_EXPORTER must be none, stdout or otlp, got %q", c.Tracing.Exporter)
+	check(c.Tracing.Endpoint == "" || validURL(c.Tracing.Endpoint, true), "OTEL_EXPORTER_OTLP_ENDPOINT must be an http(s) URL")
+	check(c.Tracing.ServiceName != "", "OTEL_SERVICE_NAME is required")
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "TRACING_SAMPLE_RATIO must be between 0 and 1")
+
+	if len(problems) > 0 {
+		return errors.New("invalid config:\n  " + strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// String renders the configuration as YAML with secrets redacted, for
+// logging at startup.
+func (c *Config) String() string {
+	redacted := *c
+	redact(reflect.ValueOf(&redacted).Elem())
+	out, err := yaml.Marshal(redacted)
+	if err != nil {
+		return "config: " + err.Error()
+	}
+	return string(out)
+}
+
+// redact replaces secret fields in v. URLs keep everything but their
+// password, so the database host stays visible.
+func redact(v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f, fv := t.Field(i), v.Field(i)
+		if f.Type.Kind() == reflect.Struct {
+			redact(fv)
+			continue
+		}
+		if f.Tag.Get("secret") != "true" || fv.String() == "" {
+			continue
+		}
+		if u, err := url.Parse(fv.String()); err == nil && u.Scheme != "" && u.User != nil {
+			if _, ok := u.User.Password(); ok {
+				fv.SetString(u.Redacted())
+				continue
+			}
+		}
+		fv.SetString("[redacted]")
+	}
+}
+
+func oneOf(s string, options ...string) bool {
+	for _, o := range options {
+		if s == o {
+			return true
+		}
 	}
+	return false
+}
 
-	return &Config{
-		Port:        port,
-		DatabaseURL: dbURL,
-		APIKey:      apiKey,
-	}, nil
+// validOrigin accepts scheme://host[:port], where host may start with "*."
+// to match subdomains.
+func validOrigin(s string) bool {
+	u, err := url.Parse(s)
+	if err != nil || u.Path != "" || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+		return false
+	}
+	host := strings.TrimPrefix(u.Hostname(), "*.")
+	return (u.Scheme == "https" || u.Scheme == "http") && host != "" && !strings.Contains(host, "*")
+}
+
+// validURL reports whether s is an absolute https URL, or http too when
+// allowHTTP is set.
+func validURL(s string, allowHTTP bool) bool {
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	return u.Scheme == "https" || (allowHTTP && u.Scheme == "http")
 }
@@ -2,29 +2,59 @@ package db
 
 import (
 	"context"
+	"fmt"
+	"log/slog"
 	"time"
 
+	"fintech-backend/internal/config"
+	"fintech-backend/internal/logging"
+	"fintech-backend/internal/metrics"
+	"fintech-backend/internal/tracing"
+
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-func NewPool(databaseURL string) (*pgxpool.Pool, error) {
-	cfg, err := pgxpool.ParseConfig(databaseURL)
+const (
+	pingTimeout = 5 * time.Second
+	maxBackoff  = 10 * time.Second
+)
+
+// NewPool opens a pool with c's limits, tracing and timing every query and
+// logging failed ones, and pings it. Serverless Postgres can take several
+// seconds to wake, so a failed ping is retried with exponential backoff
+// until c.ConnectTimeout has passed.
+func NewPool(c config.DB) (*pgxpool.Pool, error) {
+	cfg, err := pgxpool.ParseConfig(c.URL)
 	if err != nil {
 		return nil, err
 	}
+	cfg.MaxConns = c.MaxConns
+	cfg.MinConns = c.MinConns
+	cfg.MaxConnLifetime = c.MaxConnLifetime
+	cfg.MaxConnIdleTime = c.MaxConnIdleTime
+	cfg.HealthCheckPeriod = c.HealthCheckPeriod
+	cfg.ConnConfig.Tracer = queryTracers{tracing.QueryTracer{}, metrics.QueryTracer{}, logging.QueryTracer{}}
 
 	pool, err := pgxpool.NewWithConfig(context.Background(), cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	if err := pool.Ping(ctx); err != nil {
-		pool.Close()
-		return nil, err
+	deadline := time.Now().Add(c.ConnectTimeout)
+	backoff := 500 * time.Millisecond
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+		err = pool.Ping(ctx)
+		cancel()
+		if err == nil {
+			return pool, nil
+		}
+		if time.Now().Add(backoff).After(deadline) {
+			pool.Close()
+			return nil, fmt.Errorf("ping failed after %d attempts: %w", attempt, err)
+		}
+		slog.Warn("db: ping failed, retrying", "attempt", attempt, "backoff", backoff, "err", err)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, maxBackoff)
 	}
-
-	return pool, nil
 }
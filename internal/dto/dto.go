@@ -1,59 +1,149 @@
+// Package dto holds the JSON request bodies. Each field's validate tag is
+// checked by validate.Struct before a request reaches the service layer.
 package dto
 
 // ====== SHOPS ======
 
 type CreateShopRequest struct {
-	Name       string `json:"name"`
-	Address    string `json:"address"`
-	GSTNumber  string `json:"gst_number"`
-	OwnerEmail string `json:"owner_email"`
+	Name       string `json:"name" validate:"required,max=200"`
+	Address    string `json:"address" validate:"max=500"`
+	GSTNumber  string `json:"gst_number" validate:"gstin"`
+	OwnerEmail string `json:"owner_email" validate:"required,email"`
+	Timezone   string `json:"timezone" validate:"timezone"`
+}
+
+// UpdateShopRequest changes only the fields that are present. An empty
+// gst_number clears it.
+type UpdateShopRequest struct {
+	Name      *string `json:"name" validate:"required,max=200"`
+	Address   *string `json:"address" validate:"max=500"`
+	GSTNumber *string `json:"gst_number" validate:"gstin"`
+	Timezone  *string `json:"timezone" validate:"timezone"`
 }
 
 // ====== PRODUCTS ======
 
 type CreateProductRequest struct {
-	ShopID            string  `json:"shop_id"`
-	Name              string  `json:"name"`
-	SKU               string  `json:"sku"`
-	Stock             int     `json:"stock"`
-	CostPrice         float64 `json:"cost_price"`
-	SellingPrice      float64 `json:"selling_price"`
-	LowStockThreshold int     `json:"low_stock_threshold"`
+	ShopID            string  `json:"shop_id" validate:"required,uuid"`
+	Name              string  `json:"name" validate:"required,max=200"`
+	SKU               string  `json:"sku" validate:"max=64"`
+	Stock             int     `json:"stock" validate:"min=0"`
+	CostPrice         float64 `json:"cost_price" validate:"min=0"`
+	SellingPrice      float64 `json:"selling_price" validate:"min=0"`
+	LowStockThreshold int     `json:"low_stock_threshold" validate:"min=0"`
+	HSNCode           string  `json:"hsn_code" validate:"hsn"`
+	GSTRate           float64 `json:"gst_rate" validate:"gstrate"`
+}
+
+// UpdateProductRequest changes only the fields that are present. An empty
+// sku or hsn_code clears it.
+type UpdateProductRequest struct {
+	Name              *string  `json:"name" validate:"required,max=200"`
+	SKU               *string  `json:"sku" validate:"max=64"`
+	CostPrice         *float64 `json:"cost_price" validate:"min=0"`
+	SellingPrice      *float64 `json:"selling_price" validate:"min=0"`
+	LowStockThreshold *int     `json:"low_stock_threshold" validate:"min=0"`
+	HSNCode           *string  `json:"hsn_code" validate:"hsn"`
+	GSTRate           *float64 `json:"gst_rate" validate:"gstrate"`
 }
 
 // ====== INVOICES ======
 
 type InvoiceItemRequest struct {
-	ProductID string  `json:"product_id"`
-	Quantity  int     `json:"quantity"`
-	UnitPrice float64 `json:"unit_price"`
+	ProductID string  `json:"product_id" validate:"required,uuid"`
+	Quantity  int     `json:"quantity" validate:"min=1"`
+	UnitPrice float64 `json:"unit_price" validate:"min=0"`
 }
 
 type CreateInvoiceRequest struct {
-	ShopID        string               `json:"shop_id"`
-	CustomerName  string               `json:"customer_name"`
-	CustomerPhone string               `json:"customer_phone"`
-	TaxAmount     float64              `json:"tax_amount"`
-	Items         []InvoiceItemRequest `json:"items"`
+	ShopID        string               `json:"shop_id" validate:"required,uuid"`
+	CustomerName  string               `json:"customer_name" validate:"max=200"`
+	CustomerPhone string               `json:"customer_phone" validate:"max=20"`
+	TaxAmount     float64              `json:"tax_amount" validate:"min=0"`
+	Items         []InvoiceItemRequest `json:"items" validate:"required,max=100"`
+	// Status is PAID (default) or CREDIT. Credit invoices are due on DueDate
+	// (YYYY-MM-DD), or 30 days after creation when it is empty.
+	Status  string `json:"status" validate:"oneof=PAID CREDIT"`
+	DueDate string `json:"due_date" validate:"date"`
+	// CustomerGSTIN makes the sale B2B. PlaceOfSupply is a two-digit state
+	// code; it defaults to the customer's GSTIN state, then the shop's.
+	// When TaxAmount is zero, tax is computed from each product's gst_rate.
+	CustomerGSTIN string `json:"customer_gstin" validate:"gstin"`
+	PlaceOfSupply string `json:"place_of_supply" validate:"state"`
+}
+
+// CreateCreditNoteRequest credits TaxableValue (before tax) of an invoice
+// back to the customer; tax is computed at GSTRate.
+type CreateCreditNoteRequest struct {
+	TaxableValue float64 `json:"taxable_value" validate:"gt=0"`
+	GSTRate      float64 `json:"gst_rate" validate:"gstrate"`
+	Reason       string  `json:"reason" validate:"max=500"`
 }
 
 // ====== EXPENSES ======
 
 type CreateExpenseRequest struct {
-	ShopID   string  `json:"shop_id"`
-	Category string  `json:"category"`
-	Amount   float64 `json:"amount"`
-	Note     string  `json:"note"`
+	ShopID   string  `json:"shop_id" validate:"required,uuid"`
+	Category string  `json:"category" validate:"required,max=100"`
+	Amount   float64 `json:"amount" validate:"gt=0"`
+	Note     string  `json:"note" validate:"max=500"`
+	Mood     string  `json:"mood" validate:"max=50"`
+	// InputTax is the GST included in Amount; it is claimable only with a
+	// SupplierGSTIN.
+	SupplierGSTIN string  `json:"supplier_gstin" validate:"gstin"`
+	InputTax      float64 `json:"input_tax" validate:"min=0"`
 }
 
 // ====== POTS ======
 
 type CreatePotRequest struct {
-	ShopID       string  `json:"shop_id"`
-	Name         string  `json:"name"`
-	TargetAmount float64 `json:"target_amount"`
+	ShopID       string  `json:"shop_id" validate:"required,uuid"`
+	Name         string  `json:"name" validate:"required,max=100"`
+	TargetAmount float64 `json:"target_amount" validate:"min=0"`
 }
 
 type DepositPotRequest struct {
-	Amount float64 `json:"amount"`
+	Amount float64 `json:"amount" validate:"gt=0"`
+}
+
+// ====== COACH ======
+
+type SnoozeInsightRequest struct {
+	Days int `json:"days" validate:"min=0,max=90"`
+}
+
+// ====== PAYOUTS ======
+
+type UPIDestination struct {
+	VPA  string `json:"vpa" validate:"required,max=255"`
+	Name string `json:"name" validate:"max=100"`
+}
+
+type BankDestination struct {
+	Account string `json:"account" validate:"required"`
+	IFSC    string `json:"ifsc" validate:"required"`
+	Name    string `json:"name" validate:"max=100"`
+}
+
+// CreatePayoutRequest takes the amount either in paise (amount_cents) or in
+// rupees (amount); amount_cents wins when both are set.
+type CreatePayoutRequest struct {
+	Amount      float64          `json:"amount" validate:"min=0"`
+	AmountCents int64            `json:"amount_cents" validate:"min=0"`
+	Currency    string           `json:"currency" validate:"max=3"`
+	Method      string           `json:"method" validate:"required,oneof=upi bank"`
+	UPI         *UPIDestination  `json:"upi"`
+	Bank        *BankDestination `json:"bank"`
+	ReferenceID string           `json:"reference_id" validate:"max=64"`
+}
+
+// ====== WEBHOOKS ======
+
+// CreateWebhookRequest subscribes url to events, e.g. ["payout.*",
+// "stock.low"]. Secret is optional; without one a secret is generated for the
+// endpoint and returned in the response.
+type CreateWebhookRequest struct {
+	URL    string   `json:"url" validate:"required,url"`
+	Events []string `json:"events" validate:"required,max=20"`
+	Secret string   `json:"secret" validate:"max=255"`
 }
@@ -0,0 +1,138 @@
+package dto_test
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	"fintech-backend/internal/dto"
+	"fintech-backend/internal/validate"
+)
+
+const (
+	shopID    = "5f0c3b1e-8d4a-4c2b-9a7e-1f2d3c4b5a69"
+	productID = "0b6f1c2d-3e4f-4a5b-8c6d-7e8f9a0b1c2d"
+	gstin     = "27AAPFU0939F1ZV"
+)
+
+// invalidFields validates req and returns the names of the failing fields.
+func invalidFields(t *testing.T, req any) []string {
+	t.Helper()
+	err := validate.Struct(req)
+	if err == nil {
+		return nil
+	}
+	var errs validate.Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Struct returned %T, want validate.Errors", err)
+	}
+	names := make([]string, len(errs))
+	for i, fe := range errs {
+		names[i] = fe.Field
+	}
+	sort.Strings(names)
+	return names
+}
+
+func check(t *testing.T, req any, want ...string) {
+	t.Helper()
+	sort.Strings(want)
+	if got := invalidFields(t, req); len(got)+len(want) > 0 && !reflect.DeepEqual(got, want) {
+		t.Errorf("invalid fields = %v, want %v", got, want)
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
+
+func TestCreateShopRequest(t *testing.T) {
+	check(t, &dto.CreateShopRequest{Name: "Kirana", OwnerEmail: "a@b.in", GSTNumber: gstin, Timezone: "Asia/Kolkata"})
+	check(t, &dto.CreateShopRequest{Name: "Kirana", OwnerEmail: "a@b.in"})
+	check(t, &dto.CreateShopRequest{Name: "  ", OwnerEmail: "nope", GSTNumber: "27AAPFU0939F1ZW", Timezone: "Mars/Base"},
+		"name", "owner_email", "gst_number", "timezone")
+}
+
+func TestUpdateShopRequest(t *testing.T) {
+	check(t, &dto.UpdateShopRequest{})
+	check(t, &dto.UpdateShopRequest{GSTNumber: ptr("")})
+	check(t, &dto.UpdateShopRequest{Name: ptr(""), GSTNumber: ptr("ABC")}, "name", "gst_number")
+}
+
+func TestCreateProductRequest(t *testing.T) {
+	check(t, &dto.CreateProductRequest{ShopID: shopID, Name: "Rice 5kg", Stock: 10, SellingPrice: 450, HSNCode: "1006", GSTRate: 5})
+	check(t, &dto.CreateProductRequest{ShopID: "x", Stock: -1, CostPrice: -1, SellingPrice: -5, LowStockThreshold: -1, HSNCode: "10", GSTRate: 17},
+		"shop_id", "name", "stock", "cost_price", "selling_price", "low_stock_threshold", "hsn_code", "gst_rate")
+}
+
+func TestUpdateProductRequest(t *testing.T) {
+	check(t, &dto.UpdateProductRequest{SellingPrice: ptr(99.0), GSTRate: ptr(18.0)})
+	check(t, &dto.UpdateProductRequest{Name: ptr(" "), SellingPrice: ptr(-1.0), LowStockThreshold: ptr(-2), GSTRate: ptr(13.0)},
+		"name", "selling_price", "low_stock_threshold", "gst_rate")
+}
+
+func TestInvoiceItemRequest(t *testing.T) {
+	check(t, &dto.InvoiceItemRequest{ProductID: productID, Quantity: 1, UnitPrice: 10})
+	check(t, &dto.InvoiceItemRequest{ProductID: "p1", Quantity: 0, UnitPrice: -1}, "product_id", "quantity", "unit_price")
+}
+
+func TestCreateInvoiceRequest(t *testing.T) {
+	item := dto.InvoiceItemRequest{ProductID: productID, Quantity: 2, UnitPrice: 50}
+	check(t, &dto.CreateInvoiceRequest{ShopID: shopID, Items: []dto.InvoiceItemRequest{item}, CustomerGSTIN: gstin, Status: "CREDIT", DueDate: "2025-12-31"})
+	check(t, &dto.CreateInvoiceRequest{ShopID: shopID}, "items")
+	check(t, &dto.CreateInvoiceRequest{
+		ShopID:        shopID,
+		TaxAmount:     -1,
+		Items:         []dto.InvoiceItemRequest{item, {ProductID: productID, Quantity: -3}},
+		Status:        "LATER",
+		DueDate:       "31/12/2025",
+		PlaceOfSupply: "99",
+	}, "tax_amount", "items[1].quantity", "status", "due_date", "place_of_supply")
+}
+
+func TestCreateCreditNoteRequest(t *testing.T) {
+	check(t, &dto.CreateCreditNoteRequest{TaxableValue: 100, GSTRate: 18})
+	check(t, &dto.CreateCreditNoteRequest{TaxableValue: 0, GSTRate: 19}, "taxable_value", "gst_rate")
+}
+
+func TestCreateExpenseRequest(t *testing.T) {
+	check(t, &dto.CreateExpenseRequest{ShopID: shopID, Category: "Rent", Amount: 15000})
+	check(t, &dto.CreateExpenseRequest{ShopID: shopID, Category: "", Amount: 0, InputTax: -1, SupplierGSTIN: "bad"},
+		"category", "amount", "input_tax", "supplier_gstin")
+	check(t, &dto.CreateExpenseRequest{ShopID: shopID, Category: "Stock", Amount: -10}, "amount")
+}
+
+func TestCreatePotRequest(t *testing.T) {
+	check(t, &dto.CreatePotRequest{ShopID: shopID, Name: "Diwali stock", TargetAmount: 50000})
+	check(t, &dto.CreatePotRequest{Name: "", TargetAmount: -1}, "shop_id", "name", "target_amount")
+}
+
+func TestDepositPotRequest(t *testing.T) {
+	check(t, &dto.DepositPotRequest{Amount: 500})
+	check(t, &dto.DepositPotRequest{Amount: 0}, "amount")
+}
+
+func TestSnoozeInsightRequest(t *testing.T) {
+	check(t, &dto.SnoozeInsightRequest{})
+	check(t, &dto.SnoozeInsightRequest{Days: 91}, "days")
+}
+
+func TestCreatePayoutRequest(t *testing.T) {
+	check(t, &dto.CreatePayoutRequest{AmountCents: 10000, Method: "upi", UPI: &dto.UPIDestination{VPA: "a@okhdfc"}})
+	check(t, &dto.CreatePayoutRequest{Amount: -5, Method: "cash"}, "amount", "method")
+	check(t, &dto.CreatePayoutRequest{Amount: 5, Method: "bank", Bank: &dto.BankDestination{}}, "bank.account", "bank.ifsc")
+}
+
+func TestUPIDestination(t *testing.T) {
+	check(t, &dto.UPIDestination{VPA: "shop@upi"})
+	check(t, &dto.UPIDestination{}, "vpa")
+}
+
+func TestBankDestination(t *testing.T) {
+	check(t, &dto.BankDestination{Account: "123456789", IFSC: "HDFC0001234"})
+	check(t, &dto.BankDestination{Name: "x"}, "account", "ifsc")
+}
+
+func TestCreateWebhookRequest(t *testing.T) {
+	check(t, &dto.CreateWebhookRequest{URL: "https://example.com/hooks", Events: []string{"payout.*"}})
+	check(t, &dto.CreateWebhookRequest{URL: "ftp://example.com"}, "url", "events")
+}
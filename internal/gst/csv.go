@@ -0,0 +1,129 @@
+package gst
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// GSTR1Sections are the sheets WriteGSTR1CSV can produce.
+var GSTR1Sections = []string{"b2b", "b2cl", "b2cs", "cdnr", "cdnur", "hsn"}
+
+// csvDate is the dd-Mon-yyyy form used in the offline tool's CSV sheets.
+const csvDate = "02-Jan-2006"
+
+// WriteGSTR1CSV writes one GSTR-1 section with the offline tool's column
+// headers.
+func WriteGSTR1CSV(w io.Writer, r *GSTR1, section string) error {
+	cw := csv.NewWriter(w)
+	var rows [][]string
+
+	switch section {
+	case "b2b":
+		rows = append(rows, []string{"GSTIN/UIN of Recipient", "Receiver Name", "Invoice Number", "Invoice date",
+			"Invoice Value", "Place Of Supply", "Reverse Charge", "Applicable % of Tax Rate", "Invoice Type",
+			"E-Commerce GSTIN", "Rate", "Taxable Value", "Cess Amount"})
+		for _, c := range r.B2B {
+			for _, inv := range c.Inv {
+				for _, it := range inv.Itms {
+					rows = append(rows, []string{c.CTIN, c.Name, inv.INum, csvDateOf(inv.IDt),
+						money(inv.Val), PlaceOfSupply(inv.POS), inv.RChrg, "", "Regular B2B",
+						"", rate(it.ItmDet.Rt), money(it.ItmDet.TxVal), money(it.ItmDet.CsAmt)})
+				}
+			}
+		}
+	case "b2cl":
+		rows = append(rows, []string{"Invoice Number", "Invoice date", "Invoice Value", "Place Of Supply",
+			"Applicable % of Tax Rate", "Rate", "Taxable Value", "Cess Amount", "E-Commerce GSTIN"})
+		for _, p := range r.B2CL {
+			for _, inv := range p.Inv {
+				for _, it := range inv.Itms {
+					rows = append(rows, []string{inv.INum, csvDateOf(inv.IDt), money(inv.Val), PlaceOfSupply(p.POS),
+						"", rate(it.ItmDet.Rt), money(it.ItmDet.TxVal), money(it.ItmDet.CsAmt), ""})
+				}
+			}
+		}
+	case "b2cs":
+		rows = append(rows, []string{"Type", "Place Of Supply", "Applicable % of Tax Rate", "Rate",
+			"Taxable Value", "Cess Amount", "E-Commerce GSTIN"})
+		for _, b := range r.B2CS {
+			rows = append(rows, []string{b.Typ, PlaceOfSupply(b.POS), "", rate(b.Rt),
+				money(b.TxVal), money(b.CsAmt), ""})
+		}
+	case "cdnr":
+		rows = append(rows, []string{"GSTIN/UIN of Recipient", "Receiver Name", "Note Number", "Note Date",
+			"Note Type", "Place Of Supply", "Reverse Charge", "Note Supply Type", "Note Value",
+			"Applicable % of Tax Rate", "Rate", "Taxable Value", "Cess Amount"})
+		for _, c := range r.CDNR {
+			for _, n := range c.Nt {
+				for _, it := range n.Itms {
+					rows = append(rows, []string{c.CTIN, c.Name, n.NtNum, csvDateOf(n.NtDt),
+						n.Ntty, PlaceOfSupply(n.POS), n.RChrg, "Regular B2B", money(n.Val),
+						"", rate(it.ItmDet.Rt), money(it.ItmDet.TxVal), money(it.ItmDet.CsAmt)})
+				}
+			}
+		}
+	case "cdnur":
+		rows = append(rows, []string{"UR Type", "Note Number", "Note Date", "Note Type", "Place Of Supply",
+			"Note Value", "Applicable % of Tax Rate", "Rate", "Taxable Value", "Cess Amount"})
+		for _, n := range r.CDNUR {
+			for _, it := range n.Itms {
+				rows = append(rows, []string{n.Typ, n.NtNum, csvDateOf(n.NtDt), n.Ntty, PlaceOfSupply(n.POS),
+					money(n.Val), "", rate(it.ItmDet.Rt), money(it.ItmDet.TxVal), money(it.ItmDet.CsAmt)})
+			}
+		}
+	case "hsn":
+		rows = append(rows, []string{"HSN", "Description", "UQC", "Total Quantity", "Total Value", "Rate",
+			"Taxable Value", "Integrated Tax Amount", "Central Tax Amount", "State/UT Tax Amount", "Cess Amount"})
+		for _, h := range r.HSN.Data {
+			rows = append(rows, []string{h.HSNSc, h.Desc, h.UQC, strconv.FormatFloat(h.Qty, 'f', -1, 64),
+				money(h.Val), rate(h.Rt), money(h.TxVal), money(h.IAmt), money(h.CAmt), money(h.SAmt), money(h.CsAmt)})
+		}
+	default:
+		return fmt.Errorf("unknown GSTR-1 section %q", section)
+	}
+
+	if err := cw.WriteAll(rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+// WriteGSTR3BCSV writes the outward supplies, input tax credit and the net
+// tax payable as one sheet.
+func WriteGSTR3BCSV(w io.Writer, r *GSTR3B) error {
+	out, exempt := r.SupDetails.OSupDet, r.SupDetails.OSupNilExmp
+	itc := r.ITCElg.ITCNet
+	net := r.NetPayable()
+
+	rows := [][]string{
+		{"Section", "Description", "Taxable Value", "Integrated Tax", "Central Tax", "State/UT Tax", "Cess"},
+		{"3.1(a)", "Outward taxable supplies", money(out.TxVal), money(out.IAmt), money(out.CAmt), money(out.SAmt), money(out.CsAmt)},
+		{"3.1(c)", "Nil rated and exempted supplies", money(exempt.TxVal), "", "", "", ""},
+		{"4(A)(5)", "All other ITC", "", money(itc.IAmt), money(itc.CAmt), money(itc.SAmt), money(itc.CsAmt)},
+		{"", "Net tax payable", "", money(net.IAmt), money(net.CAmt), money(net.SAmt), money(net.CsAmt)},
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.WriteAll(rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+func csvDateOf(jsonDate string) string {
+	t, err := time.Parse(invoiceDate, jsonDate)
+	if err != nil {
+		return jsonDate
+	}
+	return t.Format(csvDate)
+}
+
+func money(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+func rate(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
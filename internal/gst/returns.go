@@ -0,0 +1,525 @@
+package gst
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// B2CLThreshold is the invoice value above which an inter-state sale to an
+// unregistered buyer is reported invoice-wise in B2CL (₹1 lakh from August
+// 2024) instead of being summarised in B2CS.
+const B2CLThreshold = 100000
+
+// Rates are the GST rates, in percent, a product or credit note may carry,
+// including the slabs retired in September 2025 so older data stays valid.
+var Rates = []float64{0, 0.1, 0.25, 1, 1.5, 3, 5, 6, 7.5, 12, 18, 28, 40}
+
+// ValidRate reports whether r is one of Rates.
+func ValidRate(r float64) bool {
+	for _, v := range Rates {
+		if v == r {
+			return true
+		}
+	}
+	return false
+}
+
+// Period is a monthly tax period.
+type Period struct {
+	Year  int
+	Month time.Month
+}
+
+// ParsePeriod accepts YYYY-MM or the GSTN form MMYYYY.
+func ParsePeriod(s string) (Period, error) {
+	for _, layout := range []string{"2006-01", "012006"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return Period{Year: t.Year(), Month: t.Month()}, nil
+		}
+	}
+	return Period{}, fmt.Errorf("invalid period %q, expected YYYY-MM", s)
+}
+
+// FP is the filing period as GSTN writes it, e.g. "112025".
+func (p Period) FP() string {
+	return fmt.Sprintf("%02d%04d", int(p.Month), p.Year)
+}
+
+// Bounds returns the period's [start, end) in loc.
+func (p Period) Bounds(loc *time.Location) (time.Time, time.Time) {
+	start := time.Date(p.Year, p.Month, 1, 0, 0, 0, 0, loc)
+	return start, start.AddDate(0, 1, 0)
+}
+
+// Line is one invoice line. Tax is the line's share of the invoice tax.
+type Line struct {
+	HSN      string
+	Rate     float64
+	Quantity float64
+	Taxable  float64
+	Tax      float64
+}
+
+// Invoice is an outward supply. CustomerGSTIN is empty for unregistered
+// buyers; POS is the place-of-supply state code.
+type Invoice struct {
+	Number        string
+	Date          time.Time
+	Value         float64
+	CustomerGSTIN string
+	CustomerName  string
+	POS           string
+	Lines         []Line
+}
+
+// CreditNote reduces the taxable value and tax of Invoice.
+type CreditNote struct {
+	Number  string
+	Date    time.Time
+	Invoice Invoice
+	Taxable float64
+	Rate    float64
+	Tax     float64
+}
+
+// InputTax is GST paid on purchases from a supplier in SupplierState.
+type InputTax struct {
+	SupplierState string
+	Tax           float64
+}
+
+// ========== GSTR-1 ==========
+
+type ItemDetail struct {
+	TxVal float64 `json:"txval"`
+	Rt    float64 `json:"rt"`
+	IAmt  float64 `json:"iamt,omitempty"`
+	CAmt  float64 `json:"camt,omitempty"`
+	SAmt  float64 `json:"samt,omitempty"`
+	CsAmt float64 `json:"csamt"`
+}
+
+type Item struct {
+	Num    int        `json:"num"`
+	ItmDet ItemDetail `json:"itm_det"`
+}
+
+type B2BInvoice struct {
+	INum   string  `json:"inum"`
+	IDt    string  `json:"idt"`
+	Val    float64 `json:"val"`
+	POS    string  `json:"pos"`
+	RChrg  string  `json:"rchrg"`
+	InvTyp string  `json:"inv_typ"`
+	Itms   []Item  `json:"itms"`
+}
+
+type B2BCustomer struct {
+	CTIN string       `json:"ctin"`
+	Name string       `json:"-"`
+	Inv  []B2BInvoice `json:"inv"`
+}
+
+type B2CLInvoice struct {
+	INum string  `json:"inum"`
+	IDt  string  `json:"idt"`
+	Val  float64 `json:"val"`
+	Itms []Item  `json:"itms"`
+}
+
+type B2CLPlace struct {
+	POS string        `json:"pos"`
+	Inv []B2CLInvoice `json:"inv"`
+}
+
+type B2CSRow struct {
+	SplyTy string  `json:"sply_ty"`
+	POS    string  `json:"pos"`
+	Typ    string  `json:"typ"`
+	Rt     float64 `json:"rt"`
+	TxVal  float64 `json:"txval"`
+	IAmt   float64 `json:"iamt,omitempty"`
+	CAmt   float64 `json:"camt,omitempty"`
+	SAmt   float64 `json:"samt,omitempty"`
+	CsAmt  float64 `json:"csamt"`
+}
+
+type Note struct {
+	NtNum  string  `json:"nt_num"`
+	NtDt   string  `json:"nt_dt"`
+	Ntty   string  `json:"ntty"`
+	Val    float64 `json:"val"`
+	POS    string  `json:"pos"`
+	RChrg  string  `json:"rchrg"`
+	InvTyp string  `json:"inv_typ"`
+	Itms   []Item  `json:"itms"`
+}
+
+type CDNRCustomer struct {
+	CTIN string `json:"ctin"`
+	Name string `json:"-"`
+	Nt   []Note `json:"nt"`
+}
+
+type CDNURNote struct {
+	Typ   string  `json:"typ"`
+	Ntty  string  `json:"ntty"`
+	NtNum string  `json:"nt_num"`
+	NtDt  string  `json:"nt_dt"`
+	Val   float64 `json:"val"`
+	POS   string  `json:"pos"`
+	Itms  []Item  `json:"itms"`
+}
+
+type HSNRow struct {
+	Num   int     `json:"num"`
+	HSNSc string  `json:"hsn_sc"`
+	Desc  string  `json:"desc"`
+	UQC   string  `json:"uqc"`
+	Qty   float64 `json:"qty"`
+	Val   float64 `json:"val"`
+	Rt    float64 `json:"rt"`
+	TxVal float64 `json:"txval"`
+	IAmt  float64 `json:"iamt"`
+	CAmt  float64 `json:"camt"`
+	SAmt  float64 `json:"samt"`
+	CsAmt float64 `json:"csamt"`
+}
+
+type HSNSummary struct {
+	Data []HSNRow `json:"data"`
+}
+
+type GSTR1 struct {
+	GSTIN string         `json:"gstin"`
+	FP    string         `json:"fp"`
+	B2B   []B2BCustomer  `json:"b2b"`
+	B2CL  []B2CLPlace    `json:"b2cl"`
+	B2CS  []B2CSRow      `json:"b2cs"`
+	CDNR  []CDNRCustomer `json:"cdnr"`
+	CDNUR []CDNURNote    `json:"cdnur"`
+	HSN   HSNSummary     `json:"hsn"`
+}
+
+// invoiceDate is the dd-mm-yyyy form used in the JSON.
+const invoiceDate = "02-01-2006"
+
+// BuildGSTR1 sorts the period's invoices and credit notes into GSTR-1
+// sections for the supplier gstin. Invoices with an empty POS are treated as
+// intra-state.
+func BuildGSTR1(gstin string, p Period, invoices []Invoice, notes []CreditNote) *GSTR1 {
+	home := gstin[:2]
+	r := &GSTR1{
+		GSTIN: gstin,
+		FP:    p.FP(),
+		B2B:   []B2BCustomer{},
+		B2CL:  []B2CLPlace{},
+		B2CS:  []B2CSRow{},
+		CDNR:  []CDNRCustomer{},
+		CDNUR: []CDNURNote{},
+		HSN:   HSNSummary{Data: []HSNRow{}},
+	}
+
+	b2b := map[string]*B2BCustomer{}
+	b2cl := map[string]*B2CLPlace{}
+	b2cs := map[string]*B2CSRow{}
+	hsn := map[string]*HSNRow{}
+	cdnr := map[string]*CDNRCustomer{}
+
+	for _, inv := range invoices {
+		pos := posOrHome(inv.POS, home)
+		inter := pos != home
+
+		for _, l := range inv.Lines {
+			key := fmt.Sprintf("%s|%g", l.HSN, l.Rate)
+			h, ok := hsn[key]
+			if !ok {
+				h = &HSNRow{HSNSc: l.HSN, UQC: "NOS", Rt: l.Rate}
+				hsn[key] = h
+			}
+			i, c, s := split(l.Tax, inter)
+			h.Qty += l.Quantity
+			h.Val += l.Taxable + l.Tax
+			h.TxVal += l.Taxable
+			h.IAmt += i
+			h.CAmt += c
+			h.SAmt += s
+		}
+
+		switch {
+		case inv.CustomerGSTIN != "":
+			cust, ok := b2b[inv.CustomerGSTIN]
+			if !ok {
+				cust = &B2BCustomer{CTIN: inv.CustomerGSTIN, Name: inv.CustomerName}
+				b2b[inv.CustomerGSTIN] = cust
+			}
+			cust.Inv = append(cust.Inv, B2BInvoice{
+				INum:   inv.Number,
+				IDt:    inv.Date.Format(invoiceDate),
+				Val:    round2(inv.Value),
+				POS:    pos,
+				RChrg:  "N",
+				InvTyp: "R",
+				Itms:   rateItems(inv.Lines, inter),
+			})
+		case inter && inv.Value > B2CLThreshold:
+			place, ok := b2cl[pos]
+			if !ok {
+				place = &B2CLPlace{POS: pos}
+				b2cl[pos] = place
+			}
+			place.Inv = append(place.Inv, B2CLInvoice{
+				INum: inv.Number,
+				IDt:  inv.Date.Format(invoiceDate),
+				Val:  round2(inv.Value),
+				Itms: rateItems(inv.Lines, inter),
+			})
+		default:
+			for _, l := range inv.Lines {
+				addB2CS(b2cs, pos, inter, l.Rate, l.Taxable, l.Tax)
+			}
+		}
+	}
+
+	for _, n := range notes {
+		pos := posOrHome(n.Invoice.POS, home)
+		inter := pos != home
+		items := rateItems([]Line{{Rate: n.Rate, Taxable: n.Taxable, Tax: n.Tax}}, inter)
+		value := round2(n.Taxable + n.Tax)
+
+		switch {
+		case n.Invoice.CustomerGSTIN != "":
+			cust, ok := cdnr[n.Invoice.CustomerGSTIN]
+			if !ok {
+				cust = &CDNRCustomer{CTIN: n.Invoice.CustomerGSTIN, Name: n.Invoice.CustomerName}
+				cdnr[n.Invoice.CustomerGSTIN] = cust
+			}
+			cust.Nt = append(cust.Nt, Note{
+				NtNum:  n.Number,
+				NtDt:   n.Date.Format(invoiceDate),
+				Ntty:   "C",
+				Val:    value,
+				POS:    pos,
+				RChrg:  "N",
+				InvTyp: "R",
+				Itms:   items,
+			})
+		case inter && n.Invoice.Value > B2CLThreshold:
+			r.CDNUR = append(r.CDNUR, CDNURNote{
+				Typ:   "B2CL",
+				Ntty:  "C",
+				NtNum: n.Number,
+				NtDt:  n.Date.Format(invoiceDate),
+				Val:   value,
+				POS:   pos,
+				Itms:  items,
+			})
+		default:
+			// Credit notes against B2CS supplies are netted into B2CS.
+			addB2CS(b2cs, pos, inter, n.Rate, -n.Taxable, -n.Tax)
+		}
+	}
+
+	for _, k := range sortedKeys(b2b) {
+		r.B2B = append(r.B2B, *b2b[k])
+	}
+	for _, k := range sortedKeys(b2cl) {
+		r.B2CL = append(r.B2CL, *b2cl[k])
+	}
+	for _, k := range sortedKeys(b2cs) {
+		row := b2cs[k]
+		row.TxVal, row.IAmt, row.CAmt, row.SAmt = round2(row.TxVal), round2(row.IAmt), round2(row.CAmt), round2(row.SAmt)
+		r.B2CS = append(r.B2CS, *row)
+	}
+	for _, k := range sortedKeys(cdnr) {
+		r.CDNR = append(r.CDNR, *cdnr[k])
+	}
+	for i, k := range sortedKeys(hsn) {
+		h := hsn[k]
+		h.Num = i + 1
+		h.Val, h.TxVal = round2(h.Val), round2(h.TxVal)
+		h.IAmt, h.CAmt, h.SAmt = round2(h.IAmt), round2(h.CAmt), round2(h.SAmt)
+		r.HSN.Data = append(r.HSN.Data, *h)
+	}
+	return r
+}
+
+func addB2CS(rows map[string]*B2CSRow, pos string, inter bool, rate, taxable, tax float64) {
+	ty := "INTRA"
+	if inter {
+		ty = "INTER"
+	}
+	key := fmt.Sprintf("%s|%s|%g", ty, pos, rate)
+	row, ok := rows[key]
+	if !ok {
+		row = &B2CSRow{SplyTy: ty, POS: pos, Typ: "OE", Rt: rate}
+		rows[key] = row
+	}
+	i, c, s := split(tax, inter)
+	row.TxVal += taxable
+	row.IAmt += i
+	row.CAmt += c
+	row.SAmt += s
+}
+
+// rateItems groups lines by rate, numbering items the way the offline tool
+// does (rate × 100 + 1, e.g. 1801 for 18%).
+func rateItems(lines []Line, inter bool) []Item {
+	byRate := map[float64]*ItemDetail{}
+	for _, l := range lines {
+		d, ok := byRate[l.Rate]
+		if !ok {
+			d = &ItemDetail{Rt: l.Rate}
+			byRate[l.Rate] = d
+		}
+		i, c, s := split(l.Tax, inter)
+		d.TxVal += l.Taxable
+		d.IAmt += i
+		d.CAmt += c
+		d.SAmt += s
+	}
+
+	rates := make([]float64, 0, len(byRate))
+	for rt := range byRate {
+		rates = append(rates, rt)
+	}
+	sort.Float64s(rates)
+
+	items := make([]Item, 0, len(rates))
+	for _, rt := range rates {
+		d := byRate[rt]
+		d.TxVal, d.IAmt, d.CAmt, d.SAmt = round2(d.TxVal), round2(d.IAmt), round2(d.CAmt), round2(d.SAmt)
+		items = append(items, Item{Num: int(math.Round(rt*100)) + 1, ItmDet: *d})
+	}
+	return items
+}
+
+// ========== GSTR-3B ==========
+
+type TaxAmounts struct {
+	TxVal float64 `json:"txval"`
+	IAmt  float64 `json:"iamt"`
+	CAmt  float64 `json:"camt"`
+	SAmt  float64 `json:"samt"`
+	CsAmt float64 `json:"csamt"`
+}
+
+type SupDetails struct {
+	// OSupDet is 3.1(a): outward taxable supplies other than zero rated,
+	// nil rated and exempted.
+	OSupDet TaxAmounts `json:"osup_det"`
+	// OSupNilExmp is 3.1(c): nil rated and exempted supplies.
+	OSupNilExmp TaxAmounts `json:"osup_nil_exmp"`
+}
+
+type ITC struct {
+	Ty    string  `json:"ty"`
+	IAmt  float64 `json:"iamt"`
+	CAmt  float64 `json:"camt"`
+	SAmt  float64 `json:"samt"`
+	CsAmt float64 `json:"csamt"`
+}
+
+type ITCElg struct {
+	ITCAvl []ITC `json:"itc_avl"`
+	ITCRev []ITC `json:"itc_rev"`
+	ITCNet ITC   `json:"itc_net"`
+}
+
+type GSTR3B struct {
+	GSTIN      string     `json:"gstin"`
+	RetPeriod  string     `json:"ret_period"`
+	SupDetails SupDetails `json:"sup_details"`
+	ITCElg     ITCElg     `json:"itc_elg"`
+}
+
+// BuildGSTR3B summarises outward tax, net of credit notes, against input tax
+// credit from registered suppliers.
+func BuildGSTR3B(gstin string, p Period, invoices []Invoice, notes []CreditNote, inputs []InputTax) *GSTR3B {
+	home := gstin[:2]
+	r := &GSTR3B{GSTIN: gstin, RetPeriod: p.FP()}
+
+	add := func(taxable, tax, rate float64, inter bool) {
+		dst := &r.SupDetails.OSupDet
+		if rate == 0 {
+			dst = &r.SupDetails.OSupNilExmp
+		}
+		i, c, s := split(tax, inter)
+		dst.TxVal += taxable
+		dst.IAmt += i
+		dst.CAmt += c
+		dst.SAmt += s
+	}
+	for _, inv := range invoices {
+		inter := posOrHome(inv.POS, home) != home
+		for _, l := range inv.Lines {
+			add(l.Taxable, l.Tax, l.Rate, inter)
+		}
+	}
+	for _, n := range notes {
+		inter := posOrHome(n.Invoice.POS, home) != home
+		add(-n.Taxable, -n.Tax, n.Rate, inter)
+	}
+	for _, t := range []*TaxAmounts{&r.SupDetails.OSupDet, &r.SupDetails.OSupNilExmp} {
+		t.TxVal, t.IAmt, t.CAmt, t.SAmt = round2(t.TxVal), round2(t.IAmt), round2(t.CAmt), round2(t.SAmt)
+	}
+
+	// 4(A)(5): all other ITC.
+	other := ITC{Ty: "OTH"}
+	for _, in := range inputs {
+		i, c, s := split(in.Tax, in.SupplierState != home)
+		other.IAmt += i
+		other.CAmt += c
+		other.SAmt += s
+	}
+	other.IAmt, other.CAmt, other.SAmt = round2(other.IAmt), round2(other.CAmt), round2(other.SAmt)
+	r.ITCElg = ITCElg{
+		ITCAvl: []ITC{{Ty: "IMPG"}, {Ty: "IMPS"}, {Ty: "ISRC"}, {Ty: "ISD"}, other},
+		ITCRev: []ITC{{Ty: "RUL"}, {Ty: "OTH"}},
+		ITCNet: ITC{IAmt: other.IAmt, CAmt: other.CAmt, SAmt: other.SAmt},
+	}
+	return r
+}
+
+// NetPayable is outward tax less input tax credit per head. Negative values
+// are credit carried forward.
+func (r *GSTR3B) NetPayable() TaxAmounts {
+	out := r.SupDetails.OSupDet
+	return TaxAmounts{
+		IAmt: round2(out.IAmt - r.ITCElg.ITCNet.IAmt),
+		CAmt: round2(out.CAmt - r.ITCElg.ITCNet.CAmt),
+		SAmt: round2(out.SAmt - r.ITCElg.ITCNet.SAmt),
+	}
+}
+
+// split divides tax into IGST for inter-state supplies, or equal CGST and
+// SGST for intra-state ones.
+func split(tax float64, inter bool) (igst, cgst, sgst float64) {
+	if inter {
+		return tax, 0, 0
+	}
+	cgst = round2(tax / 2)
+	return 0, cgst, tax - cgst
+}
+
+func posOrHome(pos, home string) string {
+	if pos == "" {
+		return home
+	}
+	return pos
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,306 @@
+package gst
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// supplier is a Maharashtra (27) registration.
+const supplier = "27AAPFU0939F1ZV"
+
+var (
+	period  = Period{Year: 2025, Month: time.November}
+	invDate = time.Date(2025, 11, 5, 0, 0, 0, 0, time.UTC)
+)
+
+func line(hsn string, rate, taxable float64) Line {
+	return Line{HSN: hsn, Rate: rate, Quantity: 1, Taxable: taxable, Tax: round2(taxable * rate / 100)}
+}
+
+// invoice is an invoice to customer (empty for unregistered) in state pos.
+func invoice(number, customer, pos string, lines ...Line) Invoice {
+	inv := Invoice{Number: number, Date: invDate, CustomerGSTIN: customer, POS: pos, Lines: lines}
+	for _, l := range lines {
+		inv.Value += l.Taxable + l.Tax
+	}
+	return inv
+}
+
+// sectionOf names the GSTR-1 section holding invoice number.
+func sectionOf(r *GSTR1, number string) string {
+	for _, c := range r.B2B {
+		for _, inv := range c.Inv {
+			if inv.INum == number {
+				return "b2b"
+			}
+		}
+	}
+	for _, p := range r.B2CL {
+		for _, inv := range p.Inv {
+			if inv.INum == number {
+				return "b2cl"
+			}
+		}
+	}
+	if len(r.B2CS) > 0 {
+		return "b2cs"
+	}
+	return ""
+}
+
+func TestBuildGSTR1Sections(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		inv  Invoice
+		want string
+	}{
+		{"registered intra-state", invoice("INV-1", "27AAACR5055K1Z7", "27", line("8471", 18, 1000)), "b2b"},
+		{"registered inter-state", invoice("INV-2", "29AAACR5055K1Z5", "29", line("8471", 18, 500000)), "b2b"},
+		{"unregistered inter-state above threshold", invoice("INV-3", "", "29", line("8471", 18, 100000)), "b2cl"},
+		{"unregistered inter-state at threshold", invoice("INV-4", "", "29", line("0401", 0, 100000)), "b2cs"},
+		{"unregistered inter-state just above threshold", invoice("INV-5", "", "29", line("0401", 0, 100000.01)), "b2cl"},
+		{"unregistered intra-state, any value", invoice("INV-6", "", "27", line("8471", 18, 500000)), "b2cs"},
+		{"no place of supply is intra-state", invoice("INV-7", "", "", line("8471", 18, 500000)), "b2cs"},
+	} {
+		r := BuildGSTR1(supplier, period, []Invoice{tc.inv}, nil)
+		if got := sectionOf(r, tc.inv.Number); got != tc.want {
+			t.Errorf("%s: in %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestBuildGSTR1TaxHeads(t *testing.T) {
+	for _, tc := range []struct {
+		name             string
+		inv              Invoice
+		igst, cgst, sgst float64
+	}{
+		{"b2b intra-state", invoice("INV-1", "27AAACR5055K1Z7", "27", line("8471", 18, 1000)), 0, 90, 90},
+		{"b2b inter-state", invoice("INV-2", "29AAACR5055K1Z5", "29", line("8471", 18, 1000)), 180, 0, 0},
+		{"b2cl", invoice("INV-3", "", "29", line("8471", 28, 200000)), 56000, 0, 0},
+	} {
+		r := BuildGSTR1(supplier, period, []Invoice{tc.inv}, nil)
+		var d ItemDetail
+		switch {
+		case len(r.B2B) == 1:
+			d = r.B2B[0].Inv[0].Itms[0].ItmDet
+		case len(r.B2CL) == 1:
+			d = r.B2CL[0].Inv[0].Itms[0].ItmDet
+		default:
+			t.Fatalf("%s: not in B2B or B2CL", tc.name)
+		}
+		if d.IAmt != tc.igst || d.CAmt != tc.cgst || d.SAmt != tc.sgst {
+			t.Errorf("%s: igst %v cgst %v sgst %v, want %v %v %v", tc.name, d.IAmt, d.CAmt, d.SAmt, tc.igst, tc.cgst, tc.sgst)
+		}
+	}
+
+	r := BuildGSTR1(supplier, period, []Invoice{
+		invoice("INV-4", "", "27", line("8471", 18, 1000)),
+		invoice("INV-5", "", "29", line("8471", 18, 1000)),
+		invoice("INV-6", "", "27", line("8471", 18, 500)),
+	}, nil)
+	want := []B2CSRow{
+		{SplyTy: "INTER", POS: "29", Typ: "OE", Rt: 18, TxVal: 1000, IAmt: 180},
+		{SplyTy: "INTRA", POS: "27", Typ: "OE", Rt: 18, TxVal: 1500, CAmt: 135, SAmt: 135},
+	}
+	if len(r.B2CS) != len(want) {
+		t.Fatalf("B2CS = %+v, want %+v", r.B2CS, want)
+	}
+	for i := range want {
+		if r.B2CS[i] != want[i] {
+			t.Errorf("B2CS[%d] = %+v, want %+v", i, r.B2CS[i], want[i])
+		}
+	}
+}
+
+func TestBuildGSTR1CreditNotes(t *testing.T) {
+	b2b := invoice("INV-1", "29AAACR5055K1Z5", "29", line("8471", 18, 10000))
+	b2cl := invoice("INV-2", "", "29", line("8471", 18, 200000))
+	b2cs := invoice("INV-3", "", "27", line("8471", 18, 1000))
+	note := func(number string, inv Invoice, taxable float64) CreditNote {
+		return CreditNote{Number: number, Date: invDate, Invoice: inv, Taxable: taxable, Rate: 18, Tax: round2(taxable * 0.18)}
+	}
+
+	r := BuildGSTR1(supplier, period, []Invoice{b2b, b2cl, b2cs}, []CreditNote{
+		note("CN-1", b2b, 1000),
+		note("CN-2", b2cl, 5000),
+		note("CN-3", b2cs, 200),
+	})
+
+	if len(r.CDNR) != 1 || r.CDNR[0].CTIN != b2b.CustomerGSTIN || r.CDNR[0].Nt[0].NtNum != "CN-1" {
+		t.Fatalf("CDNR = %+v, want CN-1 under the registered buyer", r.CDNR)
+	}
+	if n := r.CDNR[0].Nt[0]; n.Val != 1180 || n.Itms[0].ItmDet.IAmt != 180 || n.Ntty != "C" {
+		t.Errorf("CDNR note = %+v, want value 1180 with 180 IGST", n)
+	}
+
+	if len(r.CDNUR) != 1 || r.CDNUR[0].NtNum != "CN-2" || r.CDNUR[0].Typ != "B2CL" || r.CDNUR[0].Val != 5900 {
+		t.Errorf("CDNUR = %+v, want CN-2 as B2CL worth 5900", r.CDNUR)
+	}
+
+	// The note against the B2CS sale is netted into its row.
+	want := B2CSRow{SplyTy: "INTRA", POS: "27", Typ: "OE", Rt: 18, TxVal: 800, CAmt: 72, SAmt: 72}
+	if len(r.B2CS) != 1 || r.B2CS[0] != want {
+		t.Errorf("B2CS = %+v, want %+v", r.B2CS, want)
+	}
+}
+
+func TestBuildGSTR1HSNSummary(t *testing.T) {
+	r := BuildGSTR1(supplier, period, []Invoice{
+		invoice("INV-1", "", "27", line("8471", 18, 1000), line("0401", 5, 200)),
+		invoice("INV-2", "", "29", line("8471", 18, 500)),
+	}, nil)
+
+	want := []HSNRow{
+		{Num: 1, HSNSc: "0401", UQC: "NOS", Qty: 1, Val: 210, Rt: 5, TxVal: 200, CAmt: 5, SAmt: 5},
+		{Num: 2, HSNSc: "8471", UQC: "NOS", Qty: 2, Val: 1770, Rt: 18, TxVal: 1500, IAmt: 90, CAmt: 90, SAmt: 90},
+	}
+	if len(r.HSN.Data) != len(want) {
+		t.Fatalf("HSN = %+v, want %+v", r.HSN.Data, want)
+	}
+	for i := range want {
+		if r.HSN.Data[i] != want[i] {
+			t.Errorf("HSN[%d] = %+v, want %+v", i, r.HSN.Data[i], want[i])
+		}
+	}
+}
+
+func TestRateItems(t *testing.T) {
+	items := rateItems([]Line{line("8471", 18, 1000), line("0401", 5, 100), line("8472", 18, 500)}, false)
+	want := []Item{
+		{Num: 501, ItmDet: ItemDetail{TxVal: 100, Rt: 5, CAmt: 2.5, SAmt: 2.5}},
+		{Num: 1801, ItmDet: ItemDetail{TxVal: 1500, Rt: 18, CAmt: 135, SAmt: 135}},
+	}
+	if len(items) != len(want) {
+		t.Fatalf("items = %+v, want %+v", items, want)
+	}
+	for i := range want {
+		if items[i] != want[i] {
+			t.Errorf("item %d = %+v, want %+v", i, items[i], want[i])
+		}
+	}
+	if got := rateItems([]Line{line("0401", 0.25, 1000)}, true); got[0].Num != 26 || got[0].ItmDet.IAmt != 2.5 {
+		t.Errorf("0.25%% item = %+v, want num 26 with 2.5 IGST", got[0])
+	}
+}
+
+func TestSplit(t *testing.T) {
+	for _, tc := range []struct {
+		tax              float64
+		inter            bool
+		igst, cgst, sgst float64
+	}{
+		{180, true, 180, 0, 0},
+		{180, false, 0, 90, 90},
+		{0.05, false, 0, 0.03, 0.02}, // the odd paisa goes to CGST
+		{-36, false, 0, -18, -18},
+		{-36, true, -36, 0, 0},
+	} {
+		i, c, s := split(tc.tax, tc.inter)
+		if i != tc.igst || c != tc.cgst || round2(s) != tc.sgst {
+			t.Errorf("split(%v, %v) = %v, %v, %v; want %v, %v, %v", tc.tax, tc.inter, i, c, s, tc.igst, tc.cgst, tc.sgst)
+		}
+		if round2(i+c+s) != tc.tax {
+			t.Errorf("split(%v, %v) adds up to %v", tc.tax, tc.inter, i+c+s)
+		}
+	}
+}
+
+func TestBuildGSTR3B(t *testing.T) {
+	intra := invoice("INV-1", "", "27", line("8471", 18, 1000), line("0401", 0, 300))
+	inter := invoice("INV-2", "29AAACR5055K1Z5", "29", line("8471", 12, 500))
+	notes := []CreditNote{{Number: "CN-1", Date: invDate, Invoice: intra, Taxable: 200, Rate: 18, Tax: 36}}
+	inputs := []InputTax{{SupplierState: "27", Tax: 50}, {SupplierState: "29", Tax: 30}}
+
+	r := BuildGSTR3B(supplier, period, []Invoice{intra, inter}, notes, inputs)
+
+	if r.GSTIN != supplier || r.RetPeriod != "112025" {
+		t.Errorf("header = %s %s", r.GSTIN, r.RetPeriod)
+	}
+	if want := (TaxAmounts{TxVal: 1300, IAmt: 60, CAmt: 72, SAmt: 72}); r.SupDetails.OSupDet != want {
+		t.Errorf("3.1(a) = %+v, want %+v", r.SupDetails.OSupDet, want)
+	}
+	if want := (TaxAmounts{TxVal: 300}); r.SupDetails.OSupNilExmp != want {
+		t.Errorf("3.1(c) = %+v, want %+v", r.SupDetails.OSupNilExmp, want)
+	}
+	if want := (ITC{IAmt: 30, CAmt: 25, SAmt: 25}); r.ITCElg.ITCNet != want {
+		t.Errorf("net ITC = %+v, want %+v", r.ITCElg.ITCNet, want)
+	}
+	if want := (TaxAmounts{IAmt: 30, CAmt: 47, SAmt: 47}); r.NetPayable() != want {
+		t.Errorf("net payable = %+v, want %+v", r.NetPayable(), want)
+	}
+}
+
+func TestParsePeriod(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want Period
+		ok   bool
+	}{
+		{"2025-11", Period{2025, time.November}, true},
+		{"112025", Period{2025, time.November}, true},
+		{"2025-13", Period{}, false},
+		{"2025-1", Period{}, false},
+		{"", Period{}, false},
+	} {
+		got, err := ParsePeriod(tc.in)
+		if (err == nil) != tc.ok || got != tc.want {
+			t.Errorf("ParsePeriod(%q) = %+v, %v", tc.in, got, err)
+		}
+	}
+	from, to := Period{2025, time.December}.Bounds(time.UTC)
+	if !from.Equal(time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC)) || !to.Equal(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Bounds = %s, %s", from, to)
+	}
+}
+
+func TestWriteGSTR1CSV(t *testing.T) {
+	r := BuildGSTR1(supplier, period, []Invoice{
+		invoice("INV-1", "29AAACR5055K1Z5", "29", line("8471", 18, 1000), line("0401", 5, 200)),
+	}, nil)
+
+	var buf bytes.Buffer
+	if err := WriteGSTR1CSV(&buf, r, "b2b"); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 || rows[0][0] != "GSTIN/UIN of Recipient" {
+		t.Fatalf("rows = %v, want a header and one row per rate", rows)
+	}
+	want := []string{"29AAACR5055K1Z5", "", "INV-1", "05-Nov-2025", "1390.00", "29-Karnataka", "N", "", "Regular B2B", "", "5", "200.00", "0.00"}
+	if strings.Join(rows[1], "|") != strings.Join(want, "|") {
+		t.Errorf("row = %v, want %v", rows[1], want)
+	}
+
+	for _, section := range GSTR1Sections {
+		if err := WriteGSTR1CSV(&bytes.Buffer{}, r, section); err != nil {
+			t.Errorf("section %s: %v", section, err)
+		}
+	}
+	if err := WriteGSTR1CSV(&bytes.Buffer{}, r, "b2ba"); err == nil {
+		t.Error("unknown section accepted")
+	}
+}
+
+func TestWriteGSTR3BCSV(t *testing.T) {
+	r := BuildGSTR3B(supplier, period, []Invoice{invoice("INV-1", "", "27", line("8471", 18, 1000))}, nil,
+		[]InputTax{{SupplierState: "27", Tax: 40}})
+
+	var buf bytes.Buffer
+	if err := WriteGSTR3BCSV(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rows[len(rows)-1]; got[1] != "Net tax payable" || got[4] != "70.00" || got[5] != "70.00" {
+		t.Errorf("last row = %v, want 70.00 CGST and SGST payable", got)
+	}
+}
@@ -0,0 +1,54 @@
+// Package gst builds GST return data (GSTR-1 and GSTR-3B) in the JSON layout
+// of the GSTN offline tool, plus the matching CSV sheets.
+package gst
+
+// States maps GST state codes, the first two digits of a GSTIN, to the state
+// or union territory name used in place-of-supply columns.
+var States = map[string]string{
+	"01": "Jammu and Kashmir",
+	"02": "Himachal Pradesh",
+	"03": "Punjab",
+	"04": "Chandigarh",
+	"05": "Uttarakhand",
+	"06": "Haryana",
+	"07": "Delhi",
+	"08": "Rajasthan",
+	"09": "Uttar Pradesh",
+	"10": "Bihar",
+	"11": "Sikkim",
+	"12": "Arunachal Pradesh",
+	"13": "Nagaland",
+	"14": "Manipur",
+	"15": "Mizoram",
+	"16": "Tripura",
+	"17": "Meghalaya",
+	"18": "Assam",
+	"19": "West Bengal",
+	"20": "Jharkhand",
+	"21": "Odisha",
+	"22": "Chhattisgarh",
+	"23": "Madhya Pradesh",
+	"24": "Gujarat",
+	"26": "Dadra and Nagar Haveli and Daman and Diu",
+	"27": "Maharashtra",
+	"29": "Karnataka",
+	"30": "Goa",
+	"31": "Lakshadweep",
+	"32": "Kerala",
+	"33": "Tamil Nadu",
+	"34": "Puducherry",
+	"35": "Andaman and Nicobar Islands",
+	"36": "Telangana",
+	"37": "Andhra Pradesh",
+	"38": "Ladakh",
+	"97": "Other Territory",
+}
+
+// PlaceOfSupply formats a state code the way the offline tool's CSV sheets
+// expect, e.g. "29-Karnataka".
+func PlaceOfSupply(code string) string {
+	if name, ok := States[code]; ok {
+		return code + "-" + name
+	}
+	return code
+}
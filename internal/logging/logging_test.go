@@ -0,0 +1,63 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"fintech-backend/internal/config"
+	"fintech-backend/internal/reqctx"
+)
+
+func TestRedactionAndContext(t *testing.T) {
+	var buf bytes.Buffer
+	log := New(&buf, config.Log{Level: "info", Format: "json"})
+
+	ctx := reqctx.WithUserID(reqctx.WithRequestID(context.Background(), "req-1"), "user-1")
+	log.InfoContext(ctx, "payout",
+		"api_key", "sk_live_secret",
+		"Authorization", "Bearer abc",
+		"dest_account", "123456789012",
+		"customer_phone", "+919876543210",
+		"amount", 100,
+	)
+	log.DebugContext(ctx, "hidden")
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("want one JSON line, got %q: %v", buf.String(), err)
+	}
+	want := map[string]any{
+		"msg":            "payout",
+		"api_key":        "[redacted]",
+		"Authorization":  "[redacted]",
+		"dest_account":   "********9012",
+		"customer_phone": "*********3210",
+		"amount":         float64(100),
+		"request_id":     "req-1",
+		"user_id":        "user-1",
+	}
+	for k, v := range want {
+		if line[k] != v {
+			t.Errorf("%s = %v, want %v", k, line[k], v)
+		}
+	}
+}
+
+func TestMask(t *testing.T) {
+	for in, want := range map[string]string{"": "", "123": "***", "12345": "*2345"} {
+		if got := Mask(in); got != want {
+			t.Errorf("Mask(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestNewFallsBackToInfo(t *testing.T) {
+	var buf bytes.Buffer
+	log := New(&buf, config.Log{Level: "loud", Format: "text"})
+	if log.Enabled(context.Background(), slog.LevelDebug) || !log.Enabled(context.Background(), slog.LevelInfo) {
+		t.Error("unknown level should mean info")
+	}
+}
@@ -0,0 +1,34 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"fintech-backend/internal/metrics"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// QueryTracer is a pgx.QueryTracer that logs failed statements with the
+// repository method that ran them. The query context carries the request,
+// so each line also has the request ID. Arguments are not logged; they hold
+// customer data.
+type QueryTracer struct{}
+
+func (QueryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, _ pgx.TraceQueryStartData) context.Context {
+	return ctx
+}
+
+func (QueryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	if data.Err == nil || errors.Is(data.Err, context.Canceled) {
+		return
+	}
+	attrs := []any{"method", metrics.RepositoryMethod(), "err", data.Err}
+	var pgErr *pgconn.PgError
+	if errors.As(data.Err, &pgErr) {
+		attrs = append(attrs, "pg_code", pgErr.Code)
+	}
+	slog.WarnContext(ctx, "query failed", attrs...)
+}
@@ -0,0 +1,104 @@
+// Package metrics holds the Prometheus collectors the server exposes on
+// /metrics: HTTP traffic, Postgres pool and query timings, and business
+// counters recorded by the service.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "vantro"
+
+// Registry holds every collector below plus the Go runtime and process
+// collectors.
+var Registry = prometheus.NewRegistry()
+
+// HTTP traffic, labelled by method, route pattern and status code.
+var (
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests served.",
+	}, []string{"method", "route", "status"})
+
+	HTTPDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency.",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 15},
+	}, []string{"method", "route", "status"})
+)
+
+// Repository queries, labelled by the Repository method that ran them.
+var (
+	DBQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Duration of SQL statements, by repository method.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"method"})
+
+	DBQueryErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_query_errors_total",
+		Help:      "SQL statements that returned an error, by repository method.",
+	}, []string{"method"})
+)
+
+// Business events.
+var (
+	InvoicesCreated = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "invoices_created_total",
+		Help:      "Invoices created.",
+	})
+
+	RevenuePaise = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "invoice_revenue_paise_total",
+		Help:      "Invoiced amount in paise, tax included.",
+	})
+
+	// Stockouts counts products a sale emptied (kind sold_out) and invoices
+	// refused for lack of stock (kind rejected).
+	Stockouts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "stockouts_total",
+		Help:      "Products sold out by an invoice, and invoices rejected for insufficient stock.",
+	}, []string{"kind"})
+
+	// Payouts counts payouts entering each status: processing when
+	// created, then success or failed.
+	Payouts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "payouts_total",
+		Help:      "Payouts entering each status.",
+	}, []string{"status"})
+
+	// WebhookFailures counts failed delivery attempts; final is "true" when
+	// the attempt was the last one and the delivery gave up.
+	WebhookFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_delivery_failures_total",
+		Help:      "Failed webhook delivery attempts.",
+	}, []string{"final"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests, HTTPDuration,
+		DBQueryDuration, DBQueryErrors,
+		InvoicesCreated, RevenuePaise, Stockouts, Payouts, WebhookFailures,
+	)
+}
+
+// Handler serves Registry in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
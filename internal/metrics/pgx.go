@@ -0,0 +1,109 @@
+package metrics
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// repoMethodPrefix is how Repository methods appear in stack frames.
+const repoMethodPrefix = "fintech-backend/internal/repository.(*Repository)."
+
+// QueryTracer is a pgx.QueryTracer timing every statement under the
+// Repository method that issued it, found from the call stack, so the
+// repository itself needs no instrumentation.
+type QueryTracer struct{}
+
+type queryKey struct{}
+
+type queryStart struct {
+	method string
+	at     time.Time
+}
+
+func (QueryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, _ pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(ctx, queryKey{}, queryStart{method: RepositoryMethod(), at: time.Now()})
+}
+
+func (QueryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	start, ok := ctx.Value(queryKey{}).(queryStart)
+	if !ok {
+		return
+	}
+	DBQueryDuration.WithLabelValues(start.method).Observe(time.Since(start.at).Seconds())
+	if data.Err != nil {
+		DBQueryErrors.WithLabelValues(start.method).Inc()
+	}
+}
+
+// RepositoryMethod names the innermost Repository method on the calling
+// goroutine's stack, e.g. "CreateInvoiceWithItems", or "other" outside one.
+func RepositoryMethod() string {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	for {
+		f, more := frames.Next()
+		if name, ok := strings.CutPrefix(f.Function, repoMethodPrefix); ok {
+			// Closures inside a method are named Method.func1.
+			name, _, _ = strings.Cut(name, ".")
+			return name
+		}
+		if !more {
+			return "other"
+		}
+	}
+}
+
+// poolCollector reports pgxpool statistics at scrape time.
+type poolCollector struct {
+	pool *pgxpool.Pool
+
+	maxConns, totalConns, idleConns, acquiredConns *prometheus.Desc
+	acquires, emptyAcquires, canceledAcquires      *prometheus.Desc
+	acquireWait                                    *prometheus.Desc
+}
+
+// RegisterPool adds pool's statistics to Registry.
+func RegisterPool(pool *pgxpool.Pool) {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", name), help, nil, nil)
+	}
+	Registry.MustRegister(&poolCollector{
+		pool:             pool,
+		maxConns:         desc("max_conns", "Maximum size of the pool."),
+		totalConns:       desc("total_conns", "Connections currently open."),
+		idleConns:        desc("idle_conns", "Open connections not in use."),
+		acquiredConns:    desc("acquired_conns", "Connections currently in use."),
+		acquires:         desc("acquires_total", "Successful connection acquires."),
+		emptyAcquires:    desc("empty_acquires_total", "Acquires that had to wait for a connection."),
+		canceledAcquires: desc("canceled_acquires_total", "Acquires cancelled by their context."),
+		acquireWait:      desc("acquire_wait_seconds_total", "Total time spent acquiring connections."),
+	})
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.pool.Stat()
+	gauge := func(d *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.GaugeValue, v)
+	}
+	counter := func(d *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.CounterValue, v)
+	}
+	gauge(c.maxConns, float64(s.MaxConns()))
+	gauge(c.totalConns, float64(s.TotalConns()))
+	gauge(c.idleConns, float64(s.IdleConns()))
+	gauge(c.acquiredConns, float64(s.AcquiredConns()))
+	counter(c.acquires, float64(s.AcquireCount()))
+	counter(c.emptyAcquires, float64(s.EmptyAcquireCount()))
+	counter(c.canceledAcquires, float64(s.CanceledAcquireCount()))
+	counter(c.acquireWait, s.AcquireDuration().Seconds())
+}
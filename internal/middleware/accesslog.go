@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"log/slog"
+	"time"
+
+	"fintech-backend/internal/reqctx"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// quietRoutes are probe and scrape endpoints, logged at debug level so they
+// do not drown out real traffic.
+var quietRoutes = map[string]bool{
+	"/livez":   true,
+	"/readyz":  true,
+	"/health":  true,
+	"/metrics": true,
+}
+
+// AccessLog writes one line per request with its route, status, latency and
+// the authenticated user, if any. Like Metrics, it renders errors itself so
+// the logged status is the one sent.
+func AccessLog() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		if err := c.Next(); err != nil {
+			if err := c.App().Config().ErrorHandler(c, err); err != nil {
+				return err
+			}
+		}
+		status := c.Response().StatusCode()
+		route := RouteLabel(c)
+
+		level := slog.LevelInfo
+		switch {
+		case status >= fiber.StatusInternalServerError:
+			level = slog.LevelError
+		case quietRoutes[route]:
+			level = slog.LevelDebug
+		}
+
+		// The request context is done by now, but its values are what the
+		// handlers saw, including the user set by the auth middleware.
+		ctx := c.UserContext()
+		attrs := []slog.Attr{
+			slog.String("method", c.Method()),
+			slog.String("route", route),
+			slog.String("path", c.Path()),
+			slog.Int("status", status),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("ip", c.IP()),
+		}
+		if reqctx.RequestID(ctx) == "" {
+			id, _ := c.Locals("requestid").(string)
+			attrs = append(attrs, slog.String("request_id", id))
+		}
+		slog.LogAttrs(ctx, level, "request", attrs...)
+		return nil
+	}
+}
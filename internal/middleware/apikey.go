@@ -1,19 +1,84 @@
 package middleware
 
 import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"sync"
+
 	"fintech-backend/internal/config"
+	"fintech-backend/internal/repository"
+	"fintech-backend/internal/reqctx"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
 )
 
-func APIKeyAuth(cfg *config.Config) fiber.Handler {
+// callerKeyLocal holds the API key the auth middleware resolved.
+const callerKeyLocal = "callerKey"
+
+// CallerKey returns the authenticated caller's API key: the key it sent, or
+// the key of the user behind a demo token.
+func CallerKey(c *fiber.Ctx) string {
+	key, _ := c.Locals(callerKeyLocal).(string)
+	return key
+}
+
+// UserStore finds the local user behind a credential, signing up the
+// user behind a demo token on first use. *repository.Repository
+// implements it.
+type UserStore interface {
+	EnsureUser(ctx context.Context, email, name string) (*repository.User, error)
+	GetUserByAPIKey(ctx context.Context, apiKey string) (*repository.User, error)
+}
+
+func APIKeyAuth(cfg *config.Config, users UserStore) fiber.Handler {
+	owner := &keyOwner{key: cfg.Auth.APIKey, users: users}
 	return func(c *fiber.Ctx) error {
 		apiKey := c.Get("X-API-Key")
-		if apiKey == "" || apiKey != cfg.APIKey {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "invalid or missing API key",
-			})
+		if apiKey == "" || subtle.ConstantTimeCompare([]byte(apiKey), []byte(cfg.Auth.APIKey)) != 1 {
+			return fiber.NewError(fiber.StatusUnauthorized, "invalid or missing API key")
 		}
+		c.Locals(callerKeyLocal, apiKey)
+		c.SetUserContext(owner.withUserID(c.UserContext()))
 		return c.Next()
 	}
 }
+
+// keyOwner looks up the user the shared API key belongs to, so requests
+// made with it are logged and limited per user like token requests. The
+// answer is kept once known, including "nobody"; a failed lookup is retried
+// on the next request.
+type keyOwner struct {
+	key   string
+	users UserStore
+
+	mu     sync.Mutex
+	known  bool
+	userID string
+}
+
+func (o *keyOwner) withUserID(ctx context.Context) context.Context {
+	o.mu.Lock()
+	known, userID := o.known, o.userID
+	o.mu.Unlock()
+
+	if !known {
+		u, err := o.users.GetUserByAPIKey(ctx, o.key)
+		switch {
+		case err == nil:
+			known, userID = true, u.ID.String()
+		case errors.Is(err, pgx.ErrNoRows):
+			known = true
+		}
+		if known {
+			o.mu.Lock()
+			o.known, o.userID = true, userID
+			o.mu.Unlock()
+		}
+	}
+	if userID == "" {
+		return ctx
+	}
+	return reqctx.WithUserID(ctx, userID)
+}
@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"fintech-backend/internal/config"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// BearerAuth protects the public /v1 API, which authenticates with
+// "Authorization: Bearer sk_..." instead of the X-API-Key header.
+func BearerAuth(cfg *config.Config, users UserStore) fiber.Handler {
+	owner := &keyOwner{key: cfg.Auth.APIKey, users: users}
+	return func(c *fiber.Ctx) error {
+		token, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(cfg.Auth.APIKey)) != 1 {
+			return fiber.NewError(fiber.StatusUnauthorized, "invalid or missing bearer token")
+		}
+		c.Locals(callerKeyLocal, token)
+		c.SetUserContext(owner.withUserID(c.UserContext()))
+		return c.Next()
+	}
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"context"
+	"time"
+
+	"fintech-backend/internal/reqctx"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequestContext gives each request a context derived from base that carries
+// the request ID and is cancelled after timeout or when base is (server
+// shutdown). Handlers pass c.UserContext() down so queries stop with it.
+//
+// It is not cancelled when the client disconnects: fasthttp gives handlers
+// no notice of a closed connection, so work for a client that has gone away
+// runs on until it finishes or the timeout fires. Keep REQUEST_TIMEOUT
+// short enough to bound that waste.
+func RequestContext(base context.Context, timeout time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, cancel := context.WithTimeout(base, timeout)
+		defer cancel()
+		if id, ok := c.Locals("requestid").(string); ok {
+			ctx = reqctx.WithRequestID(ctx, id)
+		}
+		c.SetUserContext(ctx)
+		return c.Next()
+	}
+}
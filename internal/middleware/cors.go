@@ -1,18 +1,112 @@
 package middleware
 
-import "github.com/gofiber/fiber/v2"
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"fintech-backend/internal/config"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	corsAllowMethods = "GET,POST,PATCH,PUT,DELETE,OPTIONS"
+	corsAllowHeaders = "Origin, Content-Type, Accept, X-API-Key, Authorization, Idempotency-Key, X-Request-ID, traceparent, tracestate"
+)
+
+// corsExposeHeaders are the response headers browser code may read.
+var corsExposeHeaders = strings.Join([]string{
+	HeaderRequestID,
+	HeaderRateLimitLimit,
+	HeaderRateLimitRemaining,
+	HeaderRateLimitReset,
+	HeaderRateLimitPolicy,
+	fiber.HeaderRetryAfter,
+	HeaderReplayed,
+}, ", ")
+
+// CORS lets browsers on cfg.CORSOrigins call the API. An entry is an exact
+// origin, a wildcard such as https://*.example.com matching any subdomain
+// (not the apex), or "*" for any origin. Requests from other origins get no
+// CORS headers, so the browser blocks them. Preflight requests are answered
+// here and may be cached for cfg.CORSMaxAge.
+func CORS(cfg config.HTTP) fiber.Handler {
+	var (
+		anyOrigin bool
+		exact     = map[string]bool{}
+		suffixes  []originSuffix
+	)
+	for _, o := range cfg.CORSOrigins {
+		switch {
+		case o == "*":
+			anyOrigin = true
+		case strings.Contains(o, "://*."):
+			scheme, host, _ := strings.Cut(o, "://*")
+			suffixes = append(suffixes, originSuffix{scheme: scheme + "://", suffix: strings.ToLower(host)})
+		default:
+			exact[strings.ToLower(o)] = true
+		}
+	}
+	allowed := func(origin string) bool {
+		if anyOrigin || exact[strings.ToLower(origin)] {
+			return true
+		}
+		for _, s := range suffixes {
+			if s.match(origin) {
+				return true
+			}
+		}
+		return false
+	}
+	maxAge := strconv.Itoa(int(cfg.CORSMaxAge.Seconds()))
 
-// CORS enables basic CORS support for frontend clients.
-func CORS() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		c.Set("Access-Control-Allow-Origin", "*")
-		c.Set("Access-Control-Allow-Methods", "GET,POST,PATCH,PUT,DELETE,OPTIONS")
-		c.Set("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, X-API-Key, Authorization")
+		// The answer depends on Origin unless every origin gets "*".
+		if !anyOrigin || cfg.CORSAllowCredentials {
+			c.Vary(fiber.HeaderOrigin)
+		}
+		origin := c.Get(fiber.HeaderOrigin)
+		preflight := c.Method() == fiber.MethodOptions && c.Get(fiber.HeaderAccessControlRequestMethod) != ""
+		if origin == "" || !allowed(origin) {
+			if preflight {
+				return c.SendStatus(fiber.StatusNoContent)
+			}
+			return c.Next()
+		}
 
-		if c.Method() == fiber.MethodOptions {
-			return c.SendStatus(fiber.StatusNoContent)
+		if anyOrigin && !cfg.CORSAllowCredentials {
+			c.Set(fiber.HeaderAccessControlAllowOrigin, "*")
+		} else {
+			c.Set(fiber.HeaderAccessControlAllowOrigin, origin)
+		}
+		if cfg.CORSAllowCredentials {
+			c.Set(fiber.HeaderAccessControlAllowCredentials, "true")
 		}
 
+		if preflight {
+			c.Set(fiber.HeaderAccessControlAllowMethods, corsAllowMethods)
+			c.Set(fiber.HeaderAccessControlAllowHeaders, corsAllowHeaders)
+			c.Set(fiber.HeaderAccessControlMaxAge, maxAge)
+			return c.SendStatus(fiber.StatusNoContent)
+		}
+		c.Set(fiber.HeaderAccessControlExposeHeaders, corsExposeHeaders)
 		return c.Next()
 	}
 }
+
+// originSuffix matches origins on any subdomain of a host, e.g. scheme
+// "https://" and suffix ".example.com", keeping the port if one was given.
+type originSuffix struct {
+	scheme string
+	suffix string
+}
+
+func (s originSuffix) match(origin string) bool {
+	u, err := url.Parse(origin)
+	if err != nil || u.Scheme+"://" != s.scheme {
+		return false
+	}
+	host := strings.ToLower(u.Host)
+	return strings.HasSuffix(host, s.suffix) && len(host) > len(s.suffix)
+}
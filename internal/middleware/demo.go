@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"strings"
+
+	"fintech-backend/internal/auth"
+	"fintech-backend/internal/reqctx"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// DemoTokenAuth accepts "Authorization: Bearer demo_..." tokens from the dev
+// demo page and acts as their user, creating it on first use; any other
+// request is passed to next. Register it only in dev.
+func DemoTokenAuth(tokens *auth.DemoTokens, users UserStore, next fiber.Handler) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		token, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+		if !ok || !auth.IsDemo(token) {
+			return next(c)
+		}
+		email, err := tokens.Verify(token)
+		if err != nil {
+			return fiber.NewError(fiber.StatusUnauthorized, "invalid or expired demo token")
+		}
+		ctx := c.UserContext()
+		user, err := users.EnsureUser(ctx, email, "Demo")
+		if err != nil {
+			return err
+		}
+		c.Locals(callerKeyLocal, user.APIKey)
+		c.SetUserContext(reqctx.WithUserID(ctx, user.ID.String()))
+		return c.Next()
+	}
+}
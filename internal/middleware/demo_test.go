@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"fintech-backend/internal/auth"
+	"fintech-backend/internal/config"
+	"fintech-backend/internal/repository"
+	"fintech-backend/internal/reqctx"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// seededUsers stands in for the repository after `vantro seed`: it knows
+// only the demo user, under its own generated api_key.
+type seededUsers struct{ user repository.User }
+
+func (s *seededUsers) EnsureUser(_ context.Context, email, _ string) (*repository.User, error) {
+	if email != s.user.Email {
+		return &repository.User{ID: uuid.New(), Email: email}, nil
+	}
+	u := s.user
+	return &u, nil
+}
+
+func (s *seededUsers) GetUserByAPIKey(_ context.Context, key string) (*repository.User, error) {
+	if key != s.user.APIKey {
+		return nil, pgx.ErrNoRows
+	}
+	u := s.user
+	return &u, nil
+}
+
+func TestSeededUserAuthenticates(t *testing.T) {
+	users := &seededUsers{user: repository.User{ID: uuid.New(), Email: auth.DemoEmail, APIKey: "sk_generated"}}
+	tokens := auth.NewDemoTokens(time.Minute)
+	cfg := &config.Config{Auth: config.Auth{APIKey: "sk_server"}}
+
+	// The /api chain router.New builds in dev.
+	app := fiber.New()
+	app.Get("/api/shops", DemoTokenAuth(tokens, users, APIKeyAuth(cfg, users)), func(c *fiber.Ctx) error {
+		return c.SendString(reqctx.UserID(c.UserContext()))
+	})
+
+	token, _ := tokens.Issue(auth.DemoEmail)
+	for _, tc := range []struct {
+		name, header, value string
+		status              int
+		userID              string
+	}{
+		{"token from /dev/token", fiber.HeaderAuthorization, "Bearer " + token, fiber.StatusOK, users.user.ID.String()},
+		{"server API key", "X-API-Key", cfg.Auth.APIKey, fiber.StatusOK, ""},
+		{"user's own api_key", "X-API-Key", users.user.APIKey, fiber.StatusUnauthorized, ""},
+		{"forged token", fiber.HeaderAuthorization, "Bearer " + token + "x", fiber.StatusUnauthorized, ""},
+	} {
+		req := httptest.NewRequest("GET", "/api/shops", nil)
+		req.Header.Set(tc.header, tc.value)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tc.status {
+			t.Errorf("%s: status %d, want %d", tc.name, resp.StatusCode, tc.status)
+			continue
+		}
+		if tc.status != fiber.StatusOK {
+			continue
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if got := string(body); got != tc.userID {
+			t.Errorf("%s: acting as %q, want %q", tc.name, got, tc.userID)
+		}
+	}
+}
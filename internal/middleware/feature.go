@@ -0,0 +1,13 @@
+package middleware
+
+import "github.com/gofiber/fiber/v2"
+
+// Feature answers 404 for every route it guards while the feature is off.
+func Feature(name string, enabled bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !enabled {
+			return fiber.NewError(fiber.StatusNotFound, name+" is disabled")
+		}
+		return c.Next()
+	}
+}
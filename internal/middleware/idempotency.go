@@ -0,0 +1,138 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"time"
+
+	"fintech-backend/internal/repository"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	HeaderIdempotencyKey = "Idempotency-Key"
+	HeaderReplayed       = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLen = 255
+
+	// idempotencyStoreTimeout bounds saving or releasing a key once the
+	// handler is done. It does not depend on the request context, whose
+	// deadline may already have passed.
+	idempotencyStoreTimeout = 5 * time.Second
+)
+
+// IdempotencyStore persists responses keyed by caller and Idempotency-Key.
+// *repository.Repository implements it.
+type IdempotencyStore interface {
+	ReserveIdempotencyKey(ctx context.Context, scope, key, requestHash string, ttl, lease time.Duration) (*repository.StoredResponse, bool, error)
+	SaveIdempotentResponse(ctx context.Context, scope, key string, statusCode int, contentType string, body []byte) error
+	ReleaseIdempotencyKey(ctx context.Context, scope, key string) error
+}
+
+// Idempotency makes POST, PUT and PATCH requests that carry an
+// Idempotency-Key header safe to retry. The first request runs normally and
+// its response is stored for ttl; a repeat with the same key and body gets
+// the stored response back with Idempotent-Replayed: true. Reusing a key with
+// a different request, or while the first one is still running, is a 409.
+// Server errors are not stored so the client can retry them.
+//
+// A request holds its key for requestTimeout, the longest it can run, plus
+// the time to store its response. If the server dies before then, a retry
+// after the lease takes the key over instead of getting 409 until ttl.
+//
+// Keys are scoped per caller by hashing the credential, so it must run after
+// the auth middleware.
+func Idempotency(store IdempotencyStore, ttl, requestTimeout time.Duration) fiber.Handler {
+	lease := requestTimeout + idempotencyStoreTimeout
+	return func(c *fiber.Ctx) error {
+		switch c.Method() {
+		case fiber.MethodPost, fiber.MethodPut, fiber.MethodPatch:
+		default:
+			return c.Next()
+		}
+		key := c.Get(HeaderIdempotencyKey)
+		if key == "" {
+			return c.Next()
+		}
+		if len(key) > maxIdempotencyKeyLen {
+			return fiber.NewError(fiber.StatusBadRequest, "Idempotency-Key must be at most 255 characters")
+		}
+
+		scope := credentialHash(c)
+		hash := requestHash(c)
+		ctx := c.UserContext()
+
+		stored, reserved, err := store.ReserveIdempotencyKey(ctx, scope, key, hash, ttl, lease)
+		if err != nil {
+			return err
+		}
+		if !reserved {
+			switch {
+			case stored.RequestHash != hash:
+				return fiber.NewError(fiber.StatusConflict, "Idempotency-Key was already used with a different request")
+			case !stored.Done:
+				return fiber.NewError(fiber.StatusConflict, "a request with this Idempotency-Key is still in progress")
+			}
+			c.Set(HeaderReplayed, "true")
+			if stored.ContentType != "" {
+				c.Set(fiber.HeaderContentType, stored.ContentType)
+			}
+			return c.Status(stored.StatusCode).Send(stored.Body)
+		}
+
+		err = c.Next()
+
+		// The request context may have timed out or the client gone away;
+		// the key must still be saved or released.
+		sctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), idempotencyStoreTimeout)
+		defer cancel()
+		release := func() {
+			if err := store.ReleaseIdempotencyKey(sctx, scope, key); err != nil {
+				slog.ErrorContext(sctx, "idempotency: release", "key", key, "err", err)
+			}
+		}
+
+		if err != nil {
+			// Render the error now so a 4xx is stored and replayed like any
+			// other response.
+			if herr := c.App().Config().ErrorHandler(c, err); herr != nil {
+				release()
+				return herr
+			}
+		}
+
+		status := c.Response().StatusCode()
+		if status >= fiber.StatusInternalServerError {
+			release()
+			return nil
+		}
+		body := append([]byte(nil), c.Response().Body()...)
+		contentType := string(c.Response().Header.ContentType())
+		if err := store.SaveIdempotentResponse(sctx, scope, key, status, contentType, body); err != nil {
+			slog.ErrorContext(sctx, "idempotency: save", "key", key, "err", err)
+		}
+		return nil
+	}
+}
+
+// credentialHash identifies the caller by whichever credential authenticated
+// the request, without storing the credential itself.
+func credentialHash(c *fiber.Ctx) string {
+	sum := sha256.Sum256([]byte(CallerKey(c)))
+	return hex.EncodeToString(sum[:])
+}
+
+// requestHash covers the method, path and body so a key cannot be reused for
+// a different operation.
+func requestHash(c *fiber.Ctx) string {
+	h := sha256.New()
+	h.Write([]byte(c.Method()))
+	h.Write([]byte{0})
+	h.Write([]byte(c.Path()))
+	h.Write([]byte{0})
+	h.Write(c.Body())
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,208 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"fintech-backend/internal/repository"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// memIdempotency is an IdempotencyStore in memory. It fails writes on a done
+// context, as the database would.
+type memIdempotency struct {
+	mu       sync.Mutex
+	rows     map[string]*repository.StoredResponse
+	released int
+}
+
+func newMemIdempotency() *memIdempotency {
+	return &memIdempotency{rows: map[string]*repository.StoredResponse{}}
+}
+
+func (m *memIdempotency) ReserveIdempotencyKey(ctx context.Context, scope, key, requestHash string, ttl, lease time.Duration) (*repository.StoredResponse, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if row, ok := m.rows[scope+"/"+key]; ok {
+		cp := *row
+		return &cp, false, nil
+	}
+	m.rows[scope+"/"+key] = &repository.StoredResponse{RequestHash: requestHash}
+	return nil, true, nil
+}
+
+func (m *memIdempotency) SaveIdempotentResponse(ctx context.Context, scope, key string, statusCode int, contentType string, body []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	row := m.rows[scope+"/"+key]
+	row.Done, row.StatusCode, row.ContentType, row.Body = true, statusCode, contentType, body
+	return nil
+}
+
+func (m *memIdempotency) ReleaseIdempotencyKey(ctx context.Context, scope, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if row, ok := m.rows[scope+"/"+key]; ok && !row.Done {
+		delete(m.rows, scope+"/"+key)
+		m.released++
+	}
+	return nil
+}
+
+func (m *memIdempotency) size() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.rows)
+}
+
+// idempotencyApp serves POST /things through Idempotency with handler. The
+// request context's cancel func is in the "cancel" local.
+func idempotencyApp(store IdempotencyStore, handler fiber.Handler) *fiber.App {
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals(callerKeyLocal, "sk_test")
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		c.Locals("cancel", cancel)
+		c.SetUserContext(ctx)
+		return c.Next()
+	})
+	app.Use(Idempotency(store, time.Hour, time.Second))
+	app.Post("/things", handler)
+	return app
+}
+
+func postThing(t *testing.T, app *fiber.App, key, body string) (int, string, string) {
+	t.Helper()
+	req := httptest.NewRequest(fiber.MethodPost, "/things", strings.NewReader(body))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	if key != "" {
+		req.Header.Set(HeaderIdempotencyKey, key)
+	}
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, resp.Header.Get(HeaderReplayed), string(b)
+}
+
+func TestIdempotencyReplaysStoredResponse(t *testing.T) {
+	store := newMemIdempotency()
+	calls := 0
+	app := idempotencyApp(store, func(c *fiber.Ctx) error {
+		calls++
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{"call": calls})
+	})
+
+	status, replayed, body := postThing(t, app, "k1", `{"a":1}`)
+	if status != fiber.StatusCreated || replayed != "" || body != `{"call":1}` {
+		t.Fatalf("first: %d %q %s", status, replayed, body)
+	}
+	status, replayed, body = postThing(t, app, "k1", `{"a":1}`)
+	if status != fiber.StatusCreated || replayed != "true" || body != `{"call":1}` {
+		t.Fatalf("replay: %d %q %s, want the stored 201", status, replayed, body)
+	}
+	if calls != 1 {
+		t.Errorf("handler ran %d times, want 1", calls)
+	}
+
+	if postThing(t, app, "", `{"a":1}`); calls != 2 {
+		t.Errorf("request without a key was not run")
+	}
+}
+
+func TestIdempotencyRejectsDifferentRequest(t *testing.T) {
+	store := newMemIdempotency()
+	app := idempotencyApp(store, func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusCreated)
+	})
+
+	postThing(t, app, "k1", `{"a":1}`)
+	if status, _, body := postThing(t, app, "k1", `{"a":2}`); status != fiber.StatusConflict || !strings.Contains(body, "different request") {
+		t.Errorf("reused key with another body: %d %s, want 409", status, body)
+	}
+}
+
+func TestIdempotencyRejectsConcurrentUse(t *testing.T) {
+	store := newMemIdempotency()
+	started, finish := make(chan struct{}), make(chan struct{})
+	app := idempotencyApp(store, func(c *fiber.Ctx) error {
+		close(started)
+		<-finish
+		return c.SendStatus(fiber.StatusCreated)
+	})
+
+	done := make(chan int)
+	go func() {
+		status, _, _ := postThing(t, app, "k1", `{}`)
+		done <- status
+	}()
+	<-started
+	if status, _, body := postThing(t, app, "k1", `{}`); status != fiber.StatusConflict || !strings.Contains(body, "in progress") {
+		t.Errorf("concurrent request: %d %s, want 409 in progress", status, body)
+	}
+	close(finish)
+	if status := <-done; status != fiber.StatusCreated {
+		t.Errorf("first request: %d, want 201", status)
+	}
+}
+
+func TestIdempotencyReleasesKeyOnServerError(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		handler fiber.Handler
+	}{
+		{"error", func(c *fiber.Ctx) error { return errors.New("boom") }},
+		{"status", func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusBadGateway) }},
+		{"timeout", func(c *fiber.Ctx) error {
+			// The request context is done by the time the middleware
+			// releases the key.
+			c.Locals("cancel").(context.CancelFunc)()
+			return fiber.NewError(fiber.StatusGatewayTimeout, "timed out")
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			store := newMemIdempotency()
+			app := idempotencyApp(store, tc.handler)
+
+			if status, _, _ := postThing(t, app, "k1", `{}`); status < 500 {
+				t.Fatalf("status %d, want a server error", status)
+			}
+			if store.released != 1 || store.size() != 0 {
+				t.Errorf("released %d, %d keys left; want the key released", store.released, store.size())
+			}
+		})
+	}
+}
+
+func TestIdempotencyStoresClientErrors(t *testing.T) {
+	store := newMemIdempotency()
+	calls := 0
+	app := idempotencyApp(store, func(c *fiber.Ctx) error {
+		calls++
+		return fiber.NewError(fiber.StatusUnprocessableEntity, "bad amount")
+	})
+
+	postThing(t, app, "k1", `{}`)
+	status, replayed, _ := postThing(t, app, "k1", `{}`)
+	if status != fiber.StatusUnprocessableEntity || replayed != "true" || calls != 1 {
+		t.Errorf("replayed 4xx: %d %q after %d calls, want stored 422", status, replayed, calls)
+	}
+}
@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"strconv"
+	"strings"
+	"time"
+
+	"fintech-backend/internal/metrics"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+const unmatchedLocal = "unmatched"
+
+// NotFound answers requests no route matched. Register it after every
+// route, so RouteLabel can tell them apart from a handler's 404.
+func NotFound(c *fiber.Ctx) error {
+	c.Locals(unmatchedLocal, true)
+	return fiber.NewError(fiber.StatusNotFound, "Cannot "+c.Method()+" "+c.Path())
+}
+
+// RouteLabel is the pattern of the route that handled the request, e.g.
+// /api/shops/:shopId, or "unmatched", so labels stay bounded whatever paths
+// clients send.
+func RouteLabel(c *fiber.Ctx) string {
+	if unmatched, _ := c.Locals(unmatchedLocal).(bool); unmatched {
+		return "unmatched"
+	}
+	return c.Route().Path
+}
+
+// Metrics records every request's count and latency by method, route and
+// status. Errors are rendered here, through the app's error handler, so the
+// recorded status is the one sent; it must run before any middleware that
+// relies on errors reaching the error handler unrendered.
+func Metrics() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		if err := c.Next(); err != nil {
+			if err := c.App().Config().ErrorHandler(c, err); err != nil {
+				return err
+			}
+		}
+		// The registry keeps label values, so the method is copied out of
+		// fasthttp's reused request buffer.
+		labels := []string{utils.CopyString(c.Method()), RouteLabel(c), strconv.Itoa(c.Response().StatusCode())}
+		metrics.HTTPRequests.WithLabelValues(labels...).Inc()
+		metrics.HTTPDuration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+		return nil
+	}
+}
+
+// MetricsAuth guards /metrics with a bearer token; an empty token leaves it
+// open, for scrapers on a private network.
+func MetricsAuth(token string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if token == "" {
+			return c.Next()
+		}
+		got, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			return fiber.NewError(fiber.StatusUnauthorized, "invalid or missing metrics token")
+		}
+		return c.Next()
+	}
+}
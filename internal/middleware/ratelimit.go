@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"log/slog"
+	"math"
+	"strconv"
+	"time"
+
+	"fintech-backend/internal/config"
+	"fintech-backend/internal/ratelimit"
+	"fintech-backend/internal/reqctx"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	HeaderRateLimitLimit     = "RateLimit-Limit"
+	HeaderRateLimitRemaining = "RateLimit-Remaining"
+	HeaderRateLimitReset     = "RateLimit-Reset"
+	HeaderRateLimitPolicy    = "RateLimit-Policy"
+)
+
+// RateLimit spends a token from the caller's read or write bucket on every
+// request, answering 429 with Retry-After once it is empty. Callers are
+// told apart by user, then by API key, then by client IP, so it must run
+// after the auth middleware. Every response carries the bucket's state in
+// RateLimit-* headers. If the store fails the request is let through: a
+// database hiccup should not take the API down with it.
+func RateLimit(cfg config.RateLimit, store ratelimit.Store) fiber.Handler {
+	if !cfg.Enabled {
+		return func(c *fiber.Ctx) error { return c.Next() }
+	}
+	reads := newRateClass("read", cfg.ReadsPerMinute, cfg.ReadBurst)
+	writes := newRateClass("write", cfg.WritesPerMinute, cfg.WriteBurst)
+
+	return func(c *fiber.Ctx) error {
+		cl := reads
+		if isWrite(c) {
+			cl = writes
+		}
+		return cl.take(c, store, rateLimitKey(c))
+	}
+}
+
+// IPRateLimit spends a token from the client IP's bucket before the caller
+// is authenticated, so requests with bad or guessed credentials are limited
+// too; RateLimit cannot see them, as auth rejects them first. The bucket is
+// sized for everyone behind one address, above any single caller's.
+func IPRateLimit(cfg config.RateLimit, store ratelimit.Store) fiber.Handler {
+	if !cfg.Enabled {
+		return func(c *fiber.Ctx) error { return c.Next() }
+	}
+	cl := newRateClass("ip", cfg.IPPerMinute, cfg.IPBurst)
+	return func(c *fiber.Ctx) error {
+		return cl.take(c, store, c.IP())
+	}
+}
+
+type rateClass struct {
+	name   string
+	limit  ratelimit.Limit
+	policy string
+}
+
+func newRateClass(name string, perMinute, burst int) rateClass {
+	return rateClass{
+		name:   name,
+		limit:  ratelimit.PerMinute(perMinute, burst),
+		policy: strconv.Itoa(perMinute) + ";w=60;burst=" + strconv.Itoa(burst),
+	}
+}
+
+// take spends a token from the class's bucket for key and sets the
+// RateLimit-* headers, replacing those of any bucket taken from before.
+func (cl rateClass) take(c *fiber.Ctx, store ratelimit.Store, key string) error {
+	ctx := c.UserContext()
+	res, err := store.Take(ctx, cl.name+":"+key, cl.limit)
+	if err != nil {
+		slog.ErrorContext(ctx, "ratelimit: take", "err", err)
+		return c.Next()
+	}
+
+	c.Set(HeaderRateLimitLimit, strconv.Itoa(res.Limit))
+	c.Set(HeaderRateLimitRemaining, strconv.Itoa(res.Remaining))
+	c.Set(HeaderRateLimitReset, strconv.Itoa(seconds(res.Reset)))
+	c.Set(HeaderRateLimitPolicy, cl.policy)
+	if !res.Allowed {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(max(seconds(res.RetryAfter), 1)))
+		return fiber.NewError(fiber.StatusTooManyRequests, "rate limit exceeded, retry later")
+	}
+	return c.Next()
+}
+
+// rateLimitKey identifies the caller: the authenticated user, else the API
+// key (hashed, so it is not kept in the store), else the client IP.
+func rateLimitKey(c *fiber.Ctx) string {
+	if id := reqctx.UserID(c.UserContext()); id != "" {
+		return "user:" + id
+	}
+	if CallerKey(c) != "" {
+		return "key:" + credentialHash(c)
+	}
+	return "ip:" + c.IP()
+}
+
+// seconds rounds d up to whole seconds, as the headers carry.
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+func isWrite(c *fiber.Ctx) bool {
+	switch c.Method() {
+	case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"fintech-backend/internal/config"
+	"fintech-backend/internal/ratelimit"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestIPRateLimitRunsBeforeAuth(t *testing.T) {
+	cfg := config.RateLimit{Enabled: true, ReadsPerMinute: 60, ReadBurst: 10, WritesPerMinute: 60, WriteBurst: 10, IPPerMinute: 1, IPBurst: 2}
+	store := ratelimit.NewMemoryStore()
+	app := fiber.New()
+	rejectAll := func(c *fiber.Ctx) error { return fiber.ErrUnauthorized }
+	app.Get("/api/shops", IPRateLimit(cfg, store), rejectAll, RateLimit(cfg, store))
+
+	guess := func() int {
+		req := httptest.NewRequest("GET", "/api/shops", nil)
+		req.Header.Set("X-API-Key", "guessed")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode
+	}
+	for i := 0; i < cfg.IPBurst; i++ {
+		if code := guess(); code != fiber.StatusUnauthorized {
+			t.Fatalf("guess %d = %d, want 401", i+1, code)
+		}
+	}
+	if code := guess(); code != fiber.StatusTooManyRequests {
+		t.Errorf("guess past the IP burst = %d, want 429", code)
+	}
+}
+
+func TestIPRateLimitDisabled(t *testing.T) {
+	app := fiber.New()
+	app.Get("/", IPRateLimit(config.RateLimit{}, ratelimit.NewMemoryStore()), func(c *fiber.Ctx) error { return nil })
+	for i := 0; i < 3; i++ {
+		resp, err := app.Test(httptest.NewRequest("GET", "/", nil))
+		if err != nil || resp.StatusCode != fiber.StatusOK || resp.Header.Get(HeaderRateLimitLimit) != "" {
+			t.Fatalf("disabled limiter: %v %v", resp, err)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"regexp"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/google/uuid"
+)
+
+// HeaderRequestID carries the request ID in both directions.
+const HeaderRequestID = "X-Request-ID"
+
+// requestIDPattern bounds IDs accepted from clients, so a caller cannot put
+// arbitrary text into logs.
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestID keeps the caller's X-Request-ID when it is well formed, so a
+// request can be followed from a proxy or client through our logs, and
+// generates one otherwise. The ID is echoed in the response and stored in
+// Locals("requestid") for RequestContext and the error handler.
+func RequestID() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		// Copied: the ID travels on the request context into spans, which
+		// outlive fasthttp's request buffer.
+		id := utils.CopyString(c.Get(HeaderRequestID))
+		if !requestIDPattern.MatchString(id) {
+			id = uuid.NewString()
+		}
+		c.Set(HeaderRequestID, id)
+		c.Locals("requestid", id)
+		return c.Next()
+	}
+}
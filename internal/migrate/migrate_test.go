@@ -0,0 +1,43 @@
+package migrate
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"fintech-backend/migrations"
+)
+
+func TestLoadEmbedded(t *testing.T) {
+	ms, err := Load(migrations.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ms) == 0 {
+		t.Fatal("no migrations embedded")
+	}
+	for i, m := range ms {
+		if m.Version != i+1 {
+			t.Errorf("migration %d has version %d, want consecutive versions from 1", i, m.Version)
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+	}{
+		{"missing down", fstest.MapFS{
+			"0001_a.up.sql": {Data: []byte("SELECT 1;")},
+		}},
+		{"two names", fstest.MapFS{
+			"0001_a.up.sql":   {Data: []byte("SELECT 1;")},
+			"0001_b.down.sql": {Data: []byte("SELECT 1;")},
+		}},
+	}
+	for _, tt := range tests {
+		if _, err := Load(tt.fsys); err == nil {
+			t.Errorf("%s: Load succeeded, want an error", tt.name)
+		}
+	}
+}
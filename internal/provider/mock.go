@@ -0,0 +1,110 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"time"
+)
+
+// Sandbox error codes.
+const (
+	ErrCodeBankDown       = "BENEFICIARY_BANK_DOWN"
+	ErrCodeInvalidAccount = "INVALID_BENEFICIARY"
+	ErrCodeInsufficient   = "INSUFFICIENT_BALANCE"
+	ErrCodeCancelled      = "CANCELLED"
+)
+
+// ErrNotCancellable is returned when cancelling a payout that already resolved.
+var ErrNotCancellable = errors.New("payout can no longer be cancelled")
+
+// successRate is the share of ordinary sandbox payouts that succeed.
+const successRate = 92
+
+// Mock is the sandbox provider. Every payout starts as processing and
+// resolves once Delay has passed since creation. The outcome is derived from
+// the payout itself, so it survives restarts and is stable across calls:
+//
+//	VPA success@sandbox          -> success
+//	VPA failure@sandbox          -> failed, INVALID_BENEFICIARY
+//	VPA pending@sandbox          -> stays processing
+//	amount 1.01 (101 paise)      -> failed, BENEFICIARY_BANK_DOWN
+//	amount 1.02 (102 paise)      -> failed, INVALID_BENEFICIARY
+//	amount 1.03 (103 paise)      -> failed, INSUFFICIENT_BALANCE
+//	amount 1.04 (104 paise)      -> stays processing
+//	anything else                -> success ~92% of the time, keyed on the id
+type Mock struct {
+	Delay time.Duration
+	now   func() time.Time
+}
+
+func NewMock(delay time.Duration) *Mock {
+	return &Mock{Delay: delay, now: time.Now}
+}
+
+func (m *Mock) Name() string { return "mock" }
+
+func (m *Mock) Initiate(_ context.Context, p Payout) (Result, error) {
+	if p.AmountCents <= 0 {
+		return Result{}, fmt.Errorf("mock: invalid amount %d", p.AmountCents)
+	}
+	return Result{Status: StatusProcessing}, nil
+}
+
+func (m *Mock) Status(_ context.Context, p Payout) (Result, error) {
+	if m.now().Sub(p.CreatedAt) < m.Delay {
+		return Result{Status: StatusProcessing}, nil
+	}
+	return outcome(p), nil
+}
+
+func (m *Mock) Cancel(ctx context.Context, p Payout) (Result, error) {
+	res, err := m.Status(ctx, p)
+	if err != nil {
+		return Result{}, err
+	}
+	if res.Status != StatusProcessing {
+		return res, ErrNotCancellable
+	}
+	return Result{Status: StatusFailed, ErrorCode: ErrCodeCancelled}, nil
+}
+
+func outcome(p Payout) Result {
+	switch strings.ToLower(p.VPA) {
+	case "success@sandbox":
+		return success(p)
+	case "failure@sandbox":
+		return Result{Status: StatusFailed, ErrorCode: ErrCodeInvalidAccount}
+	case "pending@sandbox":
+		return Result{Status: StatusProcessing}
+	}
+
+	switch p.AmountCents {
+	case 101:
+		return Result{Status: StatusFailed, ErrorCode: ErrCodeBankDown}
+	case 102:
+		return Result{Status: StatusFailed, ErrorCode: ErrCodeInvalidAccount}
+	case 103:
+		return Result{Status: StatusFailed, ErrorCode: ErrCodeInsufficient}
+	case 104:
+		return Result{Status: StatusProcessing}
+	}
+
+	if hash(p.ID)%100 < successRate {
+		return success(p)
+	}
+	return Result{Status: StatusFailed, ErrorCode: ErrCodeBankDown}
+}
+
+// success builds a deterministic 12-digit UTR from the payout id.
+func success(p Payout) Result {
+	return Result{Status: StatusSuccess, UTR: fmt.Sprintf("SBX%012d", hash("utr:"+p.ID)%1_000_000_000_000)}
+}
+
+func hash(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return h.Sum64()
+}
@@ -0,0 +1,110 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMockOutcomes(t *testing.T) {
+	created := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	m := NewMock(3 * time.Second)
+	m.now = func() time.Time { return created.Add(3 * time.Second) }
+
+	for _, tc := range []struct {
+		name   string
+		p      Payout
+		status string
+		code   string
+	}{
+		{"success vpa", Payout{ID: "po_1", AmountCents: 101, VPA: "success@sandbox"}, StatusSuccess, ""},
+		{"vpa is case-insensitive", Payout{ID: "po_2", AmountCents: 5000, VPA: "Failure@Sandbox"}, StatusFailed, ErrCodeInvalidAccount},
+		{"pending vpa", Payout{ID: "po_3", AmountCents: 5000, VPA: "pending@sandbox"}, StatusProcessing, ""},
+		{"bank down", Payout{ID: "po_4", AmountCents: 101}, StatusFailed, ErrCodeBankDown},
+		{"invalid beneficiary", Payout{ID: "po_5", AmountCents: 102}, StatusFailed, ErrCodeInvalidAccount},
+		{"insufficient balance", Payout{ID: "po_6", AmountCents: 103}, StatusFailed, ErrCodeInsufficient},
+		{"stuck", Payout{ID: "po_7", AmountCents: 104, VPA: "shop@upi"}, StatusProcessing, ""},
+	} {
+		tc.p.CreatedAt = created
+		res, err := m.Status(context.Background(), tc.p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Status != tc.status || res.ErrorCode != tc.code {
+			t.Errorf("%s: %+v, want %s %s", tc.name, res, tc.status, tc.code)
+		}
+		if (res.UTR != "") != (tc.status == StatusSuccess) {
+			t.Errorf("%s: UTR %q on %s", tc.name, res.UTR, res.Status)
+		}
+	}
+}
+
+func TestMockResolvesAfterDelay(t *testing.T) {
+	created := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	now := created
+	m := NewMock(3 * time.Second)
+	m.now = func() time.Time { return now }
+	p := Payout{ID: "po_1", AmountCents: 5000, VPA: "success@sandbox", CreatedAt: created}
+
+	if res, _ := m.Initiate(context.Background(), p); res.Status != StatusProcessing {
+		t.Errorf("Initiate: %+v, want processing", res)
+	}
+	now = created.Add(2999 * time.Millisecond)
+	if res, _ := m.Status(context.Background(), p); res.Status != StatusProcessing {
+		t.Errorf("before delay: %+v, want processing", res)
+	}
+	now = created.Add(3 * time.Second)
+	first, _ := m.Status(context.Background(), p)
+	again, _ := NewMock(0).Status(context.Background(), p)
+	if first.Status != StatusSuccess || first != again {
+		t.Errorf("after delay: %+v then %+v, want the same success", first, again)
+	}
+}
+
+func TestMockHashSplit(t *testing.T) {
+	m := NewMock(0)
+	succeeded := 0
+	const n = 2000
+	for i := 0; i < n; i++ {
+		p := Payout{ID: fmt.Sprintf("po_%d", i), AmountCents: 5000, VPA: "shop@upi"}
+		res, _ := m.Status(context.Background(), p)
+		if again, _ := m.Status(context.Background(), p); again != res {
+			t.Fatalf("%s: %+v then %+v, want a stable outcome", p.ID, res, again)
+		}
+		switch {
+		case res.Status == StatusSuccess:
+			succeeded++
+		case res.Status != StatusFailed || res.ErrorCode != ErrCodeBankDown:
+			t.Fatalf("%s: %+v, want success or bank down", p.ID, res)
+		}
+	}
+	if rate := succeeded * 100 / n; rate < successRate-3 || rate > successRate+3 {
+		t.Errorf("success rate %d%%, want about %d%%", rate, successRate)
+	}
+}
+
+func TestMockCancel(t *testing.T) {
+	created := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	now := created
+	m := NewMock(3 * time.Second)
+	m.now = func() time.Time { return now }
+	p := Payout{ID: "po_1", AmountCents: 5000, VPA: "success@sandbox", CreatedAt: created}
+
+	res, err := m.Cancel(context.Background(), p)
+	if err != nil || res.Status != StatusFailed || res.ErrorCode != ErrCodeCancelled {
+		t.Errorf("cancel while processing: %+v, %v; want failed CANCELLED", res, err)
+	}
+
+	now = created.Add(time.Minute)
+	res, err = m.Cancel(context.Background(), p)
+	if !errors.Is(err, ErrNotCancellable) || res.Status != StatusSuccess {
+		t.Errorf("cancel after success: %+v, %v; want ErrNotCancellable with the result", res, err)
+	}
+
+	stuck := Payout{ID: "po_2", AmountCents: 104, CreatedAt: created}
+	if res, err := m.Cancel(context.Background(), stuck); err != nil || res.ErrorCode != ErrCodeCancelled {
+		t.Errorf("cancel stuck payout: %+v, %v; want cancelled", res, err)
+	}
+}
@@ -0,0 +1,54 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Payout statuses reported by providers. They match the payouts.status column.
+const (
+	StatusProcessing = "processing"
+	StatusSuccess    = "success"
+	StatusFailed     = "failed"
+)
+
+// Payout is what a provider needs to move money. ID is our po_ id and doubles
+// as the idempotency reference sent to the provider.
+type Payout struct {
+	ID          string
+	AmountCents int64
+	Currency    string
+	Method      string // upi | bank
+	VPA         string
+	Account     string
+	IFSC        string
+	Name        string
+	CreatedAt   time.Time
+}
+
+// Result is the provider's view of a payout. UTR is set on success and
+// ErrorCode on failure.
+type Result struct {
+	Status    string
+	UTR       string
+	ErrorCode string
+}
+
+// Provider is a payout rail (sandbox, Razorpay Payouts, a bank API...).
+type Provider interface {
+	Name() string
+	Initiate(ctx context.Context, p Payout) (Result, error)
+	Status(ctx context.Context, p Payout) (Result, error)
+	Cancel(ctx context.Context, p Payout) (Result, error)
+}
+
+// New returns the provider configured by name.
+func New(name string, mockDelay time.Duration) (Provider, error) {
+	switch name {
+	case "", "mock":
+		return NewMock(mockDelay), nil
+	default:
+		return nil, fmt.Errorf("unknown payout provider %q", name)
+	}
+}
@@ -0,0 +1,53 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often MemoryStore drops buckets that have refilled;
+// a full bucket is the same as no bucket.
+const sweepInterval = time.Minute
+
+// MemoryStore keeps buckets in process memory. Each instance of the server
+// then enforces its own limits.
+type MemoryStore struct {
+	now func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]memoryBucket
+	swept   time.Time
+}
+
+type memoryBucket struct {
+	bucket
+	full time.Time // when the bucket will have refilled
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{now: time.Now, buckets: map[string]memoryBucket{}}
+}
+
+func (s *MemoryStore) Take(_ context.Context, key string, l Limit) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if now.Sub(s.swept) >= sweepInterval {
+		for k, b := range s.buckets {
+			if !now.Before(b.full) {
+				delete(s.buckets, k)
+			}
+		}
+		s.swept = now
+	}
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b.bucket = bucket{tokens: float64(l.Burst), updated: now}
+	}
+	next, res := l.take(b.bucket, now)
+	s.buckets[key] = memoryBucket{bucket: next, full: now.Add(res.Reset)}
+	return res, nil
+}
@@ -0,0 +1,81 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresStore keeps buckets in the rate_limit_buckets table, so every
+// instance of the server draws from the same buckets. Each take locks the
+// key's row for one short transaction and uses the database clock, read with
+// clock_timestamp() after the lock: now() is fixed when the transaction
+// starts, so a take that waited on the lock would refill from a time before
+// the previous take's update.
+type PostgresStore struct {
+	pool    *pgxpool.Pool
+	timeout time.Duration
+}
+
+// NewPostgresStore bounds each take by timeout.
+func NewPostgresStore(pool *pgxpool.Pool, timeout time.Duration) *PostgresStore {
+	return &PostgresStore{pool: pool, timeout: timeout}
+}
+
+func (s *PostgresStore) Take(ctx context.Context, key string, l Limit) (Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO rate_limit_buckets (key, tokens, updated_at, full_at)
+		VALUES ($1, $2, clock_timestamp(), clock_timestamp())
+		ON CONFLICT (key) DO NOTHING
+	`, key, float64(l.Burst)); err != nil {
+		return Result{}, err
+	}
+
+	var (
+		b   bucket
+		now time.Time
+	)
+	err = tx.QueryRow(ctx, `
+		WITH locked AS (
+			SELECT tokens, updated_at
+			FROM rate_limit_buckets
+			WHERE key = $1
+			FOR UPDATE
+		)
+		SELECT tokens, updated_at, clock_timestamp() FROM locked
+	`, key).Scan(&b.tokens, &b.updated, &now)
+	if err != nil {
+		return Result{}, err
+	}
+
+	next, res := l.take(b, now)
+	if _, err := tx.Exec(ctx, `
+		UPDATE rate_limit_buckets SET tokens = $2, updated_at = $3, full_at = $4 WHERE key = $1
+	`, key, next.tokens, next.updated, now.Add(res.Reset)); err != nil {
+		return Result{}, err
+	}
+	return res, tx.Commit(ctx)
+}
+
+// DeleteFull removes buckets that have refilled since their last take;
+// they are the same as no bucket.
+func (s *PostgresStore) DeleteFull(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	tag, err := s.pool.Exec(ctx, `DELETE FROM rate_limit_buckets WHERE full_at < now()`)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,80 @@
+// Package ratelimit implements token buckets: each key holds up to Burst
+// tokens, refilled at Rate per second, and every request spends one. The
+// buckets live in a Store, in memory for a single instance or in Postgres
+// when several instances share the limits.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Limit is a bucket's refill rate, in tokens per second, and capacity.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// PerMinute allows n requests a minute on average and up to burst at once.
+func PerMinute(n, burst int) Limit {
+	return Limit{Rate: float64(n) / 60, Burst: burst}
+}
+
+// Result is the outcome of taking a token.
+type Result struct {
+	Allowed bool
+	// Limit is the bucket's capacity.
+	Limit int
+	// Remaining is the number of whole tokens left.
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until a token is available; zero when
+	// Allowed.
+	RetryAfter time.Duration
+}
+
+// Store takes a token from key's bucket, creating a full bucket for a new
+// key.
+type Store interface {
+	Take(ctx context.Context, key string, l Limit) (Result, error)
+}
+
+// bucket is a key's state: its tokens as of updated.
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// take refills b up to now and spends a token if one is available.
+func (l Limit) take(b bucket, now time.Time) (bucket, Result) {
+	elapsed := now.Sub(b.updated).Seconds()
+	if elapsed < 0 {
+		elapsed = 0 // clock stepped back
+	}
+	burst := float64(l.Burst)
+	tokens := math.Min(burst, b.tokens+elapsed*l.Rate)
+
+	res := Result{Limit: l.Burst}
+	if tokens >= 1 {
+		tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = l.wait(1 - tokens)
+	}
+	res.Remaining = int(tokens)
+	res.Reset = l.wait(burst - tokens)
+	return bucket{tokens: tokens, updated: now}, res
+}
+
+// wait is how long refilling n tokens takes.
+func (l Limit) wait(n float64) time.Duration {
+	if n <= 0 {
+		return 0
+	}
+	if l.Rate <= 0 {
+		return math.MaxInt64
+	}
+	return time.Duration(n / l.Rate * float64(time.Second))
+}
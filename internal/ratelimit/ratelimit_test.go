@@ -0,0 +1,62 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreTake(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := NewMemoryStore()
+	s.now = func() time.Time { return now }
+	l := PerMinute(60, 3) // a token a second, three at once
+	ctx := context.Background()
+
+	take := func() Result {
+		t.Helper()
+		res, err := s.Take(ctx, "k", l)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	for want := 2; want >= 0; want-- {
+		res := take()
+		if !res.Allowed || res.Remaining != want || res.Limit != 3 {
+			t.Fatalf("burst take: %+v, want allowed with %d remaining", res, want)
+		}
+	}
+	res := take()
+	if res.Allowed || res.RetryAfter != time.Second || res.Reset != 3*time.Second {
+		t.Fatalf("empty bucket: %+v, want denied, retry after 1s, full in 3s", res)
+	}
+
+	now = now.Add(1500 * time.Millisecond)
+	res = take()
+	if !res.Allowed || res.Remaining != 0 {
+		t.Fatalf("after refill: %+v, want allowed with 0 remaining", res)
+	}
+	if res.Reset != 2500*time.Millisecond {
+		t.Errorf("Reset = %s, want 2.5s", res.Reset)
+	}
+
+	if res, _ := s.Take(ctx, "other", l); !res.Allowed || res.Remaining != 2 {
+		t.Errorf("other key: %+v, want its own full bucket", res)
+	}
+}
+
+func TestMemoryStoreSweepsFullBuckets(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := NewMemoryStore()
+	s.now = func() time.Time { return now }
+	l := PerMinute(60, 5)
+
+	s.Take(context.Background(), "a", l)
+	now = now.Add(sweepInterval)
+	s.Take(context.Background(), "b", l)
+	if _, ok := s.buckets["a"]; ok || len(s.buckets) != 1 {
+		t.Errorf("buckets after sweep = %v, want only b", s.buckets)
+	}
+}
@@ -0,0 +1,361 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ========== COACH PLANS ==========
+
+type CoachPlan struct {
+	ID          uuid.UUID `json:"id"`
+	UserID      uuid.UUID `json:"user_id"`
+	WeekStart   time.Time `json:"week_start"`
+	Rules       []string  `json:"rules"`
+	DailyNudge  string    `json:"daily_nudge"`
+	HealthScore int       `json:"health_score"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// WeeklySpend is one (week, category, mood) bucket of expenses.
+// Mood is empty when the expense was recorded without one.
+type WeeklySpend struct {
+	WeekStart time.Time
+	Category  string
+	Mood      string
+	Amount    float64
+}
+
+type PotTotals struct {
+	Count         int
+	TargetAmount  float64
+	CurrentAmount float64
+}
+
+// WeeklySpendByOwner buckets expenses across all shops of an owner by ISO week
+// in timezone tz, category and mood, starting at since.
+func (r *Repository) WeeklySpendByOwner(ctx context.Context, ownerID uuid.UUID, tz string, since time.Time) ([]WeeklySpend, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	rows, err := r.pool.Query(ctx, `
+		SELECT date_trunc('week', e.spent_at AT TIME ZONE $3::text)::date, e.category, COALESCE(e.mood, ''), SUM(e.amount)
+		FROM expenses e
+		JOIN shops s ON s.id = e.shop_id
+		WHERE s.owner_id = $1
+		  AND e.spent_at >= $2
+		GROUP BY 1, 2, 3
+		ORDER BY 1
+	`, ownerID, since, tz)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []WeeklySpend
+	for rows.Next() {
+		var w WeeklySpend
+		if err := rows.Scan(&w.WeekStart, &w.Category, &w.Mood, &w.Amount); err != nil {
+			return nil, err
+		}
+		result = append(result, w)
+	}
+	return result, rows.Err()
+}
+
+// SumRevenueByOwner totals invoices across all shops of an owner created in
+// [since, until).
+func (r *Repository) SumRevenueByOwner(ctx context.Context, ownerID uuid.UUID, since, until time.Time) (float64, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	row := r.pool.QueryRow(ctx, `
+		SELECT COALESCE(SUM(i.total_amount),0)
+		FROM invoices i
+		JOIN shops s ON s.id = i.shop_id
+		WHERE s.owner_id = $1
+		  AND i.created_at >= $2
+		  AND i.created_at < $3
+	`, ownerID, since, until)
+
+	var total float64
+	if err := row.Scan(&total); err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+func (r *Repository) PotTotalsByOwner(ctx context.Context, ownerID uuid.UUID) (*PotTotals, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	row := r.pool.QueryRow(ctx, `
+		SELECT COUNT(*), COALESCE(SUM(p.target_amount),0), COALESCE(SUM(p.current_amount),0)
+		FROM pots p
+		JOIN shops s ON s.id = p.shop_id
+		WHERE s.owner_id = $1
+	`, ownerID)
+
+	var t PotTotals
+	if err := row.Scan(&t.Count, &t.TargetAmount, &t.CurrentAmount); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// UpsertCoachPlan stores the plan for (user, week_start), replacing any plan
+// generated earlier in the same week.
+func (r *Repository) UpsertCoachPlan(ctx context.Context, p CoachPlan) (*CoachPlan, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	rules, err := json.Marshal(p.Rules)
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.pool.QueryRow(ctx, `
+		INSERT INTO coach_plans (user_id, week_start, rules, daily_nudge, health_score)
+		VALUES ($1,$2,$3,$4,$5)
+		ON CONFLICT (user_id, week_start) DO UPDATE
+		SET rules = EXCLUDED.rules,
+		    daily_nudge = EXCLUDED.daily_nudge,
+		    health_score = EXCLUDED.health_score,
+		    created_at = now()
+		RETURNING id, created_at
+	`, p.UserID, p.WeekStart, rules, p.DailyNudge, p.HealthScore).
+		Scan(&p.ID, &p.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (r *Repository) ListCoachPlansByUser(ctx context.Context, userID uuid.UUID, limit int) ([]CoachPlan, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	rows, err := r.pool.Query(ctx, `
+		SELECT id, user_id, week_start, rules, COALESCE(daily_nudge, ''), health_score, created_at
+		FROM coach_plans
+		WHERE user_id = $1
+		ORDER BY week_start DESC
+		LIMIT $2
+	`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []CoachPlan
+	for rows.Next() {
+		var p CoachPlan
+		if err := rows.Scan(&p.ID, &p.UserID, &p.WeekStart, &p.Rules, &p.DailyNudge, &p.HealthScore, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+		result = append(result, p)
+	}
+	return result, rows.Err()
+}
+
+// ========== COACH INSIGHTS ==========
+
+// CoachInsight is one stored insight of a shop's current set. ID stays the
+// same for as long as its code keeps coming back, so dismissals and snoozes
+// key on it. Action is the service's JSON, stored as is.
+type CoachInsight struct {
+	ID          uuid.UUID
+	ShopID      uuid.UUID
+	Code        string
+	Severity    string
+	Message     string
+	Metrics     map[string]float64
+	Action      json.RawMessage
+	GeneratedAt time.Time
+}
+
+// ListCoachInsights returns the shop's stored insights in the order they were
+// generated.
+func (r *Repository) ListCoachInsights(ctx context.Context, shopID uuid.UUID) ([]CoachInsight, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	rows, err := r.pool.Query(ctx, `
+		SELECT id, shop_id, code, severity, message, metrics, action, generated_at
+		FROM coach_insights
+		WHERE shop_id = $1
+		ORDER BY position
+	`, shopID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []CoachInsight
+	for rows.Next() {
+		var in CoachInsight
+		if err := rows.Scan(&in.ID, &in.ShopID, &in.Code, &in.Severity, &in.Message, &in.Metrics, &in.Action, &in.GeneratedAt); err != nil {
+			return nil, err
+		}
+		result = append(result, in)
+	}
+	return result, rows.Err()
+}
+
+func (r *Repository) GetCoachInsight(ctx context.Context, shopID, id uuid.UUID) (*CoachInsight, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var in CoachInsight
+	err := r.pool.QueryRow(ctx, `
+		SELECT id, shop_id, code, severity, message, metrics, action, generated_at
+		FROM coach_insights
+		WHERE shop_id = $1 AND id = $2
+	`, shopID, id).Scan(&in.ID, &in.ShopID, &in.Code, &in.Severity, &in.Message, &in.Metrics, &in.Action, &in.GeneratedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &in, nil
+}
+
+// ReplaceCoachInsights makes insights the shop's current set. A code already
+// stored keeps its ID; stored codes missing from insights are deleted along
+// with any dismiss or snooze state on them. Codes must be unique.
+func (r *Repository) ReplaceCoachInsights(ctx context.Context, shopID uuid.UUID, insights []CoachInsight) ([]CoachInsight, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.txTimeout)
+	defer cancel()
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Serialise regenerations of the same shop.
+	if _, err := tx.Exec(ctx, `SELECT 1 FROM shops WHERE id = $1 FOR UPDATE`, shopID); err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, len(insights))
+	result := make([]CoachInsight, 0, len(insights))
+	for i, in := range insights {
+		metrics, err := json.Marshal(in.Metrics)
+		if err != nil {
+			return nil, err
+		}
+		in.ShopID = shopID
+		err = tx.QueryRow(ctx, `
+			INSERT INTO coach_insights (shop_id, code, position, severity, message, metrics, action, generated_at)
+			VALUES ($1,$2,$3,$4,$5,$6,$7,now())
+			ON CONFLICT (shop_id, code) DO UPDATE
+			SET position = EXCLUDED.position,
+			    severity = EXCLUDED.severity,
+			    message = EXCLUDED.message,
+			    metrics = EXCLUDED.metrics,
+			    action = EXCLUDED.action,
+			    generated_at = EXCLUDED.generated_at
+			RETURNING id, generated_at
+		`, shopID, in.Code, i, in.Severity, in.Message, metrics, in.Action).Scan(&in.ID, &in.GeneratedAt)
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, in.Code)
+		result = append(result, in)
+	}
+
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM coach_insights
+		WHERE shop_id = $1 AND NOT (code = ANY($2))
+	`, shopID, codes); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ========== COACH INSIGHT STATE ==========
+
+const (
+	InsightDismissed = "DISMISSED"
+	InsightSnoozed   = "SNOOZED"
+)
+
+// CoachInsightState records that a user dismissed or snoozed one of a shop's
+// stored insights, together with the metrics it had at that moment.
+type CoachInsightState struct {
+	UserID       uuid.UUID
+	ShopID       uuid.UUID
+	InsightID    uuid.UUID
+	Status       string
+	SnoozedUntil *time.Time
+	Metrics      map[string]float64
+	UpdatedAt    time.Time
+}
+
+func (r *Repository) UpsertCoachInsightState(ctx context.Context, st CoachInsightState) (*CoachInsightState, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	metrics, err := json.Marshal(st.Metrics)
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.pool.QueryRow(ctx, `
+		INSERT INTO coach_insight_states (user_id, shop_id, insight_id, status, snoozed_until, metrics)
+		VALUES ($1,$2,$3,$4,$5,$6)
+		ON CONFLICT (user_id, insight_id) DO UPDATE
+		SET status = EXCLUDED.status,
+		    snoozed_until = EXCLUDED.snoozed_until,
+		    metrics = EXCLUDED.metrics,
+		    updated_at = now()
+		RETURNING updated_at
+	`, st.UserID, st.ShopID, st.InsightID, st.Status, st.SnoozedUntil, metrics).
+		Scan(&st.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+func (r *Repository) ListCoachInsightStates(ctx context.Context, userID, shopID uuid.UUID) ([]CoachInsightState, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	rows, err := r.pool.Query(ctx, `
+		SELECT user_id, shop_id, insight_id, status, snoozed_until, metrics, updated_at
+		FROM coach_insight_states
+		WHERE user_id = $1 AND shop_id = $2
+	`, userID, shopID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []CoachInsightState
+	for rows.Next() {
+		var st CoachInsightState
+		if err := rows.Scan(&st.UserID, &st.ShopID, &st.InsightID, &st.Status, &st.SnoozedUntil, &st.Metrics, &st.UpdatedAt); err != nil {
+			return nil, err
+		}
+		result = append(result, st)
+	}
+	return result, rows.Err()
+}
+
+func (r *Repository) DeleteCoachInsightState(ctx context.Context, userID, shopID, insightID uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	_, err := r.pool.Exec(ctx, `
+		DELETE FROM coach_insight_states
+		WHERE user_id = $1 AND shop_id = $2 AND insight_id = $3
+	`, userID, shopID, insightID)
+	return err
+}
@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ========== DASHBOARD SERIES ==========
+
+type SeriesBucket struct {
+	Start    time.Time
+	Revenue  float64
+	Expenses float64
+	Invoices int
+}
+
+type PeriodTotals struct {
+	Revenue  float64
+	Expenses float64
+	Invoices int
+}
+
+// DashboardSeries buckets revenue, expenses and invoice count for the local
+// dates [from, to] in the shop's timezone, one row per bucket including empty
+// ones. It also totals the preceding period of the same length so callers can
+// compare periods without a second round trip. granularity must be one of
+// day, week or month.
+func (r *Repository) DashboardSeries(ctx context.Context, shopID uuid.UUID, tz string, from, to time.Time, granularity string) ([]SeriesBucket, *PeriodTotals, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	rows, err := r.pool.Query(ctx, `
+		WITH bounds AS (
+			SELECT $2::date::timestamp AS from_ts,
+			       ($3::date + 1)::timestamp AS to_ts,
+			       ($2::date - ($3::date - $2::date + 1))::timestamp AS prev_ts
+		), buckets AS (
+			SELECT generate_series(
+			         date_trunc($4::text, b.from_ts),
+			         b.to_ts - interval '1 day',
+			         ('1 ' || $4::text)::interval
+			       ) AS bucket
+			FROM bounds b
+		), sales AS (
+			SELECT i.created_at AT TIME ZONE $5::text AS local_ts, i.total_amount
+			FROM invoices i, bounds b
+			WHERE i.shop_id = $1
+			  AND i.created_at >= b.prev_ts AT TIME ZONE $5::text
+			  AND i.created_at <  b.to_ts AT TIME ZONE $5::text
+		), spend AS (
+			SELECT e.spent_at AT TIME ZONE $5::text AS local_ts, e.amount
+			FROM expenses e, bounds b
+			WHERE e.shop_id = $1
+			  AND e.spent_at >= b.prev_ts AT TIME ZONE $5::text
+			  AND e.spent_at <  b.to_ts AT TIME ZONE $5::text
+		), cur_sales AS (
+			SELECT date_trunc($4::text, s.local_ts) AS bucket, SUM(s.total_amount) AS revenue, COUNT(*) AS invoices
+			FROM sales s, bounds b
+			WHERE s.local_ts >= b.from_ts
+			GROUP BY 1
+		), cur_spend AS (
+			SELECT date_trunc($4::text, s.local_ts) AS bucket, SUM(s.amount) AS expenses
+			FROM spend s, bounds b
+			WHERE s.local_ts >= b.from_ts
+			GROUP BY 1
+		), prev AS (
+			SELECT (SELECT COALESCE(SUM(s.total_amount), 0) FROM sales s, bounds b WHERE s.local_ts < b.from_ts) AS revenue,
+			       (SELECT COALESCE(SUM(s.amount), 0) FROM spend s, bounds b WHERE s.local_ts < b.from_ts) AS expenses,
+			       (SELECT COUNT(*) FROM sales s, bounds b WHERE s.local_ts < b.from_ts) AS invoices
+		)
+		SELECT bk.bucket::date,
+		       COALESCE(cs.revenue, 0), COALESCE(ce.expenses, 0), COALESCE(cs.invoices, 0),
+		       prev.revenue, prev.expenses, prev.invoices
+		FROM buckets bk
+		LEFT JOIN cur_sales cs ON cs.bucket = bk.bucket
+		LEFT JOIN cur_spend ce ON ce.bucket = bk.bucket
+		CROSS JOIN prev
+		ORDER BY bk.bucket
+	`, shopID, from, to, granularity, tz)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var (
+		buckets []SeriesBucket
+		prev    PeriodTotals
+	)
+	for rows.Next() {
+		var b SeriesBucket
+		if err := rows.Scan(&b.Start, &b.Revenue, &b.Expenses, &b.Invoices, &prev.Revenue, &prev.Expenses, &prev.Invoices); err != nil {
+			return nil, nil, err
+		}
+		buckets = append(buckets, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	return buckets, &prev, nil
+}
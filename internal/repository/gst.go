@@ -0,0 +1,233 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ========== GST ==========
+
+// ErrCreditExceedsInvoice is returned when credit notes would credit more
+// than an invoice's taxable value.
+var ErrCreditExceedsInvoice = errors.New("credit notes exceed the invoice's taxable value")
+
+type CreditNote struct {
+	ID           uuid.UUID
+	ShopID       uuid.UUID
+	InvoiceID    uuid.UUID
+	TaxableValue float64
+	GSTRate      float64
+	TaxAmount    float64
+	Reason       *string
+	CreatedAt    time.Time
+}
+
+type GSTLine struct {
+	HSNCode  *string
+	GSTRate  float64
+	Quantity int
+	Taxable  float64
+	Tax      float64
+}
+
+// GSTInvoice is an invoice with its lines as reported in GST returns.
+type GSTInvoice struct {
+	Invoice
+	Lines []GSTLine
+}
+
+// GSTCreditNote is a credit note with the invoice it adjusts.
+type GSTCreditNote struct {
+	CreditNote
+	Invoice Invoice
+}
+
+// StateInputTax is claimable input tax from suppliers in one state.
+type StateInputTax struct {
+	StateCode string
+	InputTax  float64
+}
+
+func (r *Repository) GetInvoice(ctx context.Context, id uuid.UUID) (*Invoice, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var iv Invoice
+	err := r.pool.QueryRow(ctx, `
+		SELECT id, shop_id, COALESCE(customer_name, ''), COALESCE(customer_phone, ''), total_amount, tax_amount,
+		       status, due_date, customer_gstin, place_of_supply, created_at
+		FROM invoices
+		WHERE id = $1
+	`, id).Scan(&iv.ID, &iv.ShopID, &iv.CustomerName, &iv.CustomerPhone, &iv.TotalAmount, &iv.TaxAmount, &iv.Status, &iv.DueDate,
+		&iv.CustomerGSTIN, &iv.PlaceOfSupply, &iv.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &iv, nil
+}
+
+// CreateCreditNote records a credit note against its invoice and posts je for
+// it in one transaction. The invoice row is locked so concurrent notes cannot
+// together credit more than the invoice's taxable value.
+func (r *Repository) CreateCreditNote(ctx context.Context, cn CreditNote, je JournalEntry) (*CreditNote, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.txTimeout)
+	defer cancel()
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var taxable, credited float64
+	err = tx.QueryRow(ctx, `
+		SELECT i.shop_id, i.total_amount - i.tax_amount,
+		       (SELECT COALESCE(SUM(c.taxable_value), 0) FROM credit_notes c WHERE c.invoice_id = i.id)
+		FROM invoices i
+		WHERE i.id = $1
+		FOR UPDATE
+	`, cn.InvoiceID).Scan(&cn.ShopID, &taxable, &credited)
+	if err != nil {
+		return nil, err
+	}
+	if toPaise(credited+cn.TaxableValue) > toPaise(taxable) {
+		return nil, ErrCreditExceedsInvoice
+	}
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO credit_notes (shop_id, invoice_id, taxable_value, gst_rate, tax_amount, reason)
+		VALUES ($1,$2,$3,$4,$5,$6)
+		RETURNING id, created_at
+	`, cn.ShopID, cn.InvoiceID, cn.TaxableValue, cn.GSTRate, cn.TaxAmount, cn.Reason).
+		Scan(&cn.ID, &cn.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	je.BookID, je.SourceID = cn.ShopID, cn.ID.String()
+	if err := postJournal(ctx, tx, je); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &cn, nil
+}
+
+// GSTInvoices returns the shop's invoices created in [from, to) with their
+// lines, oldest first.
+func (r *Repository) GSTInvoices(ctx context.Context, shopID uuid.UUID, from, to time.Time) ([]GSTInvoice, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	rows, err := r.pool.Query(ctx, `
+		SELECT i.id, i.shop_id, COALESCE(i.customer_name, ''), COALESCE(i.customer_phone, ''),
+		       i.total_amount, i.tax_amount, i.status, i.due_date, i.customer_gstin, i.place_of_supply, i.created_at,
+		       ii.hsn_code, ii.gst_rate, ii.quantity, ii.quantity * ii.unit_price, ii.tax_amount
+		FROM invoices i
+		JOIN invoice_items ii ON ii.invoice_id = i.id
+		WHERE i.shop_id = $1
+		  AND i.created_at >= $2
+		  AND i.created_at < $3
+		ORDER BY i.created_at, i.id, ii.id
+	`, shopID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []GSTInvoice
+	for rows.Next() {
+		var (
+			iv Invoice
+			l  GSTLine
+		)
+		err := rows.Scan(&iv.ID, &iv.ShopID, &iv.CustomerName, &iv.CustomerPhone,
+			&iv.TotalAmount, &iv.TaxAmount, &iv.Status, &iv.DueDate, &iv.CustomerGSTIN, &iv.PlaceOfSupply, &iv.CreatedAt,
+			&l.HSNCode, &l.GSTRate, &l.Quantity, &l.Taxable, &l.Tax)
+		if err != nil {
+			return nil, err
+		}
+		if n := len(result); n == 0 || result[n-1].ID != iv.ID {
+			result = append(result, GSTInvoice{Invoice: iv})
+		}
+		last := &result[len(result)-1]
+		last.Lines = append(last.Lines, l)
+	}
+	return result, rows.Err()
+}
+
+// GSTCreditNotes returns the shop's credit notes issued in [from, to) with
+// the invoices they adjust, oldest first.
+func (r *Repository) GSTCreditNotes(ctx context.Context, shopID uuid.UUID, from, to time.Time) ([]GSTCreditNote, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	rows, err := r.pool.Query(ctx, `
+		SELECT c.id, c.shop_id, c.invoice_id, c.taxable_value, c.gst_rate, c.tax_amount, c.reason, c.created_at,
+		       i.id, i.shop_id, COALESCE(i.customer_name, ''), COALESCE(i.customer_phone, ''),
+		       i.total_amount, i.tax_amount, i.status, i.due_date, i.customer_gstin, i.place_of_supply, i.created_at
+		FROM credit_notes c
+		JOIN invoices i ON i.id = c.invoice_id
+		WHERE c.shop_id = $1
+		  AND c.created_at >= $2
+		  AND c.created_at < $3
+		ORDER BY c.created_at, c.id
+	`, shopID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []GSTCreditNote
+	for rows.Next() {
+		var n GSTCreditNote
+		iv := &n.Invoice
+		err := rows.Scan(&n.ID, &n.ShopID, &n.InvoiceID, &n.TaxableValue, &n.GSTRate, &n.TaxAmount, &n.Reason, &n.CreatedAt,
+			&iv.ID, &iv.ShopID, &iv.CustomerName, &iv.CustomerPhone,
+			&iv.TotalAmount, &iv.TaxAmount, &iv.Status, &iv.DueDate, &iv.CustomerGSTIN, &iv.PlaceOfSupply, &iv.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, n)
+	}
+	return result, rows.Err()
+}
+
+// InputTaxByState sums claimable input tax on the shop's expenses in
+// [from, to) by supplier state. Expenses without a supplier GSTIN are not
+// claimable and are left out.
+func (r *Repository) InputTaxByState(ctx context.Context, shopID uuid.UUID, from, to time.Time) ([]StateInputTax, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	rows, err := r.pool.Query(ctx, `
+		SELECT left(supplier_gstin, 2), SUM(input_tax)
+		FROM expenses
+		WHERE shop_id = $1
+		  AND spent_at >= $2
+		  AND spent_at < $3
+		  AND supplier_gstin IS NOT NULL
+		  AND input_tax > 0
+		GROUP BY 1
+		ORDER BY 1
+	`, shopID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []StateInputTax
+	for rows.Next() {
+		var s StateInputTax
+		if err := rows.Scan(&s.StateCode, &s.InputTax); err != nil {
+			return nil, err
+		}
+		result = append(result, s)
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ========== IDEMPOTENCY KEYS ==========
+
+// StoredResponse is a response recorded under an Idempotency-Key. Done is
+// false while the original request is still being handled.
+type StoredResponse struct {
+	RequestHash string
+	Done        bool
+	StatusCode  int
+	ContentType string
+	Body        []byte
+}
+
+// ReserveIdempotencyKey claims (scope, key) for a new request, holding it in
+// progress for lease. It returns reserved=true when the caller should run the
+// request: the key is new, its previous record has expired, or a previous
+// request's lease ran out before it stored a response (it crashed or could
+// not release the key). Otherwise it returns the existing record.
+func (r *Repository) ReserveIdempotencyKey(ctx context.Context, scope, key, requestHash string, ttl, lease time.Duration) (*StoredResponse, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	tag, err := r.pool.Exec(ctx, `
+		INSERT INTO idempotency_keys (scope, key, request_hash, expires_at, locked_until)
+		VALUES ($1, $2, $3, now() + $4::interval, now() + $5::interval)
+		ON CONFLICT (scope, key) DO UPDATE
+		SET request_hash = EXCLUDED.request_hash,
+		    status_code = NULL, content_type = NULL, response_body = NULL,
+		    created_at = now(), expires_at = EXCLUDED.expires_at,
+		    locked_until = EXCLUDED.locked_until
+		WHERE idempotency_keys.expires_at < now()
+		   OR (idempotency_keys.status_code IS NULL AND idempotency_keys.locked_until < now())
+	`, scope, key, requestHash, ttl, lease)
+	if err != nil {
+		return nil, false, err
+	}
+	if tag.RowsAffected() == 1 {
+		return nil, true, nil
+	}
+
+	var (
+		res         StoredResponse
+		status      *int
+		contentType *string
+	)
+	err = r.pool.QueryRow(ctx, `
+		SELECT request_hash, status_code, content_type, response_body
+		FROM idempotency_keys
+		WHERE scope = $1 AND key = $2
+	`, scope, key).Scan(&res.RequestHash, &status, &contentType, &res.Body)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Released between the two statements; let the caller retry.
+		return r.ReserveIdempotencyKey(ctx, scope, key, requestHash, ttl, lease)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if status != nil {
+		res.Done = true
+		res.StatusCode = *status
+	}
+	if contentType != nil {
+		res.ContentType = *contentType
+	}
+	return &res, false, nil
+}
+
+// SaveIdempotentResponse records the response for a reserved key. If the
+// lease ran out and a retry already stored its response, that one is kept.
+func (r *Repository) SaveIdempotentResponse(ctx context.Context, scope, key string, statusCode int, contentType string, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	_, err := r.pool.Exec(ctx, `
+		UPDATE idempotency_keys
+		SET status_code = $3, content_type = $4, response_body = $5, locked_until = NULL
+		WHERE scope = $1 AND key = $2 AND status_code IS NULL
+	`, scope, key, statusCode, contentType, body)
+	return err
+}
+
+// ReleaseIdempotencyKey forgets a reserved key so the request can be retried,
+// e.g. after a server error.
+func (r *Repository) ReleaseIdempotencyKey(ctx context.Context, scope, key string) error {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	_, err := r.pool.Exec(ctx, `
+		DELETE FROM idempotency_keys
+		WHERE scope = $1 AND key = $2 AND status_code IS NULL
+	`, scope, key)
+	return err
+}
+
+// DeleteExpiredIdempotencyKeys removes records past their TTL.
+func (r *Repository) DeleteExpiredIdempotencyKeys(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	tag, err := r.pool.Exec(ctx, `DELETE FROM idempotency_keys WHERE expires_at < now()`)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,247 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// ========== COACH RULE AGGREGATES ==========
+
+// insightRowLimit caps how many offenders each aggregate returns; the coach
+// only names the worst few.
+const insightRowLimit = 5
+
+type CategorySpike struct {
+	Category string
+	ThisWeek float64
+	LastWeek float64
+}
+
+type LowMarginProduct struct {
+	ID           uuid.UUID
+	Name         string
+	CostPrice    float64
+	SellingPrice float64
+	Margin       float64
+}
+
+type DeadStockProduct struct {
+	ID         uuid.UUID
+	Name       string
+	Stock      int
+	StockValue float64
+}
+
+// DeadStockSummary covers every dead product; Top holds the worst few.
+type DeadStockSummary struct {
+	Products   int
+	StockValue float64
+	Top        []DeadStockProduct
+}
+
+// OverdueCreditSummary totals what is still owed on a shop's CREDIT invoices
+// past their due date, net of credit notes; fully credited invoices are left
+// out. Customers are told apart by name and phone; MaxDays is how late the
+// oldest is.
+type OverdueCreditSummary struct {
+	Customers int
+	Invoices  int
+	Amount    float64
+	MaxDays   int
+}
+
+// CashPosition is the shop's cash on hand (paid sales net of credit notes,
+// minus expenses, pot transfers and the owner's payouts) and its average
+// daily net outflow over the burn window.
+type CashPosition struct {
+	Cash      float64
+	DailyBurn float64
+}
+
+// CategorySpikes compares each expense category's spend in the last 7 days
+// with the 7 days before, returning categories that grew by more than
+// minRatio (e.g. 0.5 for +50%) and by at least minIncrease in absolute terms.
+func (r *Repository) CategorySpikes(ctx context.Context, shopID uuid.UUID, minRatio, minIncrease float64) ([]CategorySpike, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	rows, err := r.pool.Query(ctx, `
+		WITH weekly AS (
+			SELECT category,
+			       COALESCE(SUM(amount) FILTER (WHERE spent_at >= now() - interval '7 days'), 0) AS this_week,
+			       COALESCE(SUM(amount) FILTER (WHERE spent_at <  now() - interval '7 days'), 0) AS last_week
+			FROM expenses
+			WHERE shop_id = $1
+			  AND spent_at >= now() - interval '14 days'
+			GROUP BY category
+		)
+		SELECT category, this_week, last_week
+		FROM weekly
+		WHERE last_week > 0
+		  AND this_week > last_week * (1 + $2::numeric)
+		  AND this_week - last_week >= $3::numeric
+		ORDER BY this_week - last_week DESC
+		LIMIT $4
+	`, shopID, minRatio, minIncrease, insightRowLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []CategorySpike
+	for rows.Next() {
+		var c CategorySpike
+		if err := rows.Scan(&c.Category, &c.ThisWeek, &c.LastWeek); err != nil {
+			return nil, err
+		}
+		result = append(result, c)
+	}
+	return result, rows.Err()
+}
+
+// LowMarginProducts returns products whose gross margin on selling price is
+// below maxMargin (e.g. 0.1 for 10%).
+func (r *Repository) LowMarginProducts(ctx context.Context, shopID uuid.UUID, maxMargin float64) ([]LowMarginProduct, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	rows, err := r.pool.Query(ctx, `
+		SELECT id, name, cost_price, selling_price,
+		       (selling_price - cost_price) / selling_price AS margin
+		FROM products
+		WHERE shop_id = $1
+		  AND selling_price > 0
+		  AND (selling_price - cost_price) / selling_price < $2::numeric
+		ORDER BY margin
+		LIMIT $3
+	`, shopID, maxMargin, insightRowLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []LowMarginProduct
+	for rows.Next() {
+		var p LowMarginProduct
+		if err := rows.Scan(&p.ID, &p.Name, &p.CostPrice, &p.SellingPrice, &p.Margin); err != nil {
+			return nil, err
+		}
+		result = append(result, p)
+	}
+	return result, rows.Err()
+}
+
+// DeadStock finds in-stock products with no invoice lines in the last days.
+// Top is ordered by the cash tied up in them (stock at cost price).
+func (r *Repository) DeadStock(ctx context.Context, shopID uuid.UUID, days int) (*DeadStockSummary, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	rows, err := r.pool.Query(ctx, `
+		SELECT p.id, p.name, p.stock, p.stock * p.cost_price AS stock_value,
+		       COUNT(*) OVER (), SUM(p.stock * p.cost_price) OVER ()
+		FROM products p
+		WHERE p.shop_id = $1
+		  AND p.stock > 0
+		  AND NOT EXISTS (
+			SELECT 1
+			FROM invoice_items ii
+			JOIN invoices i ON i.id = ii.invoice_id
+			WHERE ii.product_id = p.id
+			  AND i.created_at >= now() - make_interval(days => $2)
+		  )
+		ORDER BY stock_value DESC
+		LIMIT $3
+	`, shopID, days, insightRowLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result DeadStockSummary
+	for rows.Next() {
+		var p DeadStockProduct
+		if err := rows.Scan(&p.ID, &p.Name, &p.Stock, &p.StockValue, &result.Products, &result.StockValue); err != nil {
+			return nil, err
+		}
+		result.Top = append(result.Top, p)
+	}
+	return &result, rows.Err()
+}
+
+// OverdueCredit totals CREDIT invoices past their due date in one row.
+func (r *Repository) OverdueCredit(ctx context.Context, shopID uuid.UUID) (*OverdueCreditSummary, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var result OverdueCreditSummary
+	err := r.pool.QueryRow(ctx, `
+		WITH owed AS (
+			SELECT i.customer_name, i.customer_phone, i.due_date,
+			       i.total_amount - COALESCE(SUM(cn.taxable_value + cn.tax_amount), 0) AS amount
+			FROM invoices i
+			LEFT JOIN credit_notes cn ON cn.invoice_id = i.id
+			WHERE i.shop_id = $1
+			  AND i.status = 'CREDIT'
+			  AND i.due_date < current_date
+			GROUP BY i.id
+		)
+		SELECT COUNT(DISTINCT (COALESCE(customer_name, ''), COALESCE(customer_phone, ''))),
+		       COUNT(*), COALESCE(SUM(amount), 0), COALESCE(current_date - MIN(due_date), 0)
+		FROM owed
+		WHERE amount > 0
+	`, shopID).Scan(&result.Customers, &result.Invoices, &result.Amount, &result.MaxDays)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// CashPositionForShop derives cash on hand and the average daily net burn over
+// the last burnDays in a single round trip. Payouts are not tied to a shop, so
+// the owner's successful and in-flight payouts count against each of their
+// shops: the estimate errs towards less cash.
+func (r *Repository) CashPositionForShop(ctx context.Context, shopID uuid.UUID, burnDays int) (*CashPosition, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	row := r.pool.QueryRow(ctx, `
+		WITH sales AS (
+			SELECT COALESCE(SUM(total_amount), 0) AS total,
+			       COALESCE(SUM(total_amount) FILTER (WHERE created_at >= now() - make_interval(days => $2)), 0) AS recent
+			FROM invoices
+			WHERE shop_id = $1 AND status = 'PAID'
+		), credited AS (
+			SELECT COALESCE(SUM(cn.taxable_value + cn.tax_amount), 0) AS total,
+			       COALESCE(SUM(cn.taxable_value + cn.tax_amount) FILTER (WHERE cn.created_at >= now() - make_interval(days => $2)), 0) AS recent
+			FROM credit_notes cn
+			JOIN invoices i ON i.id = cn.invoice_id
+			WHERE cn.shop_id = $1 AND i.status = 'PAID'
+		), paid_out AS (
+			SELECT COALESCE(SUM(p.amount_cents), 0) / 100.0 AS total,
+			       COALESCE(SUM(p.amount_cents) FILTER (WHERE p.created_at >= now() - make_interval(days => $2)), 0) / 100.0 AS recent
+			FROM payouts p
+			JOIN shops s ON s.owner_id = p.user_id
+			WHERE s.id = $1 AND p.status IN ('processing', 'success')
+		), spend AS (
+			SELECT COALESCE(SUM(amount), 0) AS total,
+			       COALESCE(SUM(amount) FILTER (WHERE spent_at >= now() - make_interval(days => $2)), 0) AS recent
+			FROM expenses
+			WHERE shop_id = $1
+		), saved AS (
+			SELECT COALESCE(SUM(current_amount), 0) AS total
+			FROM pots
+			WHERE shop_id = $1
+		)
+		SELECT sales.total - credited.total - spend.total - saved.total - paid_out.total,
+		       (spend.recent + credited.recent + paid_out.recent - sales.recent) / $2
+		FROM sales, credited, paid_out, spend, saved
+	`, shopID, burnDays)
+
+	var c CashPosition
+	if err := row.Scan(&c.Cash, &c.DailyBurn); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
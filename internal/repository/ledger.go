@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ========== LEDGER ==========
+
+const (
+	AccountAsset     = "asset"
+	AccountLiability = "liability"
+	AccountEquity    = "equity"
+	AccountIncome    = "income"
+	AccountExpense   = "expense"
+)
+
+// Account identifies a ledger account within a book by code. Name and Type
+// are used only when the account is opened by its first posting.
+type Account struct {
+	Code string
+	Name string
+	Type string
+}
+
+type JournalLine struct {
+	Account Account
+	Debit   float64
+	Credit  float64
+}
+
+// JournalEntry is built by the service for one business operation. BookID and
+// SourceID are filled in by the repository method that posts it, from the
+// row it writes.
+type JournalEntry struct {
+	BookID   uuid.UUID
+	Memo     string
+	Source   string
+	SourceID string
+	Lines    []JournalLine
+}
+
+type AccountBalance struct {
+	Code   string
+	Name   string
+	Type   string
+	Debit  float64
+	Credit float64
+}
+
+// toPaise rounds an amount to whole paise so balance checks are exact.
+func toPaise(v float64) int64 {
+	return int64(math.Round(v * 100))
+}
+
+// postJournal writes a balanced entry inside tx, opening accounts on first
+// use. Each line is netted to one side, so a negative debit posts as a
+// credit, and zero lines are dropped.
+func postJournal(ctx context.Context, tx pgx.Tx, je JournalEntry) error {
+	var (
+		lines         []JournalLine
+		debit, credit int64
+	)
+	for _, l := range je.Lines {
+		net := toPaise(l.Debit) - toPaise(l.Credit)
+		switch {
+		case net > 0:
+			debit += net
+			lines = append(lines, JournalLine{Account: l.Account, Debit: float64(net) / 100})
+		case net < 0:
+			credit -= net
+			lines = append(lines, JournalLine{Account: l.Account, Credit: float64(-net) / 100})
+		}
+	}
+	if debit != credit {
+		return fmt.Errorf("unbalanced journal entry %q: debits %.2f, credits %.2f", je.Memo, float64(debit)/100, float64(credit)/100)
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+
+	var entryID uuid.UUID
+	err := tx.QueryRow(ctx, `
+		INSERT INTO journal_entries (book_id, memo, source, source_id)
+		VALUES ($1,$2,$3,$4)
+		RETURNING id
+	`, je.BookID, je.Memo, je.Source, je.SourceID).Scan(&entryID)
+	if err != nil {
+		return err
+	}
+
+	for _, l := range lines {
+		accountID, err := openAccount(ctx, tx, je.BookID, l.Account)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `
+			INSERT INTO journal_lines (entry_id, account_id, debit, credit)
+			VALUES ($1,$2,$3,$4)
+		`, entryID, accountID, l.Debit, l.Credit)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// openAccount returns the account's id, creating it if this is its first use.
+// The no-op DO UPDATE makes RETURNING yield the existing row, including one
+// inserted concurrently by another transaction.
+func openAccount(ctx context.Context, tx pgx.Tx, bookID uuid.UUID, a Account) (uuid.UUID, error) {
+	var id uuid.UUID
+	err := tx.QueryRow(ctx, `
+		INSERT INTO ledger_accounts (book_id, code, name, type)
+		VALUES ($1,$2,$3,$4)
+		ON CONFLICT (book_id, code) DO UPDATE SET code = EXCLUDED.code
+		RETURNING id
+	`, bookID, a.Code, a.Name, a.Type).Scan(&id)
+	return id, err
+}
+
+// AccountBalances sums debits and credits per account of the book for
+// entries posted in [from, to). A nil bound is open.
+func (r *Repository) AccountBalances(ctx context.Context, bookID uuid.UUID, from, to *time.Time) ([]AccountBalance, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	rows, err := r.pool.Query(ctx, `
+		SELECT a.code, a.name, a.type,
+		       COALESCE(SUM(l.debit), 0), COALESCE(SUM(l.credit), 0)
+		FROM ledger_accounts a
+		JOIN journal_lines l ON l.account_id = a.id
+		JOIN journal_entries e ON e.id = l.entry_id
+		WHERE a.book_id = $1
+		  AND ($2::timestamptz IS NULL OR e.posted_at >= $2)
+		  AND ($3::timestamptz IS NULL OR e.posted_at < $3)
+		GROUP BY a.code, a.name, a.type
+		ORDER BY a.code
+	`, bookID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []AccountBalance
+	for rows.Next() {
+		var b AccountBalance
+		if err := rows.Scan(&b.Code, &b.Name, &b.Type, &b.Debit, &b.Credit); err != nil {
+			return nil, err
+		}
+		result = append(result, b)
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,282 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ========== PAYOUTS ==========
+
+const (
+	PayoutProcessing = "processing"
+	PayoutSuccess    = "success"
+	PayoutFailed     = "failed"
+)
+
+// ErrPayoutFinal is returned when a status change is attempted on a payout
+// that has already succeeded or failed.
+var ErrPayoutFinal = errors.New("payout is already in a final state")
+
+type Payout struct {
+	ID          string     `json:"id"`
+	UserID      *uuid.UUID `json:"-"`
+	ReferenceID *string    `json:"reference_id"`
+	AmountCents int64      `json:"amount_cents"`
+	Currency    string     `json:"currency"`
+	Method      string     `json:"method"`
+	DestVPA     *string    `json:"dest_vpa,omitempty"`
+	DestName    *string    `json:"dest_name,omitempty"`
+	DestAccount *string    `json:"dest_account,omitempty"`
+	DestIFSC    *string    `json:"dest_ifsc,omitempty"`
+	Status      string     `json:"status"`
+	UTR         *string    `json:"utr"`
+	Error       *string    `json:"error"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+type PayoutEvent struct {
+	ID        string          `json:"id"`
+	PayoutID  string          `json:"payout_id"`
+	Event     string          `json:"event"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// NewID returns prefix followed by 24 random hex characters, e.g. po_3f9c...
+func NewID(prefix string) string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return prefix + hex.EncodeToString(b)
+}
+
+const payoutColumns = `id, user_id, reference_id, amount_cents, currency, method, dest_vpa, dest_name,
+	dest_account, dest_ifsc, status, utr, error, created_at, updated_at`
+
+func scanPayout(row pgx.Row) (*Payout, error) {
+	var p Payout
+	err := row.Scan(&p.ID, &p.UserID, &p.ReferenceID, &p.AmountCents, &p.Currency, &p.Method, &p.DestVPA, &p.DestName,
+		&p.DestAccount, &p.DestIFSC, &p.Status, &p.UTR, &p.Error, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// insertPayoutEvent records payout.<status> with a snapshot of the payout and
+// queues it for the owner's webhook endpoints.
+func insertPayoutEvent(ctx context.Context, tx pgx.Tx, p *Payout) error {
+	payload, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	event := "payout." + p.Status
+	_, err = tx.Exec(ctx, `
+		INSERT INTO payout_events (id, payout_id, event, payload)
+		VALUES ($1,$2,$3,$4)
+	`, NewID("evt_"), p.ID, event, payload)
+	if err != nil {
+		return err
+	}
+	if p.UserID == nil {
+		return nil
+	}
+	_, err = enqueueWebhook(ctx, tx, *p.UserID, event, p)
+	return err
+}
+
+// payoutBook is the ledger book for a payout: its owner, or the nil UUID for
+// payouts created with a key that belongs to no user.
+func payoutBook(p *Payout) uuid.UUID {
+	if p.UserID == nil {
+		return uuid.Nil
+	}
+	return *p.UserID
+}
+
+// CreatePayout inserts the payout, its first event and je in one transaction.
+func (r *Repository) CreatePayout(ctx context.Context, p Payout, je JournalEntry) (*Payout, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.txTimeout)
+	defer cancel()
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	created, err := scanPayout(tx.QueryRow(ctx, `
+		INSERT INTO payouts (id, user_id, reference_id, amount_cents, currency, method, dest_vpa, dest_name,
+		                     dest_account, dest_ifsc, status)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11)
+		RETURNING `+payoutColumns,
+		p.ID, p.UserID, p.ReferenceID, p.AmountCents, p.Currency, p.Method, p.DestVPA, p.DestName,
+		p.DestAccount, p.DestIFSC, p.Status))
+	if err != nil {
+		return nil, err
+	}
+	if err := insertPayoutEvent(ctx, tx, created); err != nil {
+		return nil, err
+	}
+	je.BookID, je.SourceID = payoutBook(created), created.ID
+	if err := postJournal(ctx, tx, je); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// UpdatePayoutStatus moves a processing payout to status and writes the
+// matching event and je in the same transaction. Final payouts are never
+// changed.
+func (r *Repository) UpdatePayoutStatus(ctx context.Context, id, status string, utr, errMsg *string, je JournalEntry) (*Payout, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.txTimeout)
+	defer cancel()
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	current, err := scanPayout(tx.QueryRow(ctx, `
+		SELECT `+payoutColumns+`
+		FROM payouts
+		WHERE id = $1
+		FOR UPDATE
+	`, id))
+	if err != nil {
+		return nil, err
+	}
+	if current.Status != PayoutProcessing {
+		return nil, ErrPayoutFinal
+	}
+
+	updated, err := scanPayout(tx.QueryRow(ctx, `
+		UPDATE payouts
+		SET status = $2, utr = $3, error = $4, updated_at = now()
+		WHERE id = $1
+		RETURNING `+payoutColumns,
+		id, status, utr, errMsg))
+	if err != nil {
+		return nil, err
+	}
+	if err := insertPayoutEvent(ctx, tx, updated); err != nil {
+		return nil, err
+	}
+	je.BookID, je.SourceID = payoutBook(updated), updated.ID
+	if err := postJournal(ctx, tx, je); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// GetPayout returns the payout owned by userID; a nil userID matches only
+// payouts without an owner. Anyone else's payout is pgx.ErrNoRows.
+func (r *Repository) GetPayout(ctx context.Context, id string, userID *uuid.UUID) (*Payout, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	return scanPayout(r.pool.QueryRow(ctx, `
+		SELECT `+payoutColumns+`
+		FROM payouts
+		WHERE id = $1 AND user_id IS NOT DISTINCT FROM $2
+	`, id, userID))
+}
+
+// ListPayouts returns userID's payouts newest first; a nil userID lists the
+// payouts without an owner. A non-nil before restricts the page to payouts
+// created strictly before that time (keyset pagination).
+func (r *Repository) ListPayouts(ctx context.Context, userID *uuid.UUID, status string, before *time.Time, limit int) ([]Payout, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	rows, err := r.pool.Query(ctx, `
+		SELECT `+payoutColumns+`
+		FROM payouts
+		WHERE user_id IS NOT DISTINCT FROM $1
+		  AND ($2 = '' OR status = $2)
+		  AND ($3::timestamptz IS NULL OR created_at < $3)
+		ORDER BY created_at DESC
+		LIMIT $4
+	`, userID, status, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	return collectPayouts(rows)
+}
+
+// ListProcessingPayouts returns up to limit processing payouts of every
+// owner, newest first, for the reconciler.
+func (r *Repository) ListProcessingPayouts(ctx context.Context, limit int) ([]Payout, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	rows, err := r.pool.Query(ctx, `
+		SELECT `+payoutColumns+`
+		FROM payouts
+		WHERE status = $1
+		ORDER BY created_at DESC
+		LIMIT $2
+	`, PayoutProcessing, limit)
+	if err != nil {
+		return nil, err
+	}
+	return collectPayouts(rows)
+}
+
+func collectPayouts(rows pgx.Rows) ([]Payout, error) {
+	defer rows.Close()
+
+	var result []Payout
+	for rows.Next() {
+		p, err := scanPayout(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *p)
+	}
+	return result, rows.Err()
+}
+
+func (r *Repository) ListPayoutEvents(ctx context.Context, payoutID string) ([]PayoutEvent, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	rows, err := r.pool.Query(ctx, `
+		SELECT id, payout_id, event, payload, created_at
+		FROM payout_events
+		WHERE payout_id = $1
+		ORDER BY created_at
+	`, payoutID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []PayoutEvent
+	for rows.Next() {
+		var e PayoutEvent
+		if err := rows.Scan(&e.ID, &e.PayoutID, &e.Event, &e.Payload, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		result = append(result, e)
+	}
+	return result, rows.Err()
+}
@@ -3,21 +3,39 @@ package repository
 import (
 	"context"
 	"errors"
+	"fmt"
 	"time"
 
+	"fintech-backend/internal/metrics"
+
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-const timeout = 5 * time.Second
+// Default statement timeouts, used when New is given zero.
+const (
+	DefaultQueryTimeout = 5 * time.Second
+	DefaultTxTimeout    = 10 * time.Second
+)
 
 type Repository struct {
 	pool *pgxpool.Pool
+
+	// queryTimeout bounds single statements, txTimeout whole transactions.
+	// Either way the caller's deadline still applies if it is sooner.
+	queryTimeout time.Duration
+	txTimeout    time.Duration
 }
 
-func New(pool *pgxpool.Pool) *Repository {
-	return &Repository{pool: pool}
+func New(pool *pgxpool.Pool, queryTimeout, txTimeout time.Duration) *Repository {
+	if queryTimeout <= 0 {
+		queryTimeout = DefaultQueryTimeout
+	}
+	if txTimeout <= 0 {
+		txTimeout = DefaultTxTimeout
+	}
+	return &Repository{pool: pool, queryTimeout: queryTimeout, txTimeout: txTimeout}
 }
 
 // ========== USERS / SHOPS ==========
@@ -35,11 +53,12 @@ type Shop struct {
 	Address   string
 	GSTNumber string
 	OwnerID   uuid.UUID
+	Timezone  string
 	CreatedAt time.Time
 }
 
 func (r *Repository) GetUserByEmail(ctx context.Context, email string) (*User, error) {
-	ctx, cancel := context.WithTimeout(ctx, timeout)
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
 	defer cancel()
 
 	row := r.pool.QueryRow(ctx, `
@@ -56,7 +75,7 @@ func (r *Repository) GetUserByEmail(ctx context.Context, email string) (*User, e
 }
 
 func (r *Repository) GetUserByAPIKey(ctx context.Context, apiKey string) (*User, error) {
-	ctx, cancel := context.WithTimeout(ctx, timeout)
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
 	defer cancel()
 
 	row := r.pool.QueryRow(ctx, `
@@ -72,17 +91,38 @@ func (r *Repository) GetUserByAPIKey(ctx context.Context, apiKey string) (*User,
 	return &u, nil
 }
 
-func (r *Repository) CreateShop(ctx context.Context, ownerID uuid.UUID, name, address, gst string) (*Shop, error) {
-	ctx, cancel := context.WithTimeout(ctx, timeout)
+// EnsureUser returns the user with email, creating one with a fresh API key
+// if there is none yet.
+func (r *Repository) EnsureUser(ctx context.Context, email, name string) (*User, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	// The no-op update makes RETURNING yield the existing row on conflict.
+	row := r.pool.QueryRow(ctx, `
+		INSERT INTO users (name, email, api_key)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (email) DO UPDATE SET email = EXCLUDED.email
+		RETURNING id, name, email, api_key
+	`, name, email, NewID("sk_")+NewID(""))
+
+	var u User
+	if err := row.Scan(&u.ID, &u.Name, &u.Email, &u.APIKey); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (r *Repository) CreateShop(ctx context.Context, ownerID uuid.UUID, name, address, gst, tz string) (*Shop, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
 	defer cancel()
 
 	var s Shop
 	err := r.pool.QueryRow(ctx, `
-		INSERT INTO shops (owner_id, name, address, gst_number)
-		VALUES ($1,$2,$3,$4)
-		RETURNING id, owner_id, name, address, gst_number, created_at
-	`, ownerID, name, address, gst).Scan(
-		&s.ID, &s.OwnerID, &s.Name, &s.Address, &s.GSTNumber, &s.CreatedAt,
+		INSERT INTO shops (owner_id, name, address, gst_number, timezone)
+		VALUES ($1,$2,$3,$4,$5)
+		RETURNING id, owner_id, name, address, gst_number, timezone, created_at
+	`, ownerID, name, address, gst, tz).Scan(
+		&s.ID, &s.OwnerID, &s.Name, &s.Address, &s.GSTNumber, &s.Timezone, &s.CreatedAt,
 	)
 	if err != nil {
 		return nil, err
@@ -91,11 +131,11 @@ func (r *Repository) CreateShop(ctx context.Context, ownerID uuid.UUID, name, ad
 }
 
 func (r *Repository) ListShopsByUser(ctx context.Context, ownerID uuid.UUID) ([]Shop, error) {
-	ctx, cancel := context.WithTimeout(ctx, timeout)
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
 	defer cancel()
 
 	rows, err := r.pool.Query(ctx, `
-		SELECT id, owner_id, name, address, gst_number, created_at
+		SELECT id, owner_id, name, address, gst_number, timezone, created_at
 		FROM shops
 		WHERE owner_id = $1
 		ORDER BY created_at DESC
@@ -108,7 +148,7 @@ func (r *Repository) ListShopsByUser(ctx context.Context, ownerID uuid.UUID) ([]
 	var result []Shop
 	for rows.Next() {
 		var s Shop
-		if err := rows.Scan(&s.ID, &s.OwnerID, &s.Name, &s.Address, &s.GSTNumber, &s.CreatedAt); err != nil {
+		if err := rows.Scan(&s.ID, &s.OwnerID, &s.Name, &s.Address, &s.GSTNumber, &s.Timezone, &s.CreatedAt); err != nil {
 			return nil, err
 		}
 		result = append(result, s)
@@ -117,17 +157,36 @@ func (r *Repository) ListShopsByUser(ctx context.Context, ownerID uuid.UUID) ([]
 }
 
 func (r *Repository) GetShopByID(ctx context.Context, id uuid.UUID) (*Shop, error) {
-	ctx, cancel := context.WithTimeout(ctx, timeout)
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
 	defer cancel()
 
 	row := r.pool.QueryRow(ctx, `
-		SELECT id, owner_id, name, address, gst_number, created_at
+		SELECT id, owner_id, name, address, gst_number, timezone, created_at
 		FROM shops
 		WHERE id = $1
 	`, id)
 
 	var s Shop
-	if err := row.Scan(&s.ID, &s.OwnerID, &s.Name, &s.Address, &s.GSTNumber, &s.CreatedAt); err != nil {
+	if err := row.Scan(&s.ID, &s.OwnerID, &s.Name, &s.Address, &s.GSTNumber, &s.Timezone, &s.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// UpdateShop overwrites the shop's editable fields.
+func (r *Repository) UpdateShop(ctx context.Context, s Shop) (*Shop, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	err := r.pool.QueryRow(ctx, `
+		UPDATE shops
+		SET name = $2, address = $3, gst_number = $4, timezone = $5
+		WHERE id = $1
+		RETURNING id, owner_id, name, address, gst_number, timezone, created_at
+	`, s.ID, s.Name, s.Address, s.GSTNumber, s.Timezone).Scan(
+		&s.ID, &s.OwnerID, &s.Name, &s.Address, &s.GSTNumber, &s.Timezone, &s.CreatedAt,
+	)
+	if err != nil {
 		return nil, err
 	}
 	return &s, nil
@@ -144,17 +203,19 @@ type Product struct {
 	CostPrice         float64
 	SellingPrice      float64
 	LowStockThreshold int
+	HSNCode           *string
+	GSTRate           float64
 }
 
 func (r *Repository) CreateProduct(ctx context.Context, p Product) (*Product, error) {
-	ctx, cancel := context.WithTimeout(ctx, timeout)
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
 	defer cancel()
 
 	err := r.pool.QueryRow(ctx, `
-		INSERT INTO products (shop_id, name, sku, stock, cost_price, selling_price, low_stock_threshold)
-		VALUES ($1,$2,$3,$4,$5,$6,$7)
+		INSERT INTO products (shop_id, name, sku, stock, cost_price, selling_price, low_stock_threshold, hsn_code, gst_rate)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9)
 		RETURNING id
-	`, p.ShopID, p.Name, p.SKU, p.Stock, p.CostPrice, p.SellingPrice, p.LowStockThreshold).
+	`, p.ShopID, p.Name, p.SKU, p.Stock, p.CostPrice, p.SellingPrice, p.LowStockThreshold, p.HSNCode, p.GSTRate).
 		Scan(&p.ID)
 	if err != nil {
 		return nil, err
@@ -163,11 +224,11 @@ func (r *Repository) CreateProduct(ctx context.Context, p Product) (*Product, er
 }
 
 func (r *Repository) ListProductsByShop(ctx context.Context, shopID uuid.UUID) ([]Product, error) {
-	ctx, cancel := context.WithTimeout(ctx, timeout)
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
 	defer cancel()
 
 	rows, err := r.pool.Query(ctx, `
-		SELECT id, shop_id, name, sku, stock, cost_price, selling_price, low_stock_threshold
+		SELECT id, shop_id, name, sku, stock, cost_price, selling_price, low_stock_threshold, hsn_code, gst_rate
 		FROM products
 		WHERE shop_id = $1
 		ORDER BY name
@@ -180,7 +241,7 @@ func (r *Repository) ListProductsByShop(ctx context.Context, shopID uuid.UUID) (
 	var result []Product
 	for rows.Next() {
 		var p Product
-		if err := rows.Scan(&p.ID, &p.ShopID, &p.Name, &p.SKU, &p.Stock, &p.CostPrice, &p.SellingPrice, &p.LowStockThreshold); err != nil {
+		if err := rows.Scan(&p.ID, &p.ShopID, &p.Name, &p.SKU, &p.Stock, &p.CostPrice, &p.SellingPrice, &p.LowStockThreshold, &p.HSNCode, &p.GSTRate); err != nil {
 			return nil, err
 		}
 		result = append(result, p)
@@ -188,21 +249,82 @@ func (r *Repository) ListProductsByShop(ctx context.Context, shopID uuid.UUID) (
 	return result, nil
 }
 
+func (r *Repository) GetProduct(ctx context.Context, id uuid.UUID) (*Product, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	row := r.pool.QueryRow(ctx, `
+		SELECT id, shop_id, name, sku, stock, cost_price, selling_price, low_stock_threshold, hsn_code, gst_rate
+		FROM products
+		WHERE id = $1
+	`, id)
+
+	var p Product
+	if err := row.Scan(&p.ID, &p.ShopID, &p.Name, &p.SKU, &p.Stock, &p.CostPrice, &p.SellingPrice, &p.LowStockThreshold, &p.HSNCode, &p.GSTRate); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// UpdateProduct overwrites the product's catalogue fields. Stock is left
+// alone; it only moves through invoices.
+func (r *Repository) UpdateProduct(ctx context.Context, p Product) (*Product, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	err := r.pool.QueryRow(ctx, `
+		UPDATE products
+		SET name = $2, sku = $3, cost_price = $4, selling_price = $5, low_stock_threshold = $6, hsn_code = $7, gst_rate = $8
+		WHERE id = $1
+		RETURNING stock
+	`, p.ID, p.Name, p.SKU, p.CostPrice, p.SellingPrice, p.LowStockThreshold, p.HSNCode, p.GSTRate).Scan(&p.Stock)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
 func getProductForUpdate(ctx context.Context, tx pgx.Tx, productID uuid.UUID) (*Product, error) {
 	row := tx.QueryRow(ctx, `
-		SELECT id, shop_id, name, sku, stock, cost_price, selling_price, low_stock_threshold
+		SELECT id, shop_id, name, sku, stock, cost_price, selling_price, low_stock_threshold, hsn_code, gst_rate
 		FROM products
 		WHERE id = $1
 		FOR UPDATE
 	`, productID)
 
 	var p Product
-	if err := row.Scan(&p.ID, &p.ShopID, &p.Name, &p.SKU, &p.Stock, &p.CostPrice, &p.SellingPrice, &p.LowStockThreshold); err != nil {
+	if err := row.Scan(&p.ID, &p.ShopID, &p.Name, &p.SKU, &p.Stock, &p.CostPrice, &p.SellingPrice, &p.LowStockThreshold, &p.HSNCode, &p.GSTRate); err != nil {
 		return nil, err
 	}
 	return &p, nil
 }
 
+// GetProductsByIDs returns the products with the given ids, keyed by id.
+func (r *Repository) GetProductsByIDs(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]Product, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	rows, err := r.pool.Query(ctx, `
+		SELECT id, shop_id, name, sku, stock, cost_price, selling_price, low_stock_threshold, hsn_code, gst_rate
+		FROM products
+		WHERE id = ANY($1)
+	`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[uuid.UUID]Product, len(ids))
+	for rows.Next() {
+		var p Product
+		if err := rows.Scan(&p.ID, &p.ShopID, &p.Name, &p.SKU, &p.Stock, &p.CostPrice, &p.SellingPrice, &p.LowStockThreshold, &p.HSNCode, &p.GSTRate); err != nil {
+			return nil, err
+		}
+		result[p.ID] = p
+	}
+	return result, rows.Err()
+}
+
 // ========== INVOICES ==========
 
 type Invoice struct {
@@ -213,19 +335,45 @@ type Invoice struct {
 	TotalAmount   float64
 	TaxAmount     float64
 	Status        string
+	DueDate       *time.Time
+	CustomerGSTIN *string
+	PlaceOfSupply *string
 	CreatedAt     time.Time
 }
 
+// ErrInsufficientStock is returned when an invoice asks for more units than a
+// product has in stock.
+var ErrInsufficientStock = errors.New("not enough stock")
+
+// StockError is the ErrInsufficientStock of one product. It carries IDs and
+// counts only, so its message never depends on user-entered names.
+type StockError struct {
+	ProductID uuid.UUID
+	Requested int
+	Available int
+}
+
+func (e *StockError) Error() string {
+	return fmt.Sprintf("%s for product %s", ErrInsufficientStock, e.ProductID)
+}
+
+func (e *StockError) Unwrap() error { return ErrInsufficientStock }
+
 type InvoiceItem struct {
 	ID        uuid.UUID
 	InvoiceID uuid.UUID
 	ProductID uuid.UUID
 	Quantity  int
 	UnitPrice float64
+	HSNCode   *string
+	GSTRate   float64
+	TaxAmount float64
 }
 
-func (r *Repository) CreateInvoiceWithItems(ctx context.Context, inv Invoice, items []InvoiceItem) (*Invoice, error) {
-	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+// CreateInvoiceWithItems decrements stock, writes the invoice and its items
+// and posts je for it, all in one transaction.
+func (r *Repository) CreateInvoiceWithItems(ctx context.Context, inv Invoice, items []InvoiceItem, je JournalEntry) (*Invoice, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.txTimeout)
 	defer cancel()
 
 	tx, err := r.pool.Begin(ctx)
@@ -234,6 +382,8 @@ func (r *Repository) CreateInvoiceWithItems(ctx context.Context, inv Invoice, it
 	}
 	defer tx.Rollback(ctx)
 
+	var lowStock []map[string]any
+	soldOut := 0
 	for i := range items {
 		item := &items[i]
 
@@ -245,7 +395,8 @@ func (r *Repository) CreateInvoiceWithItems(ctx context.Context, inv Invoice, it
 			return nil, errors.New("quantity must be positive")
 		}
 		if p.Stock < item.Quantity {
-			return nil, errors.New("not enough stock for product: " + p.Name)
+			metrics.Stockouts.WithLabelValues("rejected").Inc()
+			return nil, &StockError{ProductID: p.ID, Requested: item.Quantity, Available: p.Stock}
 		}
 
 		newStock := p.Stock - item.Quantity
@@ -257,13 +408,28 @@ func (r *Repository) CreateInvoiceWithItems(ctx context.Context, inv Invoice, it
 		if err != nil {
 			return nil, err
 		}
+		if newStock == 0 {
+			soldOut++
+		}
+		if p.Stock > p.LowStockThreshold && newStock <= p.LowStockThreshold {
+			lowStock = append(lowStock, map[string]any{
+				"shop_id":             p.ShopID,
+				"product_id":          p.ID,
+				"name":                p.Name,
+				"sku":                 p.SKU,
+				"stock":               newStock,
+				"low_stock_threshold": p.LowStockThreshold,
+			})
+		}
 	}
 
 	err = tx.QueryRow(ctx, `
-		INSERT INTO invoices (shop_id, customer_name, customer_phone, total_amount, tax_amount, status)
-		VALUES ($1,$2,$3,$4,$5,$6)
+		INSERT INTO invoices (shop_id, customer_name, customer_phone, total_amount, tax_amount, status, due_date,
+		                      customer_gstin, place_of_supply)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9)
 		RETURNING id, created_at
-	`, inv.ShopID, inv.CustomerName, inv.CustomerPhone, inv.TotalAmount, inv.TaxAmount, inv.Status).
+	`, inv.ShopID, inv.CustomerName, inv.CustomerPhone, inv.TotalAmount, inv.TaxAmount, inv.Status, inv.DueDate,
+		inv.CustomerGSTIN, inv.PlaceOfSupply).
 		Scan(&inv.ID, &inv.CreatedAt)
 	if err != nil {
 		return nil, err
@@ -273,28 +439,79 @@ func (r *Repository) CreateInvoiceWithItems(ctx context.Context, inv Invoice, it
 		item := &items[i]
 		item.InvoiceID = inv.ID
 		err = tx.QueryRow(ctx, `
-			INSERT INTO invoice_items (invoice_id, product_id, quantity, unit_price)
-			VALUES ($1,$2,$3,$4)
+			INSERT INTO invoice_items (invoice_id, product_id, quantity, unit_price, hsn_code, gst_rate, tax_amount)
+			VALUES ($1,$2,$3,$4,$5,$6,$7)
 			RETURNING id
-		`, item.InvoiceID, item.ProductID, item.Quantity, item.UnitPrice).
+		`, item.InvoiceID, item.ProductID, item.Quantity, item.UnitPrice, item.HSNCode, item.GSTRate, item.TaxAmount).
 			Scan(&item.ID)
 		if err != nil {
 			return nil, err
 		}
 	}
 
+	je.BookID, je.SourceID = inv.ShopID, inv.ID.String()
+	if err := postJournal(ctx, tx, je); err != nil {
+		return nil, err
+	}
+
+	if err := enqueueInvoiceWebhooks(ctx, tx, &inv, items, lowStock); err != nil {
+		return nil, err
+	}
+
 	if err := tx.Commit(ctx); err != nil {
 		return nil, err
 	}
+	metrics.Stockouts.WithLabelValues("sold_out").Add(float64(soldOut))
 	return &inv, nil
 }
 
+// enqueueInvoiceWebhooks queues invoice.created, and stock.low for every
+// product the sale took to or below its threshold, for the shop owner.
+func enqueueInvoiceWebhooks(ctx context.Context, tx pgx.Tx, inv *Invoice, items []InvoiceItem, lowStock []map[string]any) error {
+	var ownerID uuid.UUID
+	if err := tx.QueryRow(ctx, `SELECT owner_id FROM shops WHERE id = $1`, inv.ShopID).Scan(&ownerID); err != nil {
+		return err
+	}
+
+	lines := make([]map[string]any, 0, len(items))
+	for _, it := range items {
+		lines = append(lines, map[string]any{
+			"product_id": it.ProductID,
+			"quantity":   it.Quantity,
+			"unit_price": it.UnitPrice,
+		})
+	}
+	_, err := enqueueWebhook(ctx, tx, ownerID, "invoice.created", map[string]any{
+		"id":             inv.ID,
+		"shop_id":        inv.ShopID,
+		"customer_name":  inv.CustomerName,
+		"customer_phone": inv.CustomerPhone,
+		"total_amount":   inv.TotalAmount,
+		"tax_amount":     inv.TaxAmount,
+		"status":         inv.Status,
+		"due_date":       inv.DueDate,
+		"items":          lines,
+		"created_at":     inv.CreatedAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, p := range lowStock {
+		if _, err := enqueueWebhook(ctx, tx, ownerID, "stock.low", p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (r *Repository) ListInvoicesByShop(ctx context.Context, shopID uuid.UUID) ([]Invoice, error) {
-	ctx, cancel := context.WithTimeout(ctx, timeout)
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
 	defer cancel()
 
 	rows, err := r.pool.Query(ctx, `
-		SELECT id, shop_id, customer_name, customer_phone, total_amount, tax_amount, status, created_at
+		SELECT id, shop_id, customer_name, customer_phone, total_amount, tax_amount, status, due_date,
+		       customer_gstin, place_of_supply, created_at
 		FROM invoices
 		WHERE shop_id = $1
 		ORDER BY created_at DESC
@@ -307,7 +524,8 @@ func (r *Repository) ListInvoicesByShop(ctx context.Context, shopID uuid.UUID) (
 	var result []Invoice
 	for rows.Next() {
 		var iv Invoice
-		if err := rows.Scan(&iv.ID, &iv.ShopID, &iv.CustomerName, &iv.CustomerPhone, &iv.TotalAmount, &iv.TaxAmount, &iv.Status, &iv.CreatedAt); err != nil {
+		if err := rows.Scan(&iv.ID, &iv.ShopID, &iv.CustomerName, &iv.CustomerPhone, &iv.TotalAmount, &iv.TaxAmount, &iv.Status, &iv.DueDate,
+			&iv.CustomerGSTIN, &iv.PlaceOfSupply, &iv.CreatedAt); err != nil {
 			return nil, err
 		}
 		result = append(result, iv)
@@ -315,6 +533,60 @@ func (r *Repository) ListInvoicesByShop(ctx context.Context, shopID uuid.UUID) (
 	return result, nil
 }
 
+// ErrInvoiceNotCredit is returned when settling an invoice that is not on
+// credit, including one already settled.
+var ErrInvoiceNotCredit = errors.New("invoice is not on credit")
+
+// SettleInvoice marks a CREDIT invoice PAID and posts the entry journal builds
+// for what the customer still owed: the total less credit notes. The invoice
+// row is locked, so a concurrent credit note or settlement waits for it.
+func (r *Repository) SettleInvoice(ctx context.Context, id uuid.UUID, journal func(inv *Invoice, outstanding float64) JournalEntry) (*Invoice, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.txTimeout)
+	defer cancel()
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var (
+		iv       Invoice
+		credited float64
+	)
+	err = tx.QueryRow(ctx, `
+		SELECT i.id, i.shop_id, COALESCE(i.customer_name, ''), COALESCE(i.customer_phone, ''), i.total_amount, i.tax_amount,
+		       i.status, i.due_date, i.customer_gstin, i.place_of_supply, i.created_at,
+		       (SELECT COALESCE(SUM(c.taxable_value + c.tax_amount), 0) FROM credit_notes c WHERE c.invoice_id = i.id)
+		FROM invoices i
+		WHERE i.id = $1
+		FOR UPDATE
+	`, id).Scan(&iv.ID, &iv.ShopID, &iv.CustomerName, &iv.CustomerPhone, &iv.TotalAmount, &iv.TaxAmount,
+		&iv.Status, &iv.DueDate, &iv.CustomerGSTIN, &iv.PlaceOfSupply, &iv.CreatedAt, &credited)
+	if err != nil {
+		return nil, err
+	}
+	if iv.Status != "CREDIT" {
+		return nil, ErrInvoiceNotCredit
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE invoices SET status = 'PAID' WHERE id = $1`, id); err != nil {
+		return nil, err
+	}
+	je := journal(&iv, float64(toPaise(iv.TotalAmount)-toPaise(credited))/100)
+	iv.Status = "PAID"
+
+	je.BookID, je.SourceID = iv.ShopID, iv.ID.String()
+	if err := postJournal(ctx, tx, je); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &iv, nil
+}
+
 // ========== EXPENSES ==========
 
 type Expense struct {
@@ -323,31 +595,52 @@ type Expense struct {
 	Category string
 	Amount   float64
 	Note     *string
-	SpentAt  time.Time
+	Mood     *string
+	// InputTax is the GST included in Amount, claimable as input tax credit
+	// when SupplierGSTIN is set.
+	SupplierGSTIN *string
+	InputTax      float64
+	SpentAt       time.Time
 }
 
-func (r *Repository) CreateExpense(ctx context.Context, e Expense) (*Expense, error) {
-	ctx, cancel := context.WithTimeout(ctx, timeout)
+// CreateExpense writes the expense and posts je for it in one transaction.
+func (r *Repository) CreateExpense(ctx context.Context, e Expense, je JournalEntry) (*Expense, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.txTimeout)
 	defer cancel()
 
-	err := r.pool.QueryRow(ctx, `
-		INSERT INTO expenses (shop_id, category, amount, note)
-		VALUES ($1,$2,$3,$4)
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO expenses (shop_id, category, amount, note, mood, supplier_gstin, input_tax)
+		VALUES ($1,$2,$3,$4,$5,$6,$7)
 		RETURNING id, spent_at
-	`, e.ShopID, e.Category, e.Amount, e.Note).
+	`, e.ShopID, e.Category, e.Amount, e.Note, e.Mood, e.SupplierGSTIN, e.InputTax).
 		Scan(&e.ID, &e.SpentAt)
 	if err != nil {
 		return nil, err
 	}
+
+	je.BookID, je.SourceID = e.ShopID, e.ID.String()
+	if err := postJournal(ctx, tx, je); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
 	return &e, nil
 }
 
 func (r *Repository) ListExpensesByShop(ctx context.Context, shopID uuid.UUID) ([]Expense, error) {
-	ctx, cancel := context.WithTimeout(ctx, timeout)
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
 	defer cancel()
 
 	rows, err := r.pool.Query(ctx, `
-		SELECT id, shop_id, category, amount, note, spent_at
+		SELECT id, shop_id, category, amount, note, mood, supplier_gstin, input_tax, spent_at
 		FROM expenses
 		WHERE shop_id = $1
 		ORDER BY spent_at DESC
@@ -360,7 +653,7 @@ func (r *Repository) ListExpensesByShop(ctx context.Context, shopID uuid.UUID) (
 	var result []Expense
 	for rows.Next() {
 		var e Expense
-		if err := rows.Scan(&e.ID, &e.ShopID, &e.Category, &e.Amount, &e.Note, &e.SpentAt); err != nil {
+		if err := rows.Scan(&e.ID, &e.ShopID, &e.Category, &e.Amount, &e.Note, &e.Mood, &e.SupplierGSTIN, &e.InputTax, &e.SpentAt); err != nil {
 			return nil, err
 		}
 		result = append(result, e)
@@ -380,7 +673,7 @@ type Pot struct {
 }
 
 func (r *Repository) CreatePot(ctx context.Context, p Pot) (*Pot, error) {
-	ctx, cancel := context.WithTimeout(ctx, timeout)
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
 	defer cancel()
 
 	err := r.pool.QueryRow(ctx, `
@@ -395,16 +688,40 @@ func (r *Repository) CreatePot(ctx context.Context, p Pot) (*Pot, error) {
 	return &p, nil
 }
 
-func (r *Repository) DepositToPot(ctx context.Context, potID uuid.UUID, amount float64) (*Pot, error) {
+func (r *Repository) GetPot(ctx context.Context, potID uuid.UUID) (*Pot, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var p Pot
+	err := r.pool.QueryRow(ctx, `
+		SELECT id, shop_id, name, target_amount, current_amount, created_at
+		FROM pots
+		WHERE id = $1
+	`, potID).
+		Scan(&p.ID, &p.ShopID, &p.Name, &p.TargetAmount, &p.CurrentAmount, &p.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// DepositToPot adds amount to the pot and posts je for it in one transaction.
+func (r *Repository) DepositToPot(ctx context.Context, potID uuid.UUID, amount float64, je JournalEntry) (*Pot, error) {
 	if amount <= 0 {
 		return nil, errors.New("amount must be positive")
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, timeout)
+	ctx, cancel := context.WithTimeout(ctx, r.txTimeout)
 	defer cancel()
 
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
 	var p Pot
-	err := r.pool.QueryRow(ctx, `
+	err = tx.QueryRow(ctx, `
 		UPDATE pots
 		SET current_amount = current_amount + $1
 		WHERE id = $2
@@ -414,11 +731,20 @@ func (r *Repository) DepositToPot(ctx context.Context, potID uuid.UUID, amount f
 	if err != nil {
 		return nil, err
 	}
+
+	je.BookID, je.SourceID = p.ShopID, p.ID.String()
+	if err := postJournal(ctx, tx, je); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
 	return &p, nil
 }
 
 func (r *Repository) ListPotsByShop(ctx context.Context, shopID uuid.UUID) ([]Pot, error) {
-	ctx, cancel := context.WithTimeout(ctx, timeout)
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
 	defer cancel()
 
 	rows, err := r.pool.Query(ctx, `
@@ -445,38 +771,39 @@ func (r *Repository) ListPotsByShop(ctx context.Context, shopID uuid.UUID) ([]Po
 
 // ========== DASHBOARD / COACH HELPERS ==========
 
-func (r *Repository) SumRevenueLastDays(ctx context.Context, shopID uuid.UUID, days int) (float64, error) {
-	ctx, cancel := context.WithTimeout(ctx, timeout)
-	defer cancel()
-
-	row := r.pool.QueryRow(ctx, `
-		SELECT COALESCE(SUM(total_amount),0)
-		FROM invoices
-		WHERE shop_id = $1
-		  AND created_at >= now() - ($2 || ' days')::interval
-	`, shopID, days)
-
-	var total float64
-	if err := row.Scan(&total); err != nil {
-		return 0, err
-	}
-	return total, nil
+// RollingTotals holds revenue and expenses over rolling 7- and 30-day windows.
+type RollingTotals struct {
+	Revenue7   float64
+	Expenses7  float64
+	Revenue30  float64
+	Expenses30 float64
 }
 
-func (r *Repository) SumExpensesLastDays(ctx context.Context, shopID uuid.UUID, days int) (float64, error) {
-	ctx, cancel := context.WithTimeout(ctx, timeout)
+func (r *Repository) RollingTotals(ctx context.Context, shopID uuid.UUID) (*RollingTotals, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
 	defer cancel()
 
 	row := r.pool.QueryRow(ctx, `
-		SELECT COALESCE(SUM(amount),0)
-		FROM expenses
-		WHERE shop_id = $1
-		  AND spent_at >= now() - ($2 || ' days')::interval
-	`, shopID, days)
+		WITH sales AS (
+			SELECT COALESCE(SUM(total_amount) FILTER (WHERE created_at >= now() - interval '7 days'), 0) AS r7,
+			       COALESCE(SUM(total_amount), 0) AS r30
+			FROM invoices
+			WHERE shop_id = $1
+			  AND created_at >= now() - interval '30 days'
+		), spend AS (
+			SELECT COALESCE(SUM(amount) FILTER (WHERE spent_at >= now() - interval '7 days'), 0) AS e7,
+			       COALESCE(SUM(amount), 0) AS e30
+			FROM expenses
+			WHERE shop_id = $1
+			  AND spent_at >= now() - interval '30 days'
+		)
+		SELECT sales.r7, spend.e7, sales.r30, spend.e30
+		FROM sales, spend
+	`, shopID)
 
-	var total float64
-	if err := row.Scan(&total); err != nil {
-		return 0, err
+	var t RollingTotals
+	if err := row.Scan(&t.Revenue7, &t.Expenses7, &t.Revenue30, &t.Expenses30); err != nil {
+		return nil, err
 	}
-	return total, nil
+	return &t, nil
 }
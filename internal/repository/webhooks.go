@@ -0,0 +1,301 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ========== WEBHOOKS ==========
+
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+type WebhookEndpoint struct {
+	ID        string    `json:"id"`
+	UserID    uuid.UUID `json:"-"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type WebhookDelivery struct {
+	ID             string          `json:"id"`
+	EndpointID     string          `json:"endpoint_id"`
+	EventID        string          `json:"event_id"`
+	Event          string          `json:"event"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	LastStatusCode *int            `json:"last_status_code"`
+	LastError      *string         `json:"last_error"`
+	DeliveredAt    *time.Time      `json:"delivered_at"`
+	CreatedAt      time.Time       `json:"created_at"`
+
+	// Set only on deliveries claimed for sending.
+	URL    string `json:"-"`
+	Secret string `json:"-"`
+}
+
+// WebhookEnvelope is the JSON body every endpoint receives.
+type WebhookEnvelope struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+func (r *Repository) CreateWebhookEndpoint(ctx context.Context, e WebhookEndpoint) (*WebhookEndpoint, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	err := r.pool.QueryRow(ctx, `
+		INSERT INTO webhook_endpoints (id, user_id, url, events, secret)
+		VALUES ($1,$2,$3,$4,NULLIF($5,''))
+		RETURNING active, created_at
+	`, e.ID, e.UserID, e.URL, e.Events, e.Secret).
+		Scan(&e.Active, &e.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (r *Repository) ListWebhookEndpoints(ctx context.Context, userID uuid.UUID) ([]WebhookEndpoint, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	rows, err := r.pool.Query(ctx, `
+		SELECT id, user_id, url, events, active, created_at
+		FROM webhook_endpoints
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []WebhookEndpoint
+	for rows.Next() {
+		var e WebhookEndpoint
+		if err := rows.Scan(&e.ID, &e.UserID, &e.URL, &e.Events, &e.Active, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		result = append(result, e)
+	}
+	return result, rows.Err()
+}
+
+func (r *Repository) DeleteWebhookEndpoint(ctx context.Context, userID uuid.UUID, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	tag, err := r.pool.Exec(ctx, `
+		DELETE FROM webhook_endpoints
+		WHERE id = $1 AND user_id = $2
+	`, id, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// enqueueWebhook queues one delivery of the event for every active endpoint
+// of the user subscribed to it. Subscriptions are exact event names, a
+// "prefix.*" wildcard or "*". It runs inside the caller's transaction so the
+// event is queued if and only if the business change commits.
+func enqueueWebhook(ctx context.Context, tx pgx.Tx, userID uuid.UUID, event string, data any) (int64, error) {
+	eventID := NewID("evt_")
+	payload, err := json.Marshal(WebhookEnvelope{
+		ID:        eventID,
+		Type:      event,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO webhook_deliveries (id, endpoint_id, event_id, event, payload)
+		SELECT 'whd_' || md5(random()::text || clock_timestamp()::text || e.id), e.id, $3, $2, $4
+		FROM webhook_endpoints e
+		WHERE e.user_id = $1
+		  AND e.active
+		  AND EXISTS (
+			SELECT 1 FROM unnest(e.events) AS p
+			WHERE p = $2 OR p = '*'
+			   OR (right(p, 2) = '.*' AND $2 LIKE left(p, -1) || '%')
+		  )
+	`, userID, event, eventID, payload)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// EnqueueWebhook is enqueueWebhook in its own transaction, for events that
+// are not tied to a database change (e.g. manual replays).
+func (r *Repository) EnqueueWebhook(ctx context.Context, userID uuid.UUID, event string, data any) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.txTimeout)
+	defer cancel()
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	n, err := enqueueWebhook(ctx, tx, userID, event, data)
+	if err != nil {
+		return 0, err
+	}
+	return n, tx.Commit(ctx)
+}
+
+// ClaimDueDeliveries leases up to limit pending deliveries whose next attempt
+// is due by pushing next_attempt_at forward by lease. SKIP LOCKED lets several
+// instances dispatch concurrently without sending the same delivery twice.
+func (r *Repository) ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	rows, err := r.pool.Query(ctx, `
+		WITH due AS (
+			SELECT id
+			FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= now()
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE webhook_deliveries d
+		SET next_attempt_at = now() + $2::interval, updated_at = now()
+		FROM due, webhook_endpoints e
+		WHERE d.id = due.id AND e.id = d.endpoint_id
+		RETURNING d.id, d.endpoint_id, d.event_id, d.event, d.payload, d.attempts, e.url, COALESCE(e.secret, '')
+	`, limit, lease)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []WebhookDelivery
+	for rows.Next() {
+		var d WebhookDelivery
+		if err := rows.Scan(&d.ID, &d.EndpointID, &d.EventID, &d.Event, &d.Payload, &d.Attempts, &d.URL, &d.Secret); err != nil {
+			return nil, err
+		}
+		result = append(result, d)
+	}
+	return result, rows.Err()
+}
+
+func (r *Repository) MarkDeliveryDelivered(ctx context.Context, id string, statusCode int) error {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	_, err := r.pool.Exec(ctx, `
+		UPDATE webhook_deliveries
+		SET status = 'delivered', attempts = attempts + 1, last_status_code = $2,
+		    last_error = NULL, delivered_at = now(), updated_at = now()
+		WHERE id = $1
+	`, id, statusCode)
+	return err
+}
+
+// MarkDeliveryAttemptFailed records a failed attempt. A nil retryAt gives up
+// and marks the delivery failed.
+func (r *Repository) MarkDeliveryAttemptFailed(ctx context.Context, id string, statusCode *int, errMsg string, retryAt *time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	_, err := r.pool.Exec(ctx, `
+		UPDATE webhook_deliveries
+		SET attempts = attempts + 1,
+		    last_status_code = $2,
+		    last_error = $3,
+		    status = CASE WHEN $4::timestamptz IS NULL THEN 'failed' ELSE 'pending' END,
+		    next_attempt_at = COALESCE($4, next_attempt_at),
+		    updated_at = now()
+		WHERE id = $1
+	`, id, statusCode, errMsg, retryAt)
+	return err
+}
+
+const deliveryColumns = `d.id, d.endpoint_id, d.event_id, d.event, d.payload, d.status, d.attempts,
+	d.next_attempt_at, d.last_status_code, d.last_error, d.delivered_at, d.created_at`
+
+func scanDelivery(row pgx.Row) (*WebhookDelivery, error) {
+	var d WebhookDelivery
+	err := row.Scan(&d.ID, &d.EndpointID, &d.EventID, &d.Event, &d.Payload, &d.Status, &d.Attempts,
+		&d.NextAttemptAt, &d.LastStatusCode, &d.LastError, &d.DeliveredAt, &d.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// ListWebhookDeliveries is the delivery log for a user's endpoints, newest
+// first, optionally filtered by endpoint and status.
+func (r *Repository) ListWebhookDeliveries(ctx context.Context, userID uuid.UUID, endpointID, status string, limit int) ([]WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	rows, err := r.pool.Query(ctx, `
+		SELECT `+deliveryColumns+`
+		FROM webhook_deliveries d
+		JOIN webhook_endpoints e ON e.id = d.endpoint_id
+		WHERE e.user_id = $1
+		  AND ($2 = '' OR d.endpoint_id = $2)
+		  AND ($3 = '' OR d.status = $3)
+		ORDER BY d.created_at DESC
+		LIMIT $4
+	`, userID, endpointID, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []WebhookDelivery
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *d)
+	}
+	return result, rows.Err()
+}
+
+// ReplayWebhookDelivery queues a fresh copy of a delivery owned by the user,
+// keeping the original row in the log.
+func (r *Repository) ReplayWebhookDelivery(ctx context.Context, userID uuid.UUID, id string) (*WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	return scanDelivery(r.pool.QueryRow(ctx, `
+		WITH src AS (
+			SELECT d.endpoint_id, d.event_id, d.event, d.payload
+			FROM webhook_deliveries d
+			JOIN webhook_endpoints e ON e.id = d.endpoint_id
+			WHERE d.id = $2 AND e.user_id = $1
+		)
+		INSERT INTO webhook_deliveries AS d (id, endpoint_id, event_id, event, payload)
+		SELECT $3, endpoint_id, event_id, event, payload FROM src
+		RETURNING `+deliveryColumns,
+		userID, id, NewID("whd_")))
+}
@@ -0,0 +1,35 @@
+// Package reqctx carries request-scoped values from the HTTP layer through
+// the service to the repository on a context.Context.
+package reqctx
+
+import "context"
+
+type key int
+
+const (
+	requestIDKey key = iota
+	userIDKey
+)
+
+// WithRequestID returns ctx carrying the request's ID.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestID returns the request ID on ctx, or "" outside a request.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// WithUserID returns ctx carrying the authenticated user's ID.
+func WithUserID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, userIDKey, id)
+}
+
+// UserID returns the authenticated user's ID on ctx, or "" when the caller
+// is not a known user.
+func UserID(ctx context.Context) string {
+	id, _ := ctx.Value(userIDKey).(string)
+	return id
+}
@@ -0,0 +1,35 @@
+package router
+
+import (
+	_ "embed"
+	"time"
+
+	"fintech-backend/internal/auth"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// demoTokenTTL bounds how long a token from /dev/token works.
+const demoTokenTTL = 15 * time.Minute
+
+//go:embed assets/demo.html
+var demoPage []byte
+
+// registerDemo serves the demo page at / and the token endpoint it signs
+// in with. Only New calls it, and only when ENV=dev: the token acts as the
+// demo user without any credential.
+func registerDemo(app *fiber.App, tokens *auth.DemoTokens) {
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.Type("html").Send(demoPage)
+	})
+
+	app.Post("/dev/token", func(c *fiber.Ctx) error {
+		token, expires := tokens.Issue(auth.DemoEmail)
+		return c.JSON(fiber.Map{
+			"token":      token,
+			"token_type": "Bearer",
+			"expires_at": expires,
+			"email":      auth.DemoEmail,
+		})
+	})
+}
@@ -0,0 +1,95 @@
+package router
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"fintech-backend/internal/service"
+	"fintech-backend/internal/validate"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// errInvalidBody is returned when a request body does not parse.
+var errInvalidBody = &service.Error{Code: service.CodeValidation, Message: "invalid body"}
+
+// parseBody decodes the request body into req and checks its validate tags,
+// so every field error is reported before the service is called.
+func parseBody(c *fiber.Ctx, req any) error {
+	if err := c.BodyParser(req); err != nil {
+		return errInvalidBody
+	}
+	return validate.Struct(req)
+}
+
+// errorResponse is the body of every error response.
+type errorResponse struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Details   any    `json:"details,omitempty"`
+	RequestID string `json:"request_id"`
+}
+
+var statusByCode = map[string]int{
+	service.CodeValidation:        http.StatusUnprocessableEntity,
+	service.CodeNotFound:          http.StatusNotFound,
+	service.CodeConflict:          http.StatusConflict,
+	service.CodeInsufficientStock: http.StatusConflict,
+	service.CodeForbidden:         http.StatusForbidden,
+	service.CodeTimeout:           http.StatusGatewayTimeout,
+	service.CodeInternal:          http.StatusInternalServerError,
+}
+
+// errorHandler renders errors returned by handlers and middleware. Fiber's
+// own errors (unknown route, 401 from the auth middleware, ...) keep their
+// status; everything else is classified by service.AsError. Internal causes
+// are logged with the request ID and never sent to the client.
+func errorHandler(c *fiber.Ctx, err error) error {
+	requestID, _ := c.Locals("requestid").(string)
+
+	var fe *fiber.Error
+	if errors.As(err, &fe) {
+		return c.Status(fe.Code).JSON(errorResponse{
+			Code:      codeForStatus(fe.Code),
+			Message:   fe.Message,
+			RequestID: requestID,
+		})
+	}
+
+	e := service.AsError(err)
+	status, ok := statusByCode[e.Code]
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+	if status >= http.StatusInternalServerError {
+		slog.ErrorContext(c.UserContext(), "request failed", "method", c.Method(), "path", c.Path(), "err", err)
+	}
+	return c.Status(status).JSON(errorResponse{
+		Code:      e.Code,
+		Message:   e.Message,
+		Details:   e.Details,
+		RequestID: requestID,
+	})
+}
+
+func codeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return service.CodeValidation
+	case http.StatusUnauthorized:
+		return "unauthorized"
+	case http.StatusForbidden:
+		return service.CodeForbidden
+	case http.StatusNotFound:
+		return service.CodeNotFound
+	case http.StatusConflict:
+		return service.CodeConflict
+	case http.StatusTooManyRequests:
+		return "rate_limited"
+	}
+	if status >= http.StatusInternalServerError {
+		return service.CodeInternal
+	}
+	return "error"
+}
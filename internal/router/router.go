@@ -1,332 +1,120 @@
 package router
 
 import (
+	"bytes"
 	"context"
+	"fmt"
 	"net/http"
+	"strings"
 
+	"fintech-backend/internal/auth"
 	"fintech-backend/internal/config"
 	"fintech-backend/internal/dto"
+	"fintech-backend/internal/gst"
+	"fintech-backend/internal/health"
+	"fintech-backend/internal/metrics"
 	"fintech-backend/internal/middleware"
-	"fintech-backend/internal/repository"
+	"fintech-backend/internal/ratelimit"
 	"fintech-backend/internal/service"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
 )
 
-func New(cfg *config.Config, pool *pgxpool.Pool) *fiber.App {
-	app := fiber.New()
-
-	app.Use(middleware.CORS())
-
-	// Simple demo UI at /
-	app.Get("/", func(c *fiber.Ctx) error {
-		html := `<!DOCTYPE html>
-<html lang="en">
-<head>
-  <meta charset="UTF-8" />
-  <title>Fintech Demo - Mini AI CFO</title>
-  <meta name="viewport" content="width=device-width,initial-scale=1" />
-  <style>
-    body {
-      font-family: system-ui, -apple-system, BlinkMacSystemFont, "Segoe UI", sans-serif;
-      background: #050816;
-      color: #e5e7eb;
-      padding: 16px;
-    }
-    h1, h2 {
-      color: #f9fafb;
-    }
-    .card {
-      background: #111827;
-      border-radius: 12px;
-      padding: 16px;
-      margin-bottom: 16px;
-      border: 1px solid #1f2933;
-    }
-    label {
-      font-size: 12px;
-      color: #9ca3af;
-      display: block;
-      margin-bottom: 4px;
-    }
-    input {
-      width: 100%;
-      padding: 8px;
-      margin-bottom: 8px;
-      border-radius: 8px;
-      border: 1px solid #374151;
-      background: #020617;
-      color: #e5e7eb;
-    }
-    button {
-      padding: 8px 14px;
-      border-radius: 999px;
-      border: none;
-      cursor: pointer;
-      font-size: 14px;
-      background: linear-gradient(to right, #22c55e, #16a34a);
-      color: #020617;
-      font-weight: 600;
-      margin-right: 8px;
-      margin-bottom: 8px;
-    }
-    button.secondary {
-      background: #111827;
-      color: #e5e7eb;
-      border: 1px solid #374151;
-    }
-    pre {
-      background: #020617;
-      border-radius: 8px;
-      padding: 8px;
-      font-size: 12px;
-      overflow-x: auto;
-      border: 1px solid #111827;
-    }
-    .row {
-      display: grid;
-      grid-template-columns: repeat(auto-fit, minmax(260px, 1fr));
-      gap: 16px;
-    }
-    .tag {
-      display: inline-block;
-      font-size: 11px;
-      padding: 2px 8px;
-      border-radius: 999px;
-      border: 1px solid #374151;
-      color: #9ca3af;
-      margin-left: 8px;
-    }
-  </style>
-</head>
-<body>
-  <h1>Fintech Backend Demo <span class="tag">Mini AI CFO</span></h1>
-  <p style="font-size:13px;color:#9ca3af;margin-bottom:16px;">
-    This page talks to your Go + Postgres backend. Create a shop, add products and see JSON responses live.
-  </p>
-
-  <div class="row">
-    <!-- SHOPS CARD -->
-    <div class="card">
-      <h2>1. Shops</h2>
-      <label>Owner Email (must exist in DB, default: admin@example.com)</label>
-      <input id="ownerEmail" placeholder="admin@example.com" value="admin@example.com" />
-
-      <label>Shop Name</label>
-      <input id="shopName" placeholder="Bablu Enterprises" />
-
-      <label>Address</label>
-      <input id="shopAddress" placeholder="Janakpuri, New Delhi" />
-
-      <label>GST Number</label>
-      <input id="shopGST" placeholder="07ABCDE1234F1Z5" />
-
-      <button onclick="createShop()">Create Shop</button>
-      <button class="secondary" onclick="listShops()">List My Shops</button>
-
-      <p style="font-size:12px;color:#9ca3af;margin-top:8px;">
-        Selected Shop ID: <span id="selectedShopId" style="color:#22c55e;">(none)</span>
-      </p>
-
-      <pre id="shopsOutput">// Shops responses will appear here</pre>
-    </div>
-
-    <!-- PRODUCTS CARD -->
-    <div class="card">
-      <h2>2. Products</h2>
-      <p style="font-size:12px;color:#9ca3af;">
-        Uses the selected Shop ID from above.
-      </p>
-
-      <label>Product Name</label>
-      <input id="productName" placeholder="Jack F4 Sewing Machine" />
-
-      <label>SKU</label>
-      <input id="productSKU" placeholder="JACK-F4" />
-
-      <label>Stock</label>
-      <input id="productStock" type="number" value="5" />
-
-      <label>Cost Price</label>
-      <input id="productCost" type="number" value="18000" />
-
-      <label>Selling Price</label>
-      <input id="productSell" type="number" value="22000" />
-
-      <label>Low Stock Threshold</label>
-      <input id="productLow" type="number" value="2" />
-
-      <button onclick="createProduct()">Create Product</button>
-      <button class="secondary" onclick="listProducts()">List Products</button>
-
-      <pre id="productsOutput">// Products responses will appear here</pre>
-    </div>
-  </div>
-
-  <script>
-    const API_KEY = "supersecretapikey";
-    const BASE_URL = window.location.origin;
-
-    let selectedShopId = null;
-
-    function setSelectedShop(id) {
-      selectedShopId = id;
-      document.getElementById("selectedShopId").textContent = id || "(none)";
-    }
-
-    async function createShop() {
-      const ownerEmail = document.getElementById("ownerEmail").value.trim();
-      const name = document.getElementById("shopName").value.trim();
-      const address = document.getElementById("shopAddress").value.trim();
-      const gst = document.getElementById("shopGST").value.trim();
-
-      if (!ownerEmail || !name) {
-        alert("Owner email and shop name are required");
-        return;
-      }
-
-      try {
-        const res = await fetch(BASE_URL + "/api/shops", {
-          method: "POST",
-          headers: {
-            "Content-Type": "application/json",
-            "X-API-Key": API_KEY
-          },
-          body: JSON.stringify({
-            owner_email: ownerEmail,
-            name,
-            address,
-            gst_number: gst
-          })
-        });
-
-        const data = await res.json();
-        document.getElementById("shopsOutput").textContent = JSON.stringify(data, null, 2);
-
-        if (data.id) {
-          setSelectedShop(data.id);
-        }
-      } catch (err) {
-        document.getElementById("shopsOutput").textContent = "Error: " + err.message;
-      }
-    }
-
-    async function listShops() {
-      try {
-        const res = await fetch(BASE_URL + "/api/shops", {
-          headers: {
-            "X-API-Key": API_KEY
-          }
-        });
-        const data = await res.json();
-        document.getElementById("shopsOutput").textContent = JSON.stringify(data, null, 2);
-
-        if (Array.isArray(data) && data.length > 0) {
-          setSelectedShop(data[0].id);
-        }
-      } catch (err) {
-        document.getElementById("shopsOutput").textContent = "Error: " + err.message;
-      }
-    }
-
-    async function createProduct() {
-      if (!selectedShopId) {
-        alert("Select or create a shop first");
-        return;
-      }
-
-      const name = document.getElementById("productName").value.trim();
-      const sku = document.getElementById("productSKU").value.trim();
-      const stock = parseInt(document.getElementById("productStock").value || "0", 10);
-      const cost = parseFloat(document.getElementById("productCost").value || "0");
-      const sell = parseFloat(document.getElementById("productSell").value || "0");
-      const low = parseInt(document.getElementById("productLow").value || "0", 10);
-
-      if (!name) {
-        alert("Product name is required");
-        return;
-      }
-
-      try {
-        const res = await fetch(BASE_URL + "/api/products", {
-          method: "POST",
-          headers: {
-            "Content-Type": "application/json",
-            "X-API-Key": API_KEY
-          },
-          body: JSON.stringify({
-            shop_id: selectedShopId,
-            name,
-            sku,
-            stock,
-            cost_price: cost,
-            selling_price: sell,
-            low_stock_threshold: low
-          })
-        });
-
-        const data = await res.json();
-        document.getElementById("productsOutput").textContent = JSON.stringify(data, null, 2);
-      } catch (err) {
-        document.getElementById("productsOutput").textContent = "Error: " + err.message;
-      }
-    }
-
-    async function listProducts() {
-      if (!selectedShopId) {
-        alert("Select or create a shop first");
-        return;
-      }
-
-      try {
-        const res = await fetch(BASE_URL + "/api/shops/" + selectedShopId + "/products", {
-          headers: {
-            "X-API-Key": API_KEY
-          }
-        });
-
-        const data = await res.json();
-        document.getElementById("productsOutput").textContent = JSON.stringify(data, null, 2);
-      } catch (err) {
-        document.getElementById("productsOutput").textContent = "Error: " + err.message;
-      }
-    }
-  </script>
-</body>
-</html>`
-		return c.Type("html").SendString(html)
-	})
-
-	// health
-	app.Get("/health", func(c *fiber.Ctx) error {
-		return c.JSON(fiber.Map{"status": "ok"})
+// Store is the persistence the middleware needs beyond the service.
+// *repository.Repository implements it.
+type Store interface {
+	middleware.IdempotencyStore
+	middleware.UserStore
+}
+
+// New builds the app. Request contexts derive from ctx, so cancelling it
+// aborts in-flight queries. limits holds the rate-limit buckets.
+func New(ctx context.Context, cfg *config.Config, svc *service.Service, store Store, limits ratelimit.Store, checker *health.Checker) *fiber.App {
+	app := fiber.New(fiber.Config{ErrorHandler: errorHandler})
+
+	app.Use(middleware.RequestID())
+	app.Use(middleware.AccessLog())
+	app.Use(middleware.Metrics())
+	app.Use(middleware.RequestContext(ctx, cfg.HTTP.RequestTimeout))
+	app.Use(middleware.Tracing())
+
+	app.Use(middleware.CORS(cfg.HTTP))
+
+	var demoTokens *auth.DemoTokens
+	if cfg.Env == config.EnvDev {
+		demoTokens = auth.NewDemoTokens(demoTokenTTL)
+		registerDemo(app, demoTokens)
+	}
+
+	// HEALTH
+	// livez only shows the process answers; readyz also needs Postgres and
+	// an up-to-date schema, and answers 503 otherwise. /health is kept for
+	// existing probes and behaves like readyz.
+	app.Get("/livez", func(c *fiber.Ctx) error {
+		return c.JSON(fiber.Map{"status": health.StatusOK})
 	})
 
-	// protected routes
-	api := app.Group("/api", middleware.APIKeyAuth(cfg))
+	ready := func(c *fiber.Ctx) error {
+		report := checker.Ready(c.UserContext())
+		if report.Status != health.StatusOK {
+			c.Status(fiber.StatusServiceUnavailable)
+		}
+		return c.JSON(report)
+	}
+	app.Get("/readyz", ready)
+	app.Get("/health", ready)
 
-	repo := repository.New(pool)
-	svc := service.New(repo)
+	if cfg.Metrics.Enabled {
+		app.Get("/metrics", middleware.MetricsAuth(cfg.Metrics.Token), adaptor.HTTPHandler(metrics.Handler()))
+	}
+
+	// protected routes
+	idempotency := middleware.Idempotency(store, cfg.HTTP.IdempotencyTTL, cfg.HTTP.RequestTimeout)
+	ipRateLimit := middleware.IPRateLimit(cfg.RateLimit, limits)
+	rateLimit := middleware.RateLimit(cfg.RateLimit, limits)
+
+	apiAuth := middleware.APIKeyAuth(cfg, store)
+	if demoTokens != nil {
+		apiAuth = middleware.DemoTokenAuth(demoTokens, store, apiAuth)
+	}
+	api := app.Group("/api", ipRateLimit, apiAuth, rateLimit, idempotency)
+	api.Use("/invoices/:invoiceId/credit-notes", middleware.Feature("gst", cfg.Features.GST))
+	api.Use("/shops/:shopId/gst", middleware.Feature("gst", cfg.Features.GST))
+	api.Use("/shops/:shopId/coach", middleware.Feature("coach", cfg.Features.Coach))
+	api.Use("/coach", middleware.Feature("coach", cfg.Features.Coach))
+	api.Use("/webhooks", middleware.Feature("webhooks", cfg.Features.Webhooks))
 
 	// SHOPS
 	api.Post("/shops", func(c *fiber.Ctx) error {
 		var req dto.CreateShopRequest
-		if err := c.BodyParser(&req); err != nil {
-			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid body"})
+		if err := parseBody(c, &req); err != nil {
+			return err
+		}
+		shop, err := svc.CreateShop(c.UserContext(), req)
+		if err != nil {
+			return err
+		}
+		return c.JSON(shop)
+	})
+
+	api.Patch("/shops/:shopId", func(c *fiber.Ctx) error {
+		var req dto.UpdateShopRequest
+		if err := parseBody(c, &req); err != nil {
+			return err
 		}
-		shop, err := svc.CreateShop(context.Background(), req)
+		shop, err := svc.UpdateShop(c.UserContext(), c.Params("shopId"), req)
 		if err != nil {
-			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+			return err
 		}
 		return c.JSON(shop)
 	})
 
 	api.Get("/shops", func(c *fiber.Ctx) error {
-		apiKey := c.Get("X-API-Key")
-		shops, err := svc.ListShops(context.Background(), apiKey)
+		apiKey := middleware.CallerKey(c)
+		shops, err := svc.ListShops(c.UserContext(), apiKey)
 		if err != nil {
-			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+			return err
 		}
 		return c.JSON(shops)
 	})
@@ -334,21 +122,33 @@ func New(cfg *config.Config, pool *pgxpool.Pool) *fiber.App {
 	// PRODUCTS
 	api.Post("/products", func(c *fiber.Ctx) error {
 		var req dto.CreateProductRequest
-		if err := c.BodyParser(&req); err != nil {
-			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid body"})
+		if err := parseBody(c, &req); err != nil {
+			return err
+		}
+		p, err := svc.CreateProduct(c.UserContext(), req)
+		if err != nil {
+			return err
+		}
+		return c.JSON(p)
+	})
+
+	api.Patch("/products/:productId", func(c *fiber.Ctx) error {
+		var req dto.UpdateProductRequest
+		if err := parseBody(c, &req); err != nil {
+			return err
 		}
-		p, err := svc.CreateProduct(context.Background(), req)
+		p, err := svc.UpdateProduct(c.UserContext(), c.Params("productId"), req)
 		if err != nil {
-			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+			return err
 		}
 		return c.JSON(p)
 	})
 
 	api.Get("/shops/:shopId/products", func(c *fiber.Ctx) error {
 		shopID := c.Params("shopId")
-		ps, err := svc.ListProducts(context.Background(), shopID)
+		ps, err := svc.ListProducts(c.UserContext(), shopID)
 		if err != nil {
-			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+			return err
 		}
 		return c.JSON(ps)
 	})
@@ -356,43 +156,63 @@ func New(cfg *config.Config, pool *pgxpool.Pool) *fiber.App {
 	// INVOICES
 	api.Post("/invoices", func(c *fiber.Ctx) error {
 		var req dto.CreateInvoiceRequest
-		if err := c.BodyParser(&req); err != nil {
-			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid body"})
+		if err := parseBody(c, &req); err != nil {
+			return err
 		}
-		inv, err := svc.CreateInvoice(context.Background(), req)
+		inv, err := svc.CreateInvoice(c.UserContext(), req)
 		if err != nil {
-			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+			return err
 		}
 		return c.JSON(inv)
 	})
 
 	api.Get("/shops/:shopId/invoices", func(c *fiber.Ctx) error {
 		shopID := c.Params("shopId")
-		invs, err := svc.ListInvoices(context.Background(), shopID)
+		invs, err := svc.ListInvoices(c.UserContext(), shopID)
 		if err != nil {
-			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+			return err
 		}
 		return c.JSON(invs)
 	})
 
+	api.Post("/invoices/:invoiceId/settle", func(c *fiber.Ctx) error {
+		inv, err := svc.SettleInvoice(c.UserContext(), c.Params("invoiceId"))
+		if err != nil {
+			return err
+		}
+		return c.JSON(inv)
+	})
+
+	api.Post("/invoices/:invoiceId/credit-notes", func(c *fiber.Ctx) error {
+		var req dto.CreateCreditNoteRequest
+		if err := parseBody(c, &req); err != nil {
+			return err
+		}
+		cn, err := svc.CreateCreditNote(c.UserContext(), c.Params("invoiceId"), req)
+		if err != nil {
+			return err
+		}
+		return c.JSON(cn)
+	})
+
 	// EXPENSES
 	api.Post("/expenses", func(c *fiber.Ctx) error {
 		var req dto.CreateExpenseRequest
-		if err := c.BodyParser(&req); err != nil {
-			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid body"})
+		if err := parseBody(c, &req); err != nil {
+			return err
 		}
-		e, err := svc.CreateExpense(context.Background(), req)
+		e, err := svc.CreateExpense(c.UserContext(), req)
 		if err != nil {
-			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+			return err
 		}
 		return c.JSON(e)
 	})
 
 	api.Get("/shops/:shopId/expenses", func(c *fiber.Ctx) error {
 		shopID := c.Params("shopId")
-		es, err := svc.ListExpenses(context.Background(), shopID)
+		es, err := svc.ListExpenses(c.UserContext(), shopID)
 		if err != nil {
-			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+			return err
 		}
 		return c.JSON(es)
 	})
@@ -400,12 +220,12 @@ func New(cfg *config.Config, pool *pgxpool.Pool) *fiber.App {
 	// POTS
 	api.Post("/pots", func(c *fiber.Ctx) error {
 		var req dto.CreatePotRequest
-		if err := c.BodyParser(&req); err != nil {
-			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid body"})
+		if err := parseBody(c, &req); err != nil {
+			return err
 		}
-		p, err := svc.CreatePot(context.Background(), req)
+		p, err := svc.CreatePot(c.UserContext(), req)
 		if err != nil {
-			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+			return err
 		}
 		return c.JSON(p)
 	})
@@ -413,21 +233,21 @@ func New(cfg *config.Config, pool *pgxpool.Pool) *fiber.App {
 	api.Patch("/pots/:potId/deposit", func(c *fiber.Ctx) error {
 		potID := c.Params("potId")
 		var req dto.DepositPotRequest
-		if err := c.BodyParser(&req); err != nil {
-			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid body"})
+		if err := parseBody(c, &req); err != nil {
+			return err
 		}
-		p, err := svc.DepositPot(context.Background(), potID, req.Amount)
+		p, err := svc.DepositPot(c.UserContext(), potID, req.Amount)
 		if err != nil {
-			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+			return err
 		}
 		return c.JSON(p)
 	})
 
 	api.Get("/shops/:shopId/pots", func(c *fiber.Ctx) error {
 		shopID := c.Params("shopId")
-		ps, err := svc.ListPots(context.Background(), shopID)
+		ps, err := svc.ListPots(c.UserContext(), shopID)
 		if err != nil {
-			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+			return err
 		}
 		return c.JSON(ps)
 	})
@@ -435,22 +255,279 @@ func New(cfg *config.Config, pool *pgxpool.Pool) *fiber.App {
 	// DASHBOARD
 	api.Get("/shops/:shopId/dashboard", func(c *fiber.Ctx) error {
 		shopID := c.Params("shopId")
-		summary, err := svc.GetDashboardSummary(context.Background(), shopID)
+		series, err := svc.GetDashboardSeries(c.UserContext(), shopID, c.Query("from"), c.Query("to"), c.Query("granularity"))
+		if err != nil {
+			return err
+		}
+		return c.JSON(series)
+	})
+
+	api.Get("/shops/:shopId/dashboard/summary", func(c *fiber.Ctx) error {
+		shopID := c.Params("shopId")
+		summary, err := svc.GetDashboardSummary(c.UserContext(), shopID)
 		if err != nil {
-			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+			return err
 		}
 		return c.JSON(summary)
 	})
 
+	// LEDGER
+	api.Get("/shops/:shopId/ledger/trial-balance", func(c *fiber.Ctx) error {
+		tb, err := svc.GetShopTrialBalance(c.UserContext(), c.Params("shopId"), c.Query("as_of"))
+		if err != nil {
+			return err
+		}
+		return c.JSON(tb)
+	})
+
+	api.Get("/shops/:shopId/ledger/pnl", func(c *fiber.Ctx) error {
+		pl, err := svc.GetShopProfitAndLoss(c.UserContext(), c.Params("shopId"), c.Query("from"), c.Query("to"))
+		if err != nil {
+			return err
+		}
+		return c.JSON(pl)
+	})
+
+	api.Get("/shops/:shopId/ledger/balance-sheet", func(c *fiber.Ctx) error {
+		bs, err := svc.GetShopBalanceSheet(c.UserContext(), c.Params("shopId"), c.Query("as_of"))
+		if err != nil {
+			return err
+		}
+		return c.JSON(bs)
+	})
+
+	// GST RETURNS
+	// format=csv returns one sheet of the offline tool; GSTR-1 needs section.
+	api.Get("/shops/:shopId/gst/gstr1", func(c *fiber.Ctx) error {
+		r, err := svc.GetGSTR1(c.UserContext(), c.Params("shopId"), c.Query("period"))
+		if err != nil {
+			return err
+		}
+		if c.Query("format") != "csv" {
+			return c.JSON(r)
+		}
+		section := c.Query("section")
+		var buf bytes.Buffer
+		if err := gst.WriteGSTR1CSV(&buf, r, section); err != nil {
+			return &service.Error{
+				Code:    service.CodeValidation,
+				Message: err.Error() + "; section must be one of " + strings.Join(gst.GSTR1Sections, ", "),
+			}
+		}
+		return sendCSV(c, fmt.Sprintf("GSTR1_%s_%s.csv", section, r.FP), buf.Bytes())
+	})
+
+	api.Get("/shops/:shopId/gst/gstr3b", func(c *fiber.Ctx) error {
+		r, err := svc.GetGSTR3B(c.UserContext(), c.Params("shopId"), c.Query("period"))
+		if err != nil {
+			return err
+		}
+		if c.Query("format") != "csv" {
+			return c.JSON(r)
+		}
+		var buf bytes.Buffer
+		if err := gst.WriteGSTR3BCSV(&buf, r); err != nil {
+			return err
+		}
+		return sendCSV(c, fmt.Sprintf("GSTR3B_%s.csv", r.RetPeriod), buf.Bytes())
+	})
+
 	// COACH
 	api.Get("/shops/:shopId/coach", func(c *fiber.Ctx) error {
 		shopID := c.Params("shopId")
-		insights, err := svc.GetCoachInsights(context.Background(), shopID)
+		apiKey := middleware.CallerKey(c)
+		insights, err := svc.GetCoachInsights(c.UserContext(), apiKey, shopID)
 		if err != nil {
-			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+			return err
 		}
 		return c.JSON(insights)
 	})
 
+	api.Post("/shops/:shopId/coach/:insightId/dismiss", func(c *fiber.Ctx) error {
+		apiKey := middleware.CallerKey(c)
+		st, err := svc.DismissCoachInsight(c.UserContext(), apiKey, c.Params("shopId"), c.Params("insightId"))
+		if err != nil {
+			return err
+		}
+		return c.JSON(st)
+	})
+
+	api.Post("/shops/:shopId/coach/:insightId/snooze", func(c *fiber.Ctx) error {
+		apiKey := middleware.CallerKey(c)
+		var req dto.SnoozeInsightRequest
+		if len(c.Body()) > 0 {
+			if err := parseBody(c, &req); err != nil {
+				return err
+			}
+		}
+		st, err := svc.SnoozeCoachInsight(c.UserContext(), apiKey, c.Params("shopId"), c.Params("insightId"), req.Days)
+		if err != nil {
+			return err
+		}
+		return c.JSON(st)
+	})
+
+	api.Delete("/shops/:shopId/coach/:insightId/state", func(c *fiber.Ctx) error {
+		apiKey := middleware.CallerKey(c)
+		if err := svc.RestoreCoachInsight(c.UserContext(), apiKey, c.Params("shopId"), c.Params("insightId")); err != nil {
+			return err
+		}
+		return c.SendStatus(http.StatusNoContent)
+	})
+
+	api.Post("/coach/plan", func(c *fiber.Ctx) error {
+		apiKey := middleware.CallerKey(c)
+		plan, err := svc.GenerateCoachPlan(c.UserContext(), apiKey)
+		if err != nil {
+			return err
+		}
+		return c.JSON(plan)
+	})
+
+	api.Get("/coach/plans", func(c *fiber.Ctx) error {
+		apiKey := middleware.CallerKey(c)
+		plans, err := svc.ListCoachPlans(c.UserContext(), apiKey)
+		if err != nil {
+			return err
+		}
+		return c.JSON(plans)
+	})
+
+	// WEBHOOKS
+	api.Post("/webhooks", func(c *fiber.Ctx) error {
+		apiKey := middleware.CallerKey(c)
+		var req dto.CreateWebhookRequest
+		if err := parseBody(c, &req); err != nil {
+			return err
+		}
+		e, err := svc.CreateWebhookEndpoint(c.UserContext(), apiKey, req)
+		if err != nil {
+			return err
+		}
+		return c.Status(http.StatusCreated).JSON(e)
+	})
+
+	api.Get("/webhooks", func(c *fiber.Ctx) error {
+		apiKey := middleware.CallerKey(c)
+		es, err := svc.ListWebhookEndpoints(c.UserContext(), apiKey)
+		if err != nil {
+			return err
+		}
+		return c.JSON(es)
+	})
+
+	api.Get("/webhooks/deliveries", func(c *fiber.Ctx) error {
+		apiKey := middleware.CallerKey(c)
+		ds, err := svc.ListWebhookDeliveries(c.UserContext(), apiKey, c.Query("endpoint_id"), c.Query("status"), c.QueryInt("limit"))
+		if err != nil {
+			return err
+		}
+		return c.JSON(ds)
+	})
+
+	api.Post("/webhooks/deliveries/:id/replay", func(c *fiber.Ctx) error {
+		apiKey := middleware.CallerKey(c)
+		d, err := svc.ReplayWebhookDelivery(c.UserContext(), apiKey, c.Params("id"))
+		if err != nil {
+			return err
+		}
+		return c.Status(http.StatusCreated).JSON(d)
+	})
+
+	api.Delete("/webhooks/:id", func(c *fiber.Ctx) error {
+		apiKey := middleware.CallerKey(c)
+		if err := svc.DeleteWebhookEndpoint(c.UserContext(), apiKey, c.Params("id")); err != nil {
+			return err
+		}
+		return c.SendStatus(http.StatusNoContent)
+	})
+
+	// PAYOUTS (public v1 API)
+	v1 := app.Group("/v1", middleware.Feature("payouts", cfg.Features.Payouts), ipRateLimit, middleware.BearerAuth(cfg, store), rateLimit, idempotency)
+
+	v1.Post("/payouts", func(c *fiber.Ctx) error {
+		var req dto.CreatePayoutRequest
+		if err := parseBody(c, &req); err != nil {
+			return err
+		}
+		token := strings.TrimPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+		p, err := svc.CreatePayout(c.UserContext(), token, req)
+		if err != nil {
+			return err
+		}
+		return c.Status(http.StatusCreated).JSON(p)
+	})
+
+	v1.Get("/payouts/ledger", func(c *fiber.Ctx) error {
+		token := strings.TrimPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+		ledger, err := svc.ListPayouts(c.UserContext(), token, c.Query("status"), c.Query("before"), c.QueryInt("limit"))
+		if err != nil {
+			return err
+		}
+		return c.JSON(ledger)
+	})
+
+	v1.Get("/payouts/:id", func(c *fiber.Ctx) error {
+		token := strings.TrimPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+		p, err := svc.GetPayout(c.UserContext(), token, c.Params("id"))
+		if err != nil {
+			return err
+		}
+		return c.JSON(p)
+	})
+
+	v1.Post("/payouts/:id/cancel", func(c *fiber.Ctx) error {
+		token := strings.TrimPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+		p, err := svc.CancelPayout(c.UserContext(), token, c.Params("id"))
+		if err != nil {
+			return err
+		}
+		return c.JSON(p)
+	})
+
+	v1.Post("/payouts/:id/webhook/replay", func(c *fiber.Ctx) error {
+		token := strings.TrimPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+		replay, err := svc.ReplayPayoutWebhook(c.UserContext(), token, c.Params("id"))
+		if err != nil {
+			return err
+		}
+		return c.JSON(replay)
+	})
+
+	v1.Get("/ledger/trial-balance", func(c *fiber.Ctx) error {
+		token := strings.TrimPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+		tb, err := svc.GetPayoutTrialBalance(c.UserContext(), token, c.Query("as_of"))
+		if err != nil {
+			return err
+		}
+		return c.JSON(tb)
+	})
+
+	v1.Get("/ledger/pnl", func(c *fiber.Ctx) error {
+		token := strings.TrimPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+		pl, err := svc.GetPayoutProfitAndLoss(c.UserContext(), token, c.Query("from"), c.Query("to"))
+		if err != nil {
+			return err
+		}
+		return c.JSON(pl)
+	})
+
+	v1.Get("/ledger/balance-sheet", func(c *fiber.Ctx) error {
+		token := strings.TrimPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+		bs, err := svc.GetPayoutBalanceSheet(c.UserContext(), token, c.Query("as_of"))
+		if err != nil {
+			return err
+		}
+		return c.JSON(bs)
+	})
+
+	app.Use(middleware.NotFound)
+
 	return app
 }
+
+func sendCSV(c *fiber.Ctx, filename string, body []byte) error {
+	c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+filename+`"`)
+	return c.Send(body)
+}
@@ -0,0 +1,477 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"fintech-backend/internal/repository"
+	"fintech-backend/internal/tracing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ========== WEEKLY COACH PLAN ==========
+
+const (
+	coachLookbackWeeks = 4
+	coachPlanHistory   = 12
+)
+
+// moods treated as emotional spending when building a plan.
+var stressMoods = map[string]bool{
+	"stressed": true,
+	"anxious":  true,
+	"sad":      true,
+	"bored":    true,
+	"angry":    true,
+}
+
+// weekStart returns the Monday of the week containing t in loc as a date
+// (midnight UTC, as pgx scans one), matching Postgres
+// date_trunc('week', t AT TIME ZONE loc)::date.
+func weekStart(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	offset := (int(t.Weekday()) + 6) % 7
+	return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, time.UTC)
+}
+
+// ownerLocation is the timezone an owner's weeks are counted in: that of
+// their first shop, or UTC before they have one.
+func (s *Service) ownerLocation(ctx context.Context, ownerID uuid.UUID) (*time.Location, error) {
+	shops, err := s.repo.ListShopsByUser(ctx, ownerID)
+	if err != nil || len(shops) == 0 {
+		return time.UTC, err
+	}
+	first := shops[len(shops)-1] // newest first
+	loc, err := time.LoadLocation(first.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("shop has invalid timezone %s", first.Timezone)
+	}
+	return loc, nil
+}
+
+func (s *Service) GenerateCoachPlan(ctx context.Context, apiKey string) (*repository.CoachPlan, error) {
+	ctx, span := tracing.Start(ctx, "Service.GenerateCoachPlan")
+	defer span.End()
+
+	user, err := s.keyUser(ctx, apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	loc, err := s.ownerLocation(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	thisWeek := weekStart(time.Now(), loc)
+	// Only complete weeks count: the current one has barely started on a
+	// Monday and would drag every weekly average down.
+	until := time.Date(thisWeek.Year(), thisWeek.Month(), thisWeek.Day(), 0, 0, 0, 0, loc)
+	since := until.AddDate(0, 0, -7*coachLookbackWeeks)
+
+	spend, err := s.repo.WeeklySpendByOwner(ctx, user.ID, loc.String(), since)
+	if err != nil {
+		return nil, err
+	}
+	revenue, err := s.repo.SumRevenueByOwner(ctx, user.ID, since, until)
+	if err != nil {
+		return nil, err
+	}
+	pots, err := s.repo.PotTotalsByOwner(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := buildCoachPlan(thisWeek, spend, revenue, pots)
+	plan.UserID = user.ID
+	return s.repo.UpsertCoachPlan(ctx, plan)
+}
+
+func (s *Service) ListCoachPlans(ctx context.Context, apiKey string) ([]repository.CoachPlan, error) {
+	ctx, span := tracing.Start(ctx, "Service.ListCoachPlans")
+	defer span.End()
+
+	user, err := s.keyUser(ctx, apiKey)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.ListCoachPlansByUser(ctx, user.ID, coachPlanHistory)
+}
+
+// buildCoachPlan turns the last complete weeks of spend, revenue and pot
+// progress into rules, a nudge and a 0-100 health score; spend in the week
+// of thisWeek is ignored. The most recent complete week is compared against
+// the average of the weeks before it.
+func buildCoachPlan(thisWeek time.Time, spend []repository.WeeklySpend, revenue float64, pots *repository.PotTotals) repository.CoachPlan {
+	lastWeek := thisWeek.AddDate(0, 0, -7)
+
+	var totalSpend, stressSpend float64
+	recent := map[string]float64{}
+	earlier := map[string]float64{}
+	for _, w := range spend {
+		if !w.WeekStart.Before(thisWeek) {
+			continue // the current, partial week
+		}
+		totalSpend += w.Amount
+		if stressMoods[strings.ToLower(w.Mood)] {
+			stressSpend += w.Amount
+		}
+		switch {
+		case w.WeekStart.Equal(lastWeek):
+			recent[w.Category] += w.Amount
+		case w.WeekStart.Before(lastWeek):
+			earlier[w.Category] += w.Amount
+		}
+	}
+
+	rules := []string{}
+	score := 0.0
+
+	// Margin: up to 40 points for keeping expenses well below revenue.
+	switch {
+	case revenue > 0:
+		ratio := totalSpend / revenue
+		score += 40 * clamp(1-ratio, 0, 1)
+		if ratio > 0.7 {
+			rules = append(rules, fmt.Sprintf("Keep total spend under %.0f this week (70%% of recent weekly revenue).", 0.7*revenue/coachLookbackWeeks))
+		}
+	case totalSpend == 0:
+		score += 20
+	default:
+		rules = append(rules, "Record your sales as invoices so spending can be measured against revenue.")
+	}
+
+	// Stability: 20 points, minus 5 for every category that spiked last week.
+	priorWeeks := float64(coachLookbackWeeks - 1)
+	var spikes []string
+	for category, amount := range recent {
+		avg := earlier[category] / priorWeeks
+		if avg > 0 && amount > 1.25*avg {
+			spikes = append(spikes, category)
+		}
+	}
+	sort.Strings(spikes)
+	for _, category := range spikes {
+		avg := earlier[category] / priorWeeks
+		rules = append(rules, fmt.Sprintf("Cap %s at its %d-week average of %.0f this week; last week was %.0f, up %.0f%%.",
+			category, coachLookbackWeeks-1, avg, recent[category], (recent[category]/avg-1)*100))
+	}
+	score += math.Max(0, 20-5*float64(len(spikes)))
+
+	// Mood: 15 points, scaled down by the share of emotionally driven spend.
+	stressShare := 0.0
+	if totalSpend > 0 {
+		stressShare = stressSpend / totalSpend
+	}
+	score += 15 * (1 - stressShare)
+	if stressShare > 0.25 {
+		rules = append(rules, fmt.Sprintf("Wait 24 hours before non-essential buys when stressed; %.0f%% of recent spend was mood-driven.", stressShare*100))
+	}
+
+	// Pots: 25 points for progress towards savings targets.
+	progress := 0.0
+	if pots.Count == 0 || pots.TargetAmount == 0 {
+		rules = append(rules, "Create a savings pot with a target so surplus cash has a home.")
+	} else {
+		progress = clamp(pots.CurrentAmount/pots.TargetAmount, 0, 1)
+		score += 25 * progress
+		if progress < 0.5 {
+			remaining := pots.TargetAmount - pots.CurrentAmount
+			rules = append(rules, fmt.Sprintf("Move %.0f into your pots this week to stay on track.", remaining/coachLookbackWeeks))
+		}
+	}
+
+	if len(rules) == 0 {
+		rules = append(rules, "Keep the current routine: spending is steady and pots are on track.")
+	}
+
+	return repository.CoachPlan{
+		WeekStart:   thisWeek,
+		Rules:       rules,
+		DailyNudge:  dailyNudge(len(spikes) > 0, stressShare, progress, revenue, totalSpend),
+		HealthScore: int(math.Round(clamp(score, 0, 100))),
+	}
+}
+
+func dailyNudge(spiked bool, stressShare, potProgress, revenue, spend float64) string {
+	switch {
+	case revenue > 0 && spend > revenue:
+		return "Spending is ahead of sales. Check each purchase against today's takings."
+	case spiked:
+		return "One category jumped last week. Look at it before you spend today."
+	case stressShare > 0.25:
+		return "Breathe. Check your pots before spending."
+	case potProgress < 0.5:
+		return "A small deposit into a pot today keeps the week on track."
+	default:
+		return "Steady week. Log every sale and expense to keep the picture sharp."
+	}
+}
+
+func clamp(v, lo, hi float64) float64 {
+	return math.Max(lo, math.Min(hi, v))
+}
+
+// ========== INSIGHT DISMISS / SNOOZE ==========
+
+const (
+	defaultSnoozeDays = 7
+	maxSnoozeDays     = 90
+
+	// materialChange is the relative move in any triggering metric that
+	// brings a dismissed insight back.
+	materialChange = 0.2
+)
+
+func (s *Service) DismissCoachInsight(ctx context.Context, apiKey, shopIDStr, insightIDStr string) (*repository.CoachInsightState, error) {
+	ctx, span := tracing.Start(ctx, "Service.DismissCoachInsight")
+	defer span.End()
+
+	return s.setCoachInsightState(ctx, apiKey, shopIDStr, insightIDStr, repository.InsightDismissed, nil)
+}
+
+func (s *Service) SnoozeCoachInsight(ctx context.Context, apiKey, shopIDStr, insightIDStr string, days int) (*repository.CoachInsightState, error) {
+	ctx, span := tracing.Start(ctx, "Service.SnoozeCoachInsight")
+	defer span.End()
+
+	if days == 0 {
+		days = defaultSnoozeDays
+	}
+	if days < 0 || days > maxSnoozeDays {
+		return nil, invalidf("days must be between 1 and %d", maxSnoozeDays)
+	}
+	until := time.Now().AddDate(0, 0, days)
+	return s.setCoachInsightState(ctx, apiKey, shopIDStr, insightIDStr, repository.InsightSnoozed, &until)
+}
+
+// RestoreCoachInsight clears a dismissal or snooze so the insight shows again.
+func (s *Service) RestoreCoachInsight(ctx context.Context, apiKey, shopIDStr, insightIDStr string) error {
+	ctx, span := tracing.Start(ctx, "Service.RestoreCoachInsight")
+	defer span.End()
+
+	shopID, err := uuid.Parse(shopIDStr)
+	if err != nil {
+		return invalidf("invalid shop_id")
+	}
+	insightID, err := uuid.Parse(insightIDStr)
+	if err != nil {
+		return invalidf("invalid insight_id")
+	}
+	user, err := s.keyUser(ctx, apiKey)
+	if err != nil {
+		return err
+	}
+	return s.repo.DeleteCoachInsightState(ctx, user.ID, shopID, insightID)
+}
+
+// setCoachInsightState snapshots the insight's stored metrics so later
+// requests can tell whether the condition has changed since.
+func (s *Service) setCoachInsightState(ctx context.Context, apiKey, shopIDStr, insightIDStr, status string, until *time.Time) (*repository.CoachInsightState, error) {
+	shopID, err := uuid.Parse(shopIDStr)
+	if err != nil {
+		return nil, invalidf("invalid shop_id")
+	}
+	insightID, err := uuid.Parse(insightIDStr)
+	if err != nil {
+		return nil, invalidf("invalid insight_id")
+	}
+	user, err := s.keyUser(ctx, apiKey)
+	if err != nil {
+		return nil, err
+	}
+	insight, err := s.repo.GetCoachInsight(ctx, shopID, insightID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, notFoundf("insight %s is not active for this shop", insightID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.UpsertCoachInsightState(ctx, repository.CoachInsightState{
+		UserID:       user.ID,
+		ShopID:       shopID,
+		InsightID:    insight.ID,
+		Status:       status,
+		SnoozedUntil: until,
+		Metrics:      insight.Metrics,
+	})
+}
+
+func filterCoachInsights(insights []CoachInsight, states []repository.CoachInsightState, now time.Time) []CoachInsight {
+	byID := make(map[uuid.UUID]repository.CoachInsightState, len(states))
+	for _, st := range states {
+		byID[st.InsightID] = st
+	}
+
+	visible := make([]CoachInsight, 0, len(insights))
+	for _, in := range insights {
+		st, ok := byID[in.ID]
+		if ok && coachInsightHidden(in, st, now) {
+			continue
+		}
+		visible = append(visible, in)
+	}
+	return visible
+}
+
+func coachInsightHidden(in CoachInsight, st repository.CoachInsightState, now time.Time) bool {
+	switch st.Status {
+	case repository.InsightSnoozed:
+		return st.SnoozedUntil != nil && now.Before(*st.SnoozedUntil)
+	case repository.InsightDismissed:
+		return !metricsChanged(st.Metrics, in.Metrics)
+	}
+	return false
+}
+
+// coachInsightsStale reports whether a shop's stored set must be generated
+// again: it is empty, or some of it is older than coachInsightsMaxAge.
+func coachInsightsStale(stored []repository.CoachInsight, now time.Time) bool {
+	if len(stored) == 0 {
+		return true
+	}
+	for _, in := range stored {
+		if now.Sub(in.GeneratedAt) > coachInsightsMaxAge {
+			return true
+		}
+	}
+	return false
+}
+
+func coachInsightRow(in CoachInsight) (repository.CoachInsight, error) {
+	row := repository.CoachInsight{
+		Code:     in.Code,
+		Severity: in.Severity,
+		Message:  in.Message,
+		Metrics:  in.Metrics,
+	}
+	if in.Action != nil {
+		action, err := json.Marshal(in.Action)
+		if err != nil {
+			return row, err
+		}
+		row.Action = action
+	}
+	return row, nil
+}
+
+// coachInsightFromRow is the inverse of coachInsightRow. An action that no
+// longer decodes is dropped rather than failing the whole set.
+func coachInsightFromRow(row repository.CoachInsight) CoachInsight {
+	in := CoachInsight{
+		ID:       row.ID,
+		Code:     row.Code,
+		Severity: row.Severity,
+		Message:  row.Message,
+		Metrics:  row.Metrics,
+	}
+	if len(row.Action) > 0 {
+		var action CoachAction
+		if json.Unmarshal(row.Action, &action) == nil {
+			in.Action = &action
+		}
+	}
+	return in
+}
+
+// metricsChanged reports whether any metric moved by more than
+// materialChange relative to the snapshot, or changed sign.
+func metricsChanged(before, after map[string]float64) bool {
+	for key, now := range after {
+		was, ok := before[key]
+		if !ok {
+			return true
+		}
+		if (was < 0) != (now < 0) {
+			return true
+		}
+		base := math.Abs(was)
+		if base == 0 {
+			if now != 0 {
+				return true
+			}
+			continue
+		}
+		if math.Abs(now-was)/base > materialChange {
+			return true
+		}
+	}
+	return false
+}
+
+// ========== COACH FACTS ==========
+
+func (s *Service) coachFacts(ctx context.Context, shopIDStr string) (*CoachFacts, error) {
+	summary, err := s.GetDashboardSummary(ctx, shopIDStr)
+	if err != nil {
+		return nil, err
+	}
+	shopID := uuid.MustParse(shopIDStr) // validated by GetDashboardSummary
+
+	facts := &CoachFacts{Summary: *summary}
+
+	spikes, err := s.repo.CategorySpikes(ctx, shopID, spikeMinRatio, spikeMinIncrease)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range spikes {
+		facts.CategorySpikes = append(facts.CategorySpikes, CategorySpike(c))
+	}
+
+	lowMargin, err := s.repo.LowMarginProducts(ctx, shopID, lowMarginThreshold)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range lowMargin {
+		facts.LowMarginProducts = append(facts.LowMarginProducts, LowMarginProduct{
+			Name:         p.Name,
+			CostPrice:    p.CostPrice,
+			SellingPrice: p.SellingPrice,
+			Margin:       p.Margin,
+		})
+	}
+
+	dead, err := s.repo.DeadStock(ctx, shopID, deadStockDays)
+	if err != nil {
+		return nil, err
+	}
+	facts.DeadStock.Products = dead.Products
+	facts.DeadStock.StockValue = dead.StockValue
+	for _, p := range dead.Top {
+		facts.DeadStock.Top = append(facts.DeadStock.Top, DeadStockItem{
+			Name:       p.Name,
+			Stock:      p.Stock,
+			StockValue: p.StockValue,
+		})
+	}
+
+	overdue, err := s.repo.OverdueCredit(ctx, shopID)
+	if err != nil {
+		return nil, err
+	}
+	facts.OverdueCredit = OverdueCredit{
+		Customers: overdue.Customers,
+		Invoices:  overdue.Invoices,
+		Amount:    overdue.Amount,
+		MaxDays:   overdue.MaxDays,
+	}
+
+	cash, err := s.repo.CashPositionForShop(ctx, shopID, runwayBurnDays)
+	if err != nil {
+		return nil, err
+	}
+	facts.Cash.Cash = cash.Cash
+	if cash.DailyBurn > 0 {
+		facts.Cash.DailyBurn = cash.DailyBurn
+		facts.Cash.RunwayDays = math.Max(0, cash.Cash) / cash.DailyBurn
+	}
+
+	return facts, nil
+}
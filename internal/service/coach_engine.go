@@ -0,0 +1,449 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CoachEngine turns a shop's financial facts into coach insights.
+type CoachEngine interface {
+	Insights(ctx context.Context, facts CoachFacts) ([]CoachInsight, error)
+}
+
+// CoachFacts is everything an engine may look at. All of it is computed with
+// SQL aggregates; customer contact details are deliberately left out so the
+// facts can be sent to an external model.
+type CoachFacts struct {
+	Summary           DashboardSummary   `json:"summary"`
+	CategorySpikes    []CategorySpike    `json:"category_spikes"`
+	LowMarginProducts []LowMarginProduct `json:"low_margin_products"`
+	DeadStock         DeadStock          `json:"dead_stock"`
+	OverdueCredit     OverdueCredit      `json:"overdue_credit"`
+	Cash              CashRunway         `json:"cash"`
+}
+
+type CategorySpike struct {
+	Category string  `json:"category"`
+	ThisWeek float64 `json:"this_week"`
+	LastWeek float64 `json:"last_week"`
+}
+
+type LowMarginProduct struct {
+	Name         string  `json:"name"`
+	CostPrice    float64 `json:"cost_price"`
+	SellingPrice float64 `json:"selling_price"`
+	Margin       float64 `json:"margin"`
+}
+
+type DeadStock struct {
+	Products   int             `json:"products"`
+	StockValue float64         `json:"stock_value"`
+	Top        []DeadStockItem `json:"top"`
+}
+
+type DeadStockItem struct {
+	Name       string  `json:"name"`
+	Stock      int     `json:"stock"`
+	StockValue float64 `json:"stock_value"`
+}
+
+type OverdueCredit struct {
+	Customers int     `json:"customers"`
+	Invoices  int     `json:"invoices"`
+	Amount    float64 `json:"amount"`
+	MaxDays   int     `json:"max_days_overdue"`
+}
+
+// CashRunway is cash on hand and how many days it lasts at the current net
+// burn. DailyBurn and RunwayDays are zero when the shop is not burning cash.
+type CashRunway struct {
+	Cash       float64 `json:"cash"`
+	DailyBurn  float64 `json:"daily_burn"`
+	RunwayDays float64 `json:"runway_days"`
+}
+
+// ========== RULES ENGINE ==========
+
+// RulesEngine is the built-in heuristic coach. It never fails and is used as
+// the fallback for every other engine.
+type RulesEngine struct{}
+
+// Thresholds for the rules engine.
+const (
+	spikeMinRatio      = 0.5  // +50% week over week
+	spikeMinIncrease   = 500  // and at least this much in absolute terms
+	lowMarginThreshold = 0.10 // gross margin under 10% of selling price
+	deadStockDays      = 60
+	runwayBurnDays     = 30
+	runwayWarnDays     = 60
+	runwayCriticalDays = 14
+)
+
+func (RulesEngine) Insights(_ context.Context, facts CoachFacts) ([]CoachInsight, error) {
+	summary := facts.Summary
+	insights := []CoachInsight{}
+
+	if summary.Last30DaysRevenue == 0 {
+		insights = append(insights, CoachInsight{
+			Code:     "NO_REVENUE",
+			Severity: SeverityWarning,
+			Message:  "No revenue in the last 30 days. Try recording invoices regularly.",
+			Metrics: map[string]float64{
+				"last_30_days_revenue": summary.Last30DaysRevenue,
+			},
+			Action: &CoachAction{Label: "Record an invoice", Method: "POST", Endpoint: "/api/invoices"},
+		})
+	}
+
+	if summary.Last30DaysExpenses > 0 && summary.Last30DaysRevenue > 0 {
+		expenseRatio := summary.Last30DaysExpenses / summary.Last30DaysRevenue
+		metrics := map[string]float64{
+			"expense_ratio":         expenseRatio,
+			"last_30_days_revenue":  summary.Last30DaysRevenue,
+			"last_30_days_expenses": summary.Last30DaysExpenses,
+		}
+		if expenseRatio > 0.7 {
+			severity := SeverityWarning
+			if expenseRatio >= 1 {
+				severity = SeverityCritical
+			}
+			insights = append(insights, CoachInsight{
+				Code:     "EXPENSE_RATIO_HIGH",
+				Severity: severity,
+				Message:  "Expenses are more than 70% of revenue in the last 30 days. Time to cut some costs.",
+				Metrics:  metrics,
+				Action:   &CoachAction{Label: "Review expenses", Method: "GET", Endpoint: "/api/shops/{shop_id}/expenses"},
+			})
+		} else if expenseRatio < 0.3 {
+			insights = append(insights, CoachInsight{
+				Code:     "EXPENSE_RATIO_LOW",
+				Severity: SeverityInfo,
+				Message:  "Expenses are under 30% of revenue. Good profitability, consider reinvesting into growth.",
+				Metrics:  metrics,
+				Action:   &CoachAction{Label: "Review products", Method: "GET", Endpoint: "/api/shops/{shop_id}/products"},
+			})
+		}
+	}
+
+	net := map[string]float64{"net_last_30_days": summary.NetLast30Days}
+	if summary.NetLast30Days > 0 {
+		insights = append(insights, CoachInsight{
+			Code:     "NET_POSITIVE",
+			Severity: SeverityInfo,
+			Message:  "You are net positive this month. Allocate part of your profits into a savings pot.",
+			Metrics:  net,
+			Action:   &CoachAction{Label: "Open savings pots", Method: "GET", Endpoint: "/api/shops/{shop_id}/pots"},
+		})
+	} else if summary.NetLast30Days < 0 {
+		insights = append(insights, CoachInsight{
+			Code:     "NET_NEGATIVE",
+			Severity: SeverityCritical,
+			Message:  "You are net negative this month. Review high-cost categories and low-margin products.",
+			Metrics:  net,
+			Action:   &CoachAction{Label: "Review expenses", Method: "GET", Endpoint: "/api/shops/{shop_id}/expenses"},
+		})
+	}
+
+	if len(facts.CategorySpikes) > 0 {
+		top := facts.CategorySpikes[0]
+		change := (top.ThisWeek - top.LastWeek) / top.LastWeek
+		insights = append(insights, CoachInsight{
+			Code:     "CATEGORY_SPIKE",
+			Severity: SeverityWarning,
+			Message: fmt.Sprintf("%s spend is up %.0f%% on last week (%.0f vs %.0f). Check what changed.",
+				top.Category, change*100, top.ThisWeek, top.LastWeek),
+			Metrics: map[string]float64{
+				"this_week":  top.ThisWeek,
+				"last_week":  top.LastWeek,
+				"change":     change,
+				"categories": float64(len(facts.CategorySpikes)),
+			},
+			Action: &CoachAction{Label: "Review expenses", Method: "GET", Endpoint: "/api/shops/{shop_id}/expenses"},
+		})
+	}
+
+	if len(facts.LowMarginProducts) > 0 {
+		worst := facts.LowMarginProducts[0]
+		insights = append(insights, CoachInsight{
+			Code:     "LOW_MARGIN_PRODUCTS",
+			Severity: SeverityWarning,
+			Message: fmt.Sprintf("%s sells at a %.0f%% margin (cost %.0f, price %.0f). Reprice or renegotiate with the supplier.",
+				worst.Name, worst.Margin*100, worst.CostPrice, worst.SellingPrice),
+			Metrics: map[string]float64{
+				"products":      float64(len(facts.LowMarginProducts)),
+				"lowest_margin": worst.Margin,
+			},
+			Action: &CoachAction{Label: "Review products", Method: "GET", Endpoint: "/api/shops/{shop_id}/products"},
+		})
+	}
+
+	if dead := facts.DeadStock; len(dead.Top) > 0 {
+		insights = append(insights, CoachInsight{
+			Code:     "DEAD_STOCK",
+			Severity: SeverityInfo,
+			Message: fmt.Sprintf("%d products (led by %s) have not sold in %d days, tying up %.0f. Consider a discount or bundle.",
+				dead.Products, dead.Top[0].Name, deadStockDays, dead.StockValue),
+			Metrics: map[string]float64{
+				"products":    float64(dead.Products),
+				"stock_value": dead.StockValue,
+			},
+			Action: &CoachAction{Label: "Review products", Method: "GET", Endpoint: "/api/shops/{shop_id}/products"},
+		})
+	}
+
+	if oc := facts.OverdueCredit; oc.Invoices > 0 {
+		severity := SeverityWarning
+		if oc.MaxDays > 30 {
+			severity = SeverityCritical
+		}
+		insights = append(insights, CoachInsight{
+			Code:     "OVERDUE_CREDIT",
+			Severity: severity,
+			Message: fmt.Sprintf("%d customers owe %.0f across %d overdue credit invoices, the oldest %d days late. Follow up today.",
+				oc.Customers, oc.Amount, oc.Invoices, oc.MaxDays),
+			Metrics: map[string]float64{
+				"customers":        float64(oc.Customers),
+				"invoices":         float64(oc.Invoices),
+				"amount":           oc.Amount,
+				"max_days_overdue": float64(oc.MaxDays),
+			},
+			Action: &CoachAction{Label: "Review invoices", Method: "GET", Endpoint: "/api/shops/{shop_id}/invoices"},
+		})
+	}
+
+	if cash := facts.Cash; cash.DailyBurn > 0 && cash.RunwayDays < runwayWarnDays {
+		severity := SeverityWarning
+		if cash.RunwayDays < runwayCriticalDays {
+			severity = SeverityCritical
+		}
+		insights = append(insights, CoachInsight{
+			Code:     "CASH_RUNWAY_LOW",
+			Severity: severity,
+			Message: fmt.Sprintf("At the current burn of %.0f a day, cash lasts about %.0f days. Cut costs or bring in sales.",
+				cash.DailyBurn, cash.RunwayDays),
+			Metrics: map[string]float64{
+				"cash":        cash.Cash,
+				"daily_burn":  cash.DailyBurn,
+				"runway_days": cash.RunwayDays,
+			},
+			Action: &CoachAction{Label: "Review expenses", Method: "GET", Endpoint: "/api/shops/{shop_id}/expenses"},
+		})
+	}
+
+	if len(insights) == 0 {
+		insights = append(insights, CoachInsight{
+			Code:     "DATA_LIMITED",
+			Severity: SeverityInfo,
+			Message:  "Data is limited. Add more invoices and expenses to unlock better insights.",
+			Action:   &CoachAction{Label: "Add an expense", Method: "POST", Endpoint: "/api/expenses"},
+		})
+	}
+
+	return insights, nil
+}
+
+// ========== LLM ENGINE ==========
+
+const (
+	defaultLLMTimeout = 8 * time.Second
+	maxLLMInsights    = 5
+)
+
+const llmSystemPrompt = `You are a financial coach for small Indian retail shops.
+You receive JSON facts about a shop: a revenue/expense summary, expense category
+spikes, low-margin products, dead stock, overdue credit and cash runway
+(amounts in INR).
+Reply with JSON only, in the form
+{"insights":[{"code":"UPPER_SNAKE_CASE","severity":"info|warning|critical","message":"..."}]}.
+Give at most 5 short, specific, actionable insights. Do not invent numbers.`
+
+// LLMEngine asks an OpenAI-compatible chat completions endpoint for insights.
+// Any failure (timeout, non-2xx, unparseable reply) falls back to Fallback.
+type LLMEngine struct {
+	BaseURL  string
+	APIKey   string
+	Model    string
+	Timeout  time.Duration
+	Client   *http.Client
+	Fallback CoachEngine
+}
+
+func NewLLMEngine(baseURL, apiKey, model string, timeout time.Duration) *LLMEngine {
+	if timeout <= 0 {
+		timeout = defaultLLMTimeout
+	}
+	return &LLMEngine{
+		BaseURL:  strings.TrimRight(baseURL, "/"),
+		APIKey:   apiKey,
+		Model:    model,
+		Timeout:  timeout,
+		Client:   &http.Client{},
+		Fallback: RulesEngine{},
+	}
+}
+
+func (e *LLMEngine) Insights(ctx context.Context, facts CoachFacts) ([]CoachInsight, error) {
+	insights, err := e.complete(ctx, facts)
+	if err != nil {
+		slog.WarnContext(ctx, "coach: llm engine failed, using rules", "err", err)
+		if e.Fallback == nil {
+			return RulesEngine{}.Insights(ctx, facts)
+		}
+		return e.Fallback.Insights(ctx, facts)
+	}
+	return insights, nil
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatRequest struct {
+	Model          string            `json:"model"`
+	Messages       []chatMessage     `json:"messages"`
+	Temperature    float64           `json:"temperature"`
+	ResponseFormat map[string]string `json:"response_format"`
+}
+
+type chatResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+}
+
+func (e *LLMEngine) complete(ctx context.Context, coachFacts CoachFacts) ([]CoachInsight, error) {
+	timeout := e.Timeout
+	if timeout <= 0 {
+		timeout = defaultLLMTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	facts, err := json.Marshal(coachFacts)
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(chatRequest{
+		Model: e.Model,
+		Messages: []chatMessage{
+			{Role: "system", Content: llmSystemPrompt},
+			{Role: "user", Content: string(facts)},
+		},
+		Temperature:    0.2,
+		ResponseFormat: map[string]string{"type": "json_object"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.BaseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.APIKey)
+	}
+
+	client := e.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("llm returned status %d", resp.StatusCode)
+	}
+
+	var chat chatResponse
+	if err := json.Unmarshal(raw, &chat); err != nil {
+		return nil, fmt.Errorf("decode chat response: %w", err)
+	}
+	if len(chat.Choices) == 0 {
+		return nil, errors.New("llm returned no choices")
+	}
+	return parseLLMInsights(chat.Choices[0].Message.Content)
+}
+
+// parseLLMInsights reads the model's JSON reply, tolerating a surrounding
+// markdown code fence.
+func parseLLMInsights(content string) ([]CoachInsight, error) {
+	content = strings.TrimSpace(content)
+	content = strings.TrimPrefix(content, "```json")
+	content = strings.TrimPrefix(content, "```")
+	content = strings.TrimSuffix(content, "```")
+
+	var out struct {
+		Insights []CoachInsight `json:"insights"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(content)), &out); err != nil {
+		return nil, fmt.Errorf("decode insights: %w", err)
+	}
+
+	insights := make([]CoachInsight, 0, len(out.Insights))
+	seen := map[string]bool{}
+	for _, in := range out.Insights {
+		in.Message = strings.TrimSpace(in.Message)
+		if in.Message == "" {
+			continue
+		}
+		// Model output is untrusted: keep codes namespaced so they can never
+		// collide with rule codes, and drop anything the app can't render.
+		// Codes identify insights within the stored set, so repeats are numbered.
+		code := "LLM_" + normalizeCode(in.Code)
+		in.Code = code
+		for n := 2; seen[in.Code]; n++ {
+			in.Code = fmt.Sprintf("%s_%d", code, n)
+		}
+		seen[in.Code] = true
+		if !validSeverity(in.Severity) {
+			in.Severity = SeverityInfo
+		}
+		in.ID = uuid.Nil
+		in.Metrics = nil
+		in.Action = nil
+		insights = append(insights, in)
+		if len(insights) == maxLLMInsights {
+			break
+		}
+	}
+	if len(insights) == 0 {
+		return nil, errors.New("llm returned no insights")
+	}
+	return insights, nil
+}
+
+func normalizeCode(code string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(strings.TrimSpace(code)) {
+		switch {
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == '_' || r == ' ' || r == '-':
+			b.WriteByte('_')
+		}
+	}
+	if b.Len() == 0 {
+		return "TIP"
+	}
+	return b.String()
+}
@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var testFacts = CoachFacts{
+	Summary: DashboardSummary{
+		Last30DaysRevenue:  100000,
+		Last30DaysExpenses: 90000,
+		NetLast30Days:      10000,
+	},
+}
+
+func fakeLLM(t *testing.T, handler func(w http.ResponseWriter, r *http.Request)) *LLMEngine {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(handler))
+	t.Cleanup(srv.Close)
+	return NewLLMEngine(srv.URL, "test-key", "test-model", time.Second)
+}
+
+func chatReply(w http.ResponseWriter, content string) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"choices": []map[string]any{
+			{"message": map[string]string{"role": "assistant", "content": content}},
+		},
+	})
+}
+
+func rulesInsights(t *testing.T) []CoachInsight {
+	t.Helper()
+	want, err := RulesEngine{}.Insights(context.Background(), testFacts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return want
+}
+
+func assertInsights(t *testing.T, got, want []CoachInsight) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d insights %v, want %d %v", len(got), got, len(want), want)
+	}
+	for i := range got {
+		if got[i].Message != want[i].Message {
+			t.Errorf("insight %d = %q, want %q", i, got[i].Message, want[i].Message)
+		}
+	}
+}
+
+func TestLLMEngineParsesInsights(t *testing.T) {
+	engine := fakeLLM(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat/completions" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer test-key" {
+			t.Errorf("Authorization = %q", got)
+		}
+		var req chatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatal(err)
+		}
+		if req.Model != "test-model" || len(req.Messages) != 2 {
+			t.Errorf("unexpected request %+v", req)
+		}
+		var facts CoachFacts
+		if err := json.Unmarshal([]byte(req.Messages[1].Content), &facts); err != nil {
+			t.Errorf("user message is not coach facts: %v", err)
+		}
+		chatReply(w, "```json\n{\"insights\":[{\"message\":\"Rent is 40% of expenses.\"},{\"message\":\" \"}]}\n```")
+	})
+
+	got, err := engine.Insights(context.Background(), testFacts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertInsights(t, got, []CoachInsight{{Message: "Rent is 40% of expenses."}})
+}
+
+func TestLLMEngineFallsBackToRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		handler func(w http.ResponseWriter, r *http.Request)
+	}{
+		{
+			name: "server error",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "overloaded", http.StatusServiceUnavailable)
+			},
+		},
+		{
+			name: "invalid json content",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				chatReply(w, "Sure! Here are some tips.")
+			},
+		},
+		{
+			name: "no insights",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				chatReply(w, `{"insights":[]}`)
+			},
+		},
+		{
+			name: "no choices",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"choices":[]}`))
+			},
+		},
+		{
+			name:    "timeout",
+			timeout: 20 * time.Millisecond,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-r.Context().Done():
+				case <-time.After(time.Second):
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := fakeLLM(t, tt.handler)
+			if tt.timeout > 0 {
+				engine.Timeout = tt.timeout
+			}
+			got, err := engine.Insights(context.Background(), testFacts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertInsights(t, got, rulesInsights(t))
+		})
+	}
+}
+
+func TestParseLLMInsights(t *testing.T) {
+	got, err := parseLLMInsights(`{"insights":[
+		{"id":"7d7e1d6e-0000-0000-0000-000000000001","code":"rent high","severity":"critical","message":"Rent is 40% of expenses.","metrics":{"x":1},"action":{"label":"Pay","method":"POST","endpoint":"/v1/payouts"}},
+		{"code":"rent-high","severity":"urgent","message":"Renegotiate the lease."},
+		{"code":"RENT_HIGH","message":"Compare with nearby shops."},
+		{"message":"Log every sale."}
+	]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct{ code, severity string }{
+		{"LLM_RENT_HIGH", SeverityCritical},
+		{"LLM_RENT_HIGH_2", SeverityInfo},
+		{"LLM_RENT_HIGH_3", ""},
+		{"LLM_TIP", ""},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d insights %+v, want %d", len(got), got, len(want))
+	}
+	for i, w := range want {
+		in := got[i]
+		if in.Code != w.code || (w.severity != "" && in.Severity != w.severity) {
+			t.Errorf("insight %d = %s %s, want %s %s", i, in.Code, in.Severity, w.code, w.severity)
+		}
+		if in.ID != uuid.Nil || in.Metrics != nil || in.Action != nil {
+			t.Errorf("insight %d kept model-supplied id, metrics or action: %+v", i, in)
+		}
+	}
+}
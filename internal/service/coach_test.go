@@ -0,0 +1,251 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"fintech-backend/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+func TestCoachInsightsStale(t *testing.T) {
+	now := time.Date(2025, 11, 10, 12, 0, 0, 0, time.UTC)
+	fresh := repository.CoachInsight{GeneratedAt: now.Add(-time.Minute)}
+	old := repository.CoachInsight{GeneratedAt: now.Add(-coachInsightsMaxAge - time.Second)}
+	// Rows a migration carried over for existing state have no generation time.
+	carried := repository.CoachInsight{GeneratedAt: time.Time{}}
+
+	for _, tc := range []struct {
+		name   string
+		stored []repository.CoachInsight
+		want   bool
+	}{
+		{"empty", nil, true},
+		{"fresh", []repository.CoachInsight{fresh, fresh}, false},
+		{"expired", []repository.CoachInsight{fresh, old}, true},
+		{"carried over", []repository.CoachInsight{carried}, true},
+	} {
+		if got := coachInsightsStale(tc.stored, now); got != tc.want {
+			t.Errorf("%s: stale = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestCoachInsightRowRoundTrip(t *testing.T) {
+	id := uuid.New()
+	for _, in := range []CoachInsight{
+		{
+			Code:     "NET_NEGATIVE",
+			Severity: SeverityCritical,
+			Message:  "You are net negative this month.",
+			Metrics:  map[string]float64{"net_last_30_days": -1200},
+			Action:   &CoachAction{Label: "Review expenses", Method: "GET", Endpoint: "/api/shops/x/expenses"},
+		},
+		{Code: "LLM_TIP", Severity: SeverityInfo, Message: "Log every sale."},
+	} {
+		row, err := coachInsightRow(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		row.ID = id
+		in.ID = id
+		if got := coachInsightFromRow(row); !reflect.DeepEqual(got, in) {
+			t.Errorf("round trip of %s = %+v, want %+v", in.Code, got, in)
+		}
+	}
+}
+
+func TestFilterCoachInsightsKeysOnID(t *testing.T) {
+	now := time.Date(2025, 11, 10, 12, 0, 0, 0, time.UTC)
+	// Model-written insights carry no metrics: a dismissal holds for as long
+	// as the stored insight exists.
+	dismissed := CoachInsight{ID: uuid.New(), Code: "LLM_TIP", Message: "Log every sale."}
+	other := CoachInsight{ID: uuid.New(), Code: "LLM_TIP_2", Message: "Restock before Diwali."}
+	states := []repository.CoachInsightState{
+		{InsightID: dismissed.ID, Status: repository.InsightDismissed},
+		{InsightID: uuid.New(), Status: repository.InsightDismissed}, // deleted insight
+	}
+
+	got := filterCoachInsights([]CoachInsight{dismissed, other}, states, now)
+	if len(got) != 1 || got[0].ID != other.ID {
+		t.Errorf("visible = %+v, want only %s", got, other.Code)
+	}
+}
+
+func TestMetricsChanged(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		before, after map[string]float64
+		want          bool
+	}{
+		{"identical", map[string]float64{"ratio": 0.8}, map[string]float64{"ratio": 0.8}, false},
+		{"exactly 20%", map[string]float64{"ratio": 1}, map[string]float64{"ratio": 1.2}, false},
+		{"down within 20%", map[string]float64{"ratio": 1}, map[string]float64{"ratio": 0.8}, false},
+		{"over 20%", map[string]float64{"ratio": 1}, map[string]float64{"ratio": 1.21}, true},
+		{"down over 20%", map[string]float64{"ratio": 1}, map[string]float64{"ratio": 0.79}, true},
+		{"negative base", map[string]float64{"net": -1000}, map[string]float64{"net": -1150}, false},
+		{"sign flip", map[string]float64{"net": -10}, map[string]float64{"net": 1}, true},
+		{"from zero", map[string]float64{"revenue": 0}, map[string]float64{"revenue": 1}, true},
+		{"stays zero", map[string]float64{"revenue": 0}, map[string]float64{"revenue": 0}, false},
+		{"new metric", map[string]float64{"a": 1}, map[string]float64{"a": 1, "b": 1}, true},
+		{"metric gone", map[string]float64{"a": 1, "b": 1}, map[string]float64{"a": 1}, false},
+		{"any metric moves", map[string]float64{"a": 1, "b": 100}, map[string]float64{"a": 1, "b": 150}, true},
+		{"no metrics", nil, nil, false},
+	} {
+		if got := metricsChanged(tc.before, tc.after); got != tc.want {
+			t.Errorf("%s: metricsChanged(%v, %v) = %v, want %v", tc.name, tc.before, tc.after, got, tc.want)
+		}
+	}
+}
+
+func TestFilterCoachInsights(t *testing.T) {
+	now := time.Date(2025, 11, 10, 12, 0, 0, 0, time.UTC)
+	later, earlier := now.Add(time.Hour), now.Add(-time.Hour)
+	in := CoachInsight{ID: uuid.New(), Code: "EXPENSE_RATIO_HIGH", Metrics: map[string]float64{"expense_ratio": 0.9}}
+
+	for _, tc := range []struct {
+		name    string
+		metrics map[string]float64 // current metrics of the insight
+		state   *repository.CoachInsightState
+		visible bool
+	}{
+		{"no state", nil, nil, true},
+		{"snoozed", nil, &repository.CoachInsightState{Status: repository.InsightSnoozed, SnoozedUntil: &later}, false},
+		{"snooze expired", nil, &repository.CoachInsightState{Status: repository.InsightSnoozed, SnoozedUntil: &earlier}, true},
+		{"snooze ends now", nil, &repository.CoachInsightState{Status: repository.InsightSnoozed, SnoozedUntil: &now}, true},
+		{"snooze ignores metrics", map[string]float64{"expense_ratio": 2}, &repository.CoachInsightState{Status: repository.InsightSnoozed, SnoozedUntil: &later}, false},
+		{"dismissed", nil, &repository.CoachInsightState{Status: repository.InsightDismissed, Metrics: map[string]float64{"expense_ratio": 0.8}}, false},
+		{"dismissed, moved under 20%", map[string]float64{"expense_ratio": 0.95}, &repository.CoachInsightState{Status: repository.InsightDismissed, Metrics: map[string]float64{"expense_ratio": 0.8}}, false},
+		{"dismissed, moved over 20%", map[string]float64{"expense_ratio": 1.2}, &repository.CoachInsightState{Status: repository.InsightDismissed, Metrics: map[string]float64{"expense_ratio": 0.8}}, true},
+		{"unknown status", nil, &repository.CoachInsightState{Status: "ARCHIVED"}, true},
+	} {
+		current := in
+		if tc.metrics != nil {
+			current.Metrics = tc.metrics
+		}
+		var states []repository.CoachInsightState
+		if tc.state != nil {
+			st := *tc.state
+			st.InsightID = in.ID
+			states = append(states, st)
+		}
+		got := filterCoachInsights([]CoachInsight{current}, states, now)
+		if (len(got) == 1) != tc.visible {
+			t.Errorf("%s: visible = %v, want %v", tc.name, len(got) == 1, tc.visible)
+		}
+		// Restoring deletes the state, which always brings the insight back.
+		if got := filterCoachInsights([]CoachInsight{current}, nil, now); len(got) != 1 {
+			t.Errorf("%s: hidden after restore", tc.name)
+		}
+	}
+}
+
+func TestWeekStart(t *testing.T) {
+	ist := time.FixedZone("IST", 5*3600+1800)
+	sundayNight := time.Date(2025, 11, 9, 20, 0, 0, 0, time.UTC) // Monday 01:30 in IST
+	for _, tc := range []struct {
+		loc  *time.Location
+		want time.Time
+	}{
+		{time.UTC, time.Date(2025, 11, 3, 0, 0, 0, 0, time.UTC)},
+		{ist, time.Date(2025, 11, 10, 0, 0, 0, 0, time.UTC)},
+	} {
+		if got := weekStart(sundayNight, tc.loc); !got.Equal(tc.want) {
+			t.Errorf("weekStart in %s = %s, want %s", tc.loc, got, tc.want)
+		}
+	}
+}
+
+func TestBuildCoachPlan(t *testing.T) {
+	thisWeek := time.Date(2025, 11, 10, 0, 0, 0, 0, time.UTC)
+	week := func(ago int) time.Time { return thisWeek.AddDate(0, 0, -7*ago) }
+
+	for _, tc := range []struct {
+		name    string
+		spend   []repository.WeeklySpend
+		revenue float64
+		pots    repository.PotTotals
+		score   int
+		rules   []string
+		nudge   string
+	}{
+		{
+			name: "steady",
+			spend: []repository.WeeklySpend{
+				{WeekStart: week(4), Category: "Groceries", Amount: 1000},
+				{WeekStart: week(3), Category: "Groceries", Amount: 1000},
+				{WeekStart: week(2), Category: "Groceries", Amount: 1000},
+				{WeekStart: week(1), Category: "Groceries", Amount: 1000},
+			},
+			revenue: 100000,
+			pots:    repository.PotTotals{Count: 1, TargetAmount: 10000, CurrentAmount: 8000},
+			score:   93, // 38.4 margin + 20 stability + 15 mood + 20 pots
+			rules:   []string{"Keep the current routine: spending is steady and pots are on track."},
+			nudge:   "Steady week. Log every sale and expense to keep the picture sharp.",
+		},
+		{
+			name: "partial current week ignored",
+			spend: []repository.WeeklySpend{
+				{WeekStart: week(4), Category: "Groceries", Amount: 1000},
+				{WeekStart: week(3), Category: "Groceries", Amount: 1000},
+				{WeekStart: week(2), Category: "Groceries", Amount: 1000},
+				{WeekStart: week(1), Category: "Groceries", Amount: 1000},
+				{WeekStart: week(0), Category: "Groceries", Mood: "Stressed", Amount: 90000},
+			},
+			revenue: 100000,
+			pots:    repository.PotTotals{Count: 1, TargetAmount: 10000, CurrentAmount: 8000},
+			score:   93, // as steady
+			rules:   []string{"Keep the current routine: spending is steady and pots are on track."},
+			nudge:   "Steady week. Log every sale and expense to keep the picture sharp.",
+		},
+		{
+			name: "spike while stressed, no sales or pots",
+			spend: []repository.WeeklySpend{
+				{WeekStart: week(3), Category: "Rent", Amount: 3000},
+				{WeekStart: week(1), Category: "Rent", Mood: "Stressed", Amount: 2000},
+			},
+			score: 24, // 15 stability + 9 mood
+			rules: []string{
+				"Record your sales as invoices so spending can be measured against revenue.",
+				"Cap Rent at its 3-week average of 1000 this week; last week was 2000, up 100%.",
+				"Wait 24 hours before non-essential buys when stressed; 40% of recent spend was mood-driven.",
+				"Create a savings pot with a target so surplus cash has a home.",
+			},
+			nudge: "One category jumped last week. Look at it before you spend today.",
+		},
+		{
+			name:    "spending ahead of sales",
+			spend:   []repository.WeeklySpend{{WeekStart: week(1), Category: "Stock", Amount: 12000}},
+			revenue: 10000,
+			pots:    repository.PotTotals{Count: 2, TargetAmount: 10000, CurrentAmount: 1000},
+			score:   38, // 20 stability + 15 mood + 2.5 pots; a new category is no spike
+			rules: []string{
+				"Keep total spend under 1750 this week (70% of recent weekly revenue).",
+				"Move 2250 into your pots this week to stay on track.",
+			},
+			nudge: "Spending is ahead of sales. Check each purchase against today's takings.",
+		},
+		{
+			name:  "no data",
+			score: 55, // 20 margin + 20 stability + 15 mood
+			rules: []string{"Create a savings pot with a target so surplus cash has a home."},
+			nudge: "A small deposit into a pot today keeps the week on track.",
+		},
+	} {
+		plan := buildCoachPlan(thisWeek, tc.spend, tc.revenue, &tc.pots)
+		if !plan.WeekStart.Equal(thisWeek) {
+			t.Errorf("%s: week start %s", tc.name, plan.WeekStart)
+		}
+		if plan.HealthScore != tc.score {
+			t.Errorf("%s: score %d, want %d", tc.name, plan.HealthScore, tc.score)
+		}
+		if !reflect.DeepEqual(plan.Rules, tc.rules) {
+			t.Errorf("%s: rules\n%q\nwant\n%q", tc.name, plan.Rules, tc.rules)
+		}
+		if plan.DailyNudge != tc.nudge {
+			t.Errorf("%s: nudge %q, want %q", tc.name, plan.DailyNudge, tc.nudge)
+		}
+	}
+}
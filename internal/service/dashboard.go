@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"fintech-backend/internal/repository"
+	"fintech-backend/internal/tracing"
+
+	"github.com/google/uuid"
+)
+
+// ========== DASHBOARD SERIES ==========
+
+const (
+	defaultSeriesDays = 30
+	maxSeriesBuckets  = 400
+	dateLayout        = "2006-01-02"
+)
+
+var bucketDays = map[string]int{
+	"day":   1,
+	"week":  7,
+	"month": 28,
+}
+
+type SeriesBucket struct {
+	Start    string  `json:"start"`
+	Revenue  float64 `json:"revenue"`
+	Expenses float64 `json:"expenses"`
+	Net      float64 `json:"net"`
+	Invoices int     `json:"invoices"`
+}
+
+type PeriodTotals struct {
+	From     string  `json:"from"`
+	To       string  `json:"to"`
+	Revenue  float64 `json:"revenue"`
+	Expenses float64 `json:"expenses"`
+	Net      float64 `json:"net"`
+	Invoices int     `json:"invoices"`
+}
+
+// PeriodChange is the relative change against the previous period
+// (0.25 = +25%). A field is null when the previous value was zero.
+type PeriodChange struct {
+	Revenue  *float64 `json:"revenue"`
+	Expenses *float64 `json:"expenses"`
+	Net      *float64 `json:"net"`
+	Invoices *float64 `json:"invoices"`
+}
+
+type DashboardSeries struct {
+	Timezone    string         `json:"timezone"`
+	Granularity string         `json:"granularity"`
+	Buckets     []SeriesBucket `json:"buckets"`
+	Current     PeriodTotals   `json:"current"`
+	Previous    PeriodTotals   `json:"previous"`
+	Change      PeriodChange   `json:"change"`
+}
+
+// GetDashboardSeries returns per-bucket figures for the local dates
+// [from, to] (YYYY-MM-DD, inclusive) in the shop's timezone, compared with the
+// period of equal length just before. Empty from/to default to the last 30
+// days; empty granularity defaults to day.
+func (s *Service) GetDashboardSeries(ctx context.Context, shopIDStr, fromStr, toStr, granularity string) (*DashboardSeries, error) {
+	ctx, span := tracing.Start(ctx, "Service.GetDashboardSeries")
+	defer span.End()
+
+	shopID, err := uuid.Parse(shopIDStr)
+	if err != nil {
+		return nil, invalidf("invalid shop_id")
+	}
+	if granularity == "" {
+		granularity = "day"
+	}
+	if _, ok := bucketDays[granularity]; !ok {
+		return nil, invalidf("granularity must be day, week or month")
+	}
+
+	shop, err := s.repo.GetShopByID(ctx, shopID)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := time.LoadLocation(shop.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("shop has invalid timezone %s", shop.Timezone)
+	}
+
+	now := time.Now().In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	from, to, err := seriesWindow(today, fromStr, toStr, granularity)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, prev, err := s.repo.DashboardSeries(ctx, shopID, shop.Timezone, from, to, granularity)
+	if err != nil {
+		return nil, err
+	}
+	return buildDashboardSeries(shop.Timezone, granularity, from, to, rows, prev), nil
+}
+
+// seriesWindow resolves the requested local dates, as UTC midnights, with
+// an empty to meaning today and an empty from the 30 days ending at to.
+func seriesWindow(today time.Time, fromStr, toStr, granularity string) (from, to time.Time, err error) {
+	to, err = parseDateOr(toStr, today)
+	if err != nil {
+		return from, to, invalidf("invalid to, expected YYYY-MM-DD")
+	}
+	from, err = parseDateOr(fromStr, to.AddDate(0, 0, 1-defaultSeriesDays))
+	if err != nil {
+		return from, to, invalidf("invalid from, expected YYYY-MM-DD")
+	}
+	if to.Before(from) {
+		return from, to, invalidf("from must not be after to")
+	}
+	if seriesDays(from, to)/bucketDays[granularity] > maxSeriesBuckets {
+		return from, to, invalidf("range too large for %s granularity (max %d buckets)", granularity, maxSeriesBuckets)
+	}
+	return from, to, nil
+}
+
+// seriesDays is the number of dates in [from, to].
+func seriesDays(from, to time.Time) int {
+	return int(to.Sub(from).Hours()/24) + 1
+}
+
+func buildDashboardSeries(tz, granularity string, from, to time.Time, rows []repository.SeriesBucket, prev *repository.PeriodTotals) *DashboardSeries {
+	days := seriesDays(from, to)
+	out := &DashboardSeries{
+		Timezone:    tz,
+		Granularity: granularity,
+		Buckets:     make([]SeriesBucket, 0, len(rows)),
+		Current: PeriodTotals{
+			From: from.Format(dateLayout),
+			To:   to.Format(dateLayout),
+		},
+		Previous: PeriodTotals{
+			From:     from.AddDate(0, 0, -days).Format(dateLayout),
+			To:       from.AddDate(0, 0, -1).Format(dateLayout),
+			Revenue:  prev.Revenue,
+			Expenses: prev.Expenses,
+			Net:      prev.Revenue - prev.Expenses,
+			Invoices: prev.Invoices,
+		},
+	}
+	for _, b := range rows {
+		out.Buckets = append(out.Buckets, SeriesBucket{
+			Start:    b.Start.Format(dateLayout),
+			Revenue:  b.Revenue,
+			Expenses: b.Expenses,
+			Net:      b.Revenue - b.Expenses,
+			Invoices: b.Invoices,
+		})
+		out.Current.Revenue += b.Revenue
+		out.Current.Expenses += b.Expenses
+		out.Current.Invoices += b.Invoices
+	}
+	out.Current.Net = out.Current.Revenue - out.Current.Expenses
+
+	out.Change = PeriodChange{
+		Revenue:  relativeChange(out.Current.Revenue, out.Previous.Revenue),
+		Expenses: relativeChange(out.Current.Expenses, out.Previous.Expenses),
+		Net:      relativeChange(out.Current.Net, out.Previous.Net),
+		Invoices: relativeChange(float64(out.Current.Invoices), float64(out.Previous.Invoices)),
+	}
+	return out
+}
+
+func parseDateOr(s string, fallback time.Time) (time.Time, error) {
+	if s == "" {
+		return fallback, nil
+	}
+	return time.Parse(dateLayout, s)
+}
+
+func relativeChange(cur, prev float64) *float64 {
+	if prev == 0 {
+		return nil
+	}
+	change := (cur - prev) / math.Abs(prev)
+	return &change
+}
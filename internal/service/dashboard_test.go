@@ -0,0 +1,111 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"fintech-backend/internal/repository"
+)
+
+func TestSeriesWindow(t *testing.T) {
+	today := time.Date(2025, 11, 30, 0, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name             string
+		from, to, gran   string
+		wantFrom, wantTo string
+		wantErr          string
+	}{
+		{name: "default window", gran: "day", wantFrom: "2025-11-01", wantTo: "2025-11-30"},
+		{name: "default from before to", to: "2025-10-31", gran: "day", wantFrom: "2025-10-02", wantTo: "2025-10-31"},
+		{name: "explicit", from: "2025-11-10", to: "2025-11-12", gran: "day", wantFrom: "2025-11-10", wantTo: "2025-11-12"},
+		{name: "single day", from: "2025-11-10", to: "2025-11-10", gran: "day", wantFrom: "2025-11-10", wantTo: "2025-11-10"},
+		{name: "from after to", from: "2025-11-13", to: "2025-11-12", gran: "day", wantErr: "from must not be after to"},
+		{name: "bad from", from: "11/10/2025", gran: "day", wantErr: "invalid from, expected YYYY-MM-DD"},
+		{name: "bad to", to: "2025-11-31", gran: "day", wantErr: "invalid to, expected YYYY-MM-DD"},
+		{name: "400 daily buckets", from: "2024-10-27", to: "2025-11-30", gran: "day", wantFrom: "2024-10-27", wantTo: "2025-11-30"},
+		{name: "401 daily buckets", from: "2024-10-26", to: "2025-11-30", gran: "day", wantErr: "range too large for day granularity (max 400 buckets)"},
+		{name: "same range weekly", from: "2024-10-26", to: "2025-11-30", gran: "week", wantFrom: "2024-10-26", wantTo: "2025-11-30"},
+	} {
+		from, to, err := seriesWindow(today, tc.from, tc.to, tc.gran)
+		if tc.wantErr != "" {
+			var e *Error
+			if !errors.As(err, &e) || e.Code != CodeValidation || e.Message != tc.wantErr {
+				t.Errorf("%s: err = %v, want invalid %q", tc.name, err, tc.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if got := from.Format(dateLayout); got != tc.wantFrom {
+			t.Errorf("%s: from = %s, want %s", tc.name, got, tc.wantFrom)
+		}
+		if got := to.Format(dateLayout); got != tc.wantTo {
+			t.Errorf("%s: to = %s, want %s", tc.name, got, tc.wantTo)
+		}
+	}
+}
+
+func TestBuildDashboardSeries(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2025, 11, d, 0, 0, 0, 0, time.UTC) }
+	rows := []repository.SeriesBucket{
+		{Start: day(10), Revenue: 300, Expenses: 100, Invoices: 2},
+		{Start: day(11)},
+		{Start: day(12), Revenue: 200, Expenses: 300, Invoices: 1},
+	}
+
+	for _, tc := range []struct {
+		name     string
+		prev     repository.PeriodTotals
+		revenue  *float64
+		expenses *float64
+		net      *float64
+		invoices *float64
+	}{
+		{
+			name:     "against a previous period",
+			prev:     repository.PeriodTotals{Revenue: 400, Expenses: 500, Invoices: 4},
+			revenue:  ptr(0.25),
+			expenses: ptr(-0.2),
+			net:      ptr(2), // -100 -> 100
+			invoices: ptr(-0.25),
+		},
+		{name: "nothing before", prev: repository.PeriodTotals{}},
+	} {
+		prev := tc.prev
+		out := buildDashboardSeries("Asia/Kolkata", "day", day(10), day(12), rows, &prev)
+
+		if out.Current.From != "2025-11-10" || out.Current.To != "2025-11-12" {
+			t.Errorf("%s: current %s..%s", tc.name, out.Current.From, out.Current.To)
+		}
+		if out.Previous.From != "2025-11-07" || out.Previous.To != "2025-11-09" {
+			t.Errorf("%s: previous %s..%s, want 2025-11-07..2025-11-09", tc.name, out.Previous.From, out.Previous.To)
+		}
+		if out.Current.Revenue != 500 || out.Current.Expenses != 400 || out.Current.Net != 100 || out.Current.Invoices != 3 {
+			t.Errorf("%s: current totals %+v", tc.name, out.Current)
+		}
+		if len(out.Buckets) != 3 || out.Buckets[1].Start != "2025-11-11" || out.Buckets[2].Net != -100 {
+			t.Errorf("%s: buckets %+v", tc.name, out.Buckets)
+		}
+		for _, f := range []struct {
+			field     string
+			got, want *float64
+		}{
+			{"revenue", out.Change.Revenue, tc.revenue},
+			{"expenses", out.Change.Expenses, tc.expenses},
+			{"net", out.Change.Net, tc.net},
+			{"invoices", out.Change.Invoices, tc.invoices},
+		} {
+			switch {
+			case f.want == nil && f.got != nil:
+				t.Errorf("%s: %s change = %v, want null", tc.name, f.field, *f.got)
+			case f.want != nil && (f.got == nil || *f.got != *f.want):
+				t.Errorf("%s: %s change = %v, want %v", tc.name, f.field, f.got, *f.want)
+			}
+		}
+	}
+}
+
+func ptr(f float64) *float64 { return &f }
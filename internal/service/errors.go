@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"fintech-backend/internal/repository"
+	"fintech-backend/internal/validate"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Error codes clients can branch on. The router maps each to an HTTP status.
+const (
+	CodeValidation        = "validation_failed"
+	CodeNotFound          = "not_found"
+	CodeConflict          = "conflict"
+	CodeInsufficientStock = "insufficient_stock"
+	CodeForbidden         = "forbidden"
+	CodeTimeout           = "timeout"
+	CodeInternal          = "internal"
+)
+
+// Error is a domain error safe to show to clients. Err, when set, is the
+// underlying cause and is only logged.
+type Error struct {
+	Code    string
+	Message string
+	Details any
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+func invalidf(format string, args ...any) error {
+	return &Error{Code: CodeValidation, Message: fmt.Sprintf(format, args...)}
+}
+
+func notFoundf(format string, args ...any) error {
+	return &Error{Code: CodeNotFound, Message: fmt.Sprintf(format, args...)}
+}
+
+func conflictf(format string, args ...any) error {
+	return &Error{Code: CodeConflict, Message: fmt.Sprintf(format, args...)}
+}
+
+func forbiddenf(format string, args ...any) error {
+	return &Error{Code: CodeForbidden, Message: fmt.Sprintf(format, args...)}
+}
+
+// StockDetails are the details of an insufficient_stock error: the product
+// that ran short, by ID, and how many units were asked for and left.
+type StockDetails struct {
+	ProductID string `json:"product_id"`
+	Requested int    `json:"requested"`
+	Available int    `json:"available"`
+}
+
+// Postgres error codes classified by AsError.
+const (
+	pgUniqueViolation     = "23505"
+	pgForeignKeyViolation = "23503"
+	pgCheckViolation      = "23514"
+	pgNotNullViolation    = "23502"
+	pgInvalidText         = "22P02"
+	pgNumericOutOfRange   = "22003"
+)
+
+// AsError classifies err for a client response. Typed errors pass through;
+// field errors, missing rows, the repository's sentinel errors and constraint
+// violations get their matching code; anything else is internal, with the
+// cause kept in Err rather than the message so SQL never reaches clients.
+func AsError(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	var fields validate.Errors
+	if errors.As(err, &fields) {
+		return &Error{Code: CodeValidation, Message: "validation failed", Details: fields}
+	}
+
+	var stock *repository.StockError
+	if errors.As(err, &stock) {
+		return &Error{Code: CodeInsufficientStock, Message: repository.ErrInsufficientStock.Error(), Details: StockDetails{
+			ProductID: stock.ProductID.String(),
+			Requested: stock.Requested,
+			Available: stock.Available,
+		}}
+	}
+
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return &Error{Code: CodeNotFound, Message: "not found", Err: err}
+	case errors.Is(err, repository.ErrInsufficientStock):
+		return &Error{Code: CodeInsufficientStock, Message: repository.ErrInsufficientStock.Error()}
+	case errors.Is(err, repository.ErrCreditExceedsInvoice), errors.Is(err, repository.ErrPayoutFinal):
+		return &Error{Code: CodeConflict, Message: err.Error()}
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled), pgconn.Timeout(err):
+		return &Error{Code: CodeTimeout, Message: "request timed out", Err: err}
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case pgUniqueViolation:
+			return &Error{Code: CodeConflict, Message: "already exists", Err: err}
+		case pgForeignKeyViolation:
+			return &Error{Code: CodeValidation, Message: "references a record that does not exist", Err: err}
+		case pgCheckViolation, pgNotNullViolation, pgInvalidText, pgNumericOutOfRange:
+			return &Error{Code: CodeValidation, Message: "invalid value", Err: err}
+		}
+	}
+	return &Error{Code: CodeInternal, Message: "internal error", Err: err}
+}
@@ -0,0 +1,24 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+
+	"fintech-backend/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+func TestAsErrorInsufficientStock(t *testing.T) {
+	id := uuid.New()
+	err := fmt.Errorf("create invoice: %w", &repository.StockError{ProductID: id, Requested: 5, Available: 2})
+
+	e := AsError(err)
+	if e.Code != CodeInsufficientStock || e.Message != "not enough stock" {
+		t.Errorf("AsError = %s %q, want insufficient_stock with the fixed message", e.Code, e.Message)
+	}
+	want := StockDetails{ProductID: id.String(), Requested: 5, Available: 2}
+	if e.Details != want {
+		t.Errorf("details = %+v, want %+v", e.Details, want)
+	}
+}
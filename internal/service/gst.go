@@ -0,0 +1,229 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"fintech-backend/internal/dto"
+	"fintech-backend/internal/gst"
+	"fintech-backend/internal/repository"
+	"fintech-backend/internal/tracing"
+	"fintech-backend/internal/validate"
+
+	"github.com/google/uuid"
+)
+
+// ========== GST ==========
+
+// allocateTax sets each item's TaxAmount and returns the invoice tax. With no
+// explicit amount, tax is computed from each line's GST rate; otherwise the
+// explicit amount is spread across lines in proportion to value × rate (or
+// value alone when no line carries a rate), with rounding left on the last
+// line.
+func allocateTax(items []repository.InvoiceItem, explicit float64) float64 {
+	if explicit == 0 {
+		var total float64
+		for i := range items {
+			it := &items[i]
+			it.TaxAmount = round2(it.UnitPrice * float64(it.Quantity) * it.GSTRate / 100)
+			total += it.TaxAmount
+		}
+		return round2(total)
+	}
+
+	weights := make([]float64, len(items))
+	var sum float64
+	for i, it := range items {
+		weights[i] = it.UnitPrice * float64(it.Quantity) * it.GSTRate
+		sum += weights[i]
+	}
+	if sum == 0 {
+		for i, it := range items {
+			weights[i] = it.UnitPrice * float64(it.Quantity)
+			sum += weights[i]
+		}
+	}
+	left := round2(explicit)
+	for i := range items {
+		share := left
+		if i < len(items)-1 && sum > 0 {
+			share = round2(explicit * weights[i] / sum)
+		}
+		items[i].TaxAmount = share
+		left = round2(left - share)
+	}
+	return round2(explicit)
+}
+
+// placeOfSupply picks the state code of the sale: the explicit code, else the
+// customer's GSTIN state, else the shop's. Codes are validated by the caller;
+// a shop GSTIN saved before validation existed is ignored if malformed.
+func placeOfSupply(code string, customerGSTIN *string, shopGSTIN string) *string {
+	switch {
+	case code != "":
+	case customerGSTIN != nil:
+		code = validate.StateCode(*customerGSTIN)
+	case validate.GSTIN(shopGSTIN) == nil:
+		code = validate.StateCode(shopGSTIN)
+	default:
+		return nil
+	}
+	return &code
+}
+
+// invoiceNumber is the document number reported to GSTN, derived from the
+// invoice ID so it is unique without a separate sequence.
+func invoiceNumber(id uuid.UUID) string {
+	return strings.ToUpper(strings.ReplaceAll(id.String(), "-", "")[:16])
+}
+
+func creditNoteNumber(id uuid.UUID) string {
+	return "CN" + strings.ToUpper(strings.ReplaceAll(id.String(), "-", "")[:14])
+}
+
+func (s *Service) CreateCreditNote(ctx context.Context, invoiceIDStr string, req dto.CreateCreditNoteRequest) (*repository.CreditNote, error) {
+	ctx, span := tracing.Start(ctx, "Service.CreateCreditNote")
+	defer span.End()
+
+	invoiceID, err := uuid.Parse(invoiceIDStr)
+	if err != nil {
+		return nil, invalidf("invalid invoice_id")
+	}
+	if req.TaxableValue <= 0 {
+		return nil, invalidf("taxable_value must be positive")
+	}
+	if !gst.ValidRate(req.GSTRate) {
+		return nil, invalidf("invalid gst_rate: %g", req.GSTRate)
+	}
+	inv, err := s.repo.GetInvoice(ctx, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+
+	cn := repository.CreditNote{
+		InvoiceID:    invoiceID,
+		TaxableValue: round2(req.TaxableValue),
+		GSTRate:      req.GSTRate,
+		TaxAmount:    round2(req.TaxableValue * req.GSTRate / 100),
+		Reason:       optional(req.Reason),
+	}
+	return s.repo.CreateCreditNote(ctx, cn, creditNoteJournal(inv, &cn))
+}
+
+// gstPeriod is the shop's GST data for one return period.
+type gstPeriod struct {
+	gstin    string
+	period   gst.Period
+	shopID   uuid.UUID
+	from, to time.Time
+	invoices []gst.Invoice
+	notes    []gst.CreditNote
+}
+
+func (s *Service) loadGSTPeriod(ctx context.Context, shopIDStr, periodStr string) (*gstPeriod, error) {
+	shopID, err := uuid.Parse(shopIDStr)
+	if err != nil {
+		return nil, invalidf("invalid shop_id")
+	}
+	if periodStr == "" {
+		return nil, invalidf("period is required, e.g. ?period=2025-11")
+	}
+	period, err := gst.ParsePeriod(periodStr)
+	if err != nil {
+		return nil, invalidf("%s", err)
+	}
+	shop, err := s.repo.GetShopByID(ctx, shopID)
+	if err != nil {
+		return nil, err
+	}
+	gstin := validate.NormalizeGSTIN(shop.GSTNumber)
+	if validate.GSTIN(gstin) != nil {
+		return nil, invalidf("shop has no valid GSTIN; set gst_number to export returns")
+	}
+	loc, err := time.LoadLocation(shop.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("shop has invalid timezone %s", shop.Timezone)
+	}
+	from, to := period.Bounds(loc)
+
+	invoices, err := s.repo.GSTInvoices(ctx, shopID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	notes, err := s.repo.GSTCreditNotes(ctx, shopID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	gp := &gstPeriod{gstin: gstin, period: period, shopID: shopID, from: from, to: to}
+	for _, iv := range invoices {
+		inv := gstInvoice(iv.Invoice, loc)
+		for _, l := range iv.Lines {
+			inv.Lines = append(inv.Lines, gst.Line{
+				HSN:      deref(l.HSNCode),
+				Rate:     l.GSTRate,
+				Quantity: float64(l.Quantity),
+				Taxable:  l.Taxable,
+				Tax:      l.Tax,
+			})
+		}
+		gp.invoices = append(gp.invoices, inv)
+	}
+	for _, n := range notes {
+		gp.notes = append(gp.notes, gst.CreditNote{
+			Number:  creditNoteNumber(n.ID),
+			Date:    n.CreatedAt.In(loc),
+			Invoice: gstInvoice(n.Invoice, loc),
+			Taxable: n.TaxableValue,
+			Rate:    n.GSTRate,
+			Tax:     n.TaxAmount,
+		})
+	}
+	return gp, nil
+}
+
+func gstInvoice(iv repository.Invoice, loc *time.Location) gst.Invoice {
+	return gst.Invoice{
+		Number:        invoiceNumber(iv.ID),
+		Date:          iv.CreatedAt.In(loc),
+		Value:         iv.TotalAmount,
+		CustomerGSTIN: deref(iv.CustomerGSTIN),
+		CustomerName:  iv.CustomerName,
+		POS:           deref(iv.PlaceOfSupply),
+	}
+}
+
+// GetGSTR1 builds the shop's GSTR-1 for period (YYYY-MM or MMYYYY).
+func (s *Service) GetGSTR1(ctx context.Context, shopIDStr, period string) (*gst.GSTR1, error) {
+	ctx, span := tracing.Start(ctx, "Service.GetGSTR1")
+	defer span.End()
+
+	gp, err := s.loadGSTPeriod(ctx, shopIDStr, period)
+	if err != nil {
+		return nil, err
+	}
+	return gst.BuildGSTR1(gp.gstin, gp.period, gp.invoices, gp.notes), nil
+}
+
+// GetGSTR3B builds the shop's GSTR-3B summary for period, claiming input tax
+// on expenses booked with a supplier GSTIN.
+func (s *Service) GetGSTR3B(ctx context.Context, shopIDStr, period string) (*gst.GSTR3B, error) {
+	ctx, span := tracing.Start(ctx, "Service.GetGSTR3B")
+	defer span.End()
+
+	gp, err := s.loadGSTPeriod(ctx, shopIDStr, period)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.repo.InputTaxByState(ctx, gp.shopID, gp.from, gp.to)
+	if err != nil {
+		return nil, err
+	}
+	inputs := make([]gst.InputTax, 0, len(rows))
+	for _, r := range rows {
+		inputs = append(inputs, gst.InputTax{SupplierState: r.StateCode, Tax: r.InputTax})
+	}
+	return gst.BuildGSTR3B(gp.gstin, gp.period, gp.invoices, gp.notes, inputs), nil
+}
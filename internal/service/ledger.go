@@ -0,0 +1,455 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"fintech-backend/internal/repository"
+	"fintech-backend/internal/tracing"
+
+	"github.com/google/uuid"
+)
+
+// ========== LEDGER ==========
+//
+// Every operation that moves money posts a balanced journal entry in the same
+// transaction as the operation itself:
+//
+//	invoice (PAID)    Dr cash             Cr revenue, tax_payable
+//	invoice (CREDIT)  Dr receivables      Cr revenue, tax_payable
+//	invoice settled   Dr cash             Cr receivables
+//	expense           Dr expense:<cat>, gst_input Cr cash
+//	credit note       Dr revenue, tax_payable     Cr cash or receivables
+//	pot deposit       Dr pot:<id>         Cr cash
+//	payout created    Dr payouts_clearing Cr bank
+//	payout success    Dr expense:payouts  Cr payouts_clearing
+//	payout failed     Dr bank             Cr payouts_clearing
+//
+// Shops keep their own book; payouts are booked to the owner of the API key.
+// Migration 0012 posted the same entries for rows created before the ledger,
+// plus one pot_opening entry per pot for deposits made before it.
+
+var (
+	accountCash            = repository.Account{Code: "cash", Name: "Cash", Type: repository.AccountAsset}
+	accountBank            = repository.Account{Code: "bank", Name: "Bank", Type: repository.AccountAsset}
+	accountReceivables     = repository.Account{Code: "receivables", Name: "Accounts receivable", Type: repository.AccountAsset}
+	accountPayoutsClearing = repository.Account{Code: "payouts_clearing", Name: "Payouts clearing", Type: repository.AccountAsset}
+	accountTaxPayable      = repository.Account{Code: "tax_payable", Name: "GST payable", Type: repository.AccountLiability}
+	accountInputTax        = repository.Account{Code: "gst_input", Name: "GST input credit", Type: repository.AccountAsset}
+	accountRevenue         = repository.Account{Code: "revenue", Name: "Sales revenue", Type: repository.AccountIncome}
+	accountPayoutsExpense  = repository.Account{Code: "expense:payouts", Name: "Payouts disbursed", Type: repository.AccountExpense}
+)
+
+func expenseAccount(category string) repository.Account {
+	name := strings.TrimSpace(category)
+	if name == "" {
+		name = "Uncategorized"
+	}
+	return repository.Account{
+		Code: "expense:" + strings.ToLower(strings.Join(strings.Fields(name), "_")),
+		Name: name,
+		Type: repository.AccountExpense,
+	}
+}
+
+func potAccount(p *repository.Pot) repository.Account {
+	return repository.Account{
+		Code: "pot:" + p.ID.String(),
+		Name: "Pot: " + p.Name,
+		Type: repository.AccountAsset,
+	}
+}
+
+func invoiceJournal(inv *repository.Invoice) repository.JournalEntry {
+	debit := accountCash
+	if inv.Status == "CREDIT" {
+		debit = accountReceivables
+	}
+	return repository.JournalEntry{
+		Memo:   "Invoice to " + inv.CustomerName,
+		Source: "invoice",
+		Lines: []repository.JournalLine{
+			{Account: debit, Debit: inv.TotalAmount},
+			{Account: accountRevenue, Credit: inv.TotalAmount - inv.TaxAmount},
+			{Account: accountTaxPayable, Credit: inv.TaxAmount},
+		},
+	}
+}
+
+// settlementJournal collects what a customer still owed on a CREDIT invoice.
+func settlementJournal(inv *repository.Invoice, outstanding float64) repository.JournalEntry {
+	return repository.JournalEntry{
+		Memo:   "Payment from " + inv.CustomerName,
+		Source: "invoice_settlement",
+		Lines: []repository.JournalLine{
+			{Account: accountCash, Debit: outstanding},
+			{Account: accountReceivables, Credit: outstanding},
+		},
+	}
+}
+
+// expenseJournal books claimable input tax to gst_input rather than the
+// expense account; without a supplier GSTIN the whole amount is expense.
+func expenseJournal(e *repository.Expense) repository.JournalEntry {
+	var itc float64
+	if e.SupplierGSTIN != nil {
+		itc = e.InputTax
+	}
+	return repository.JournalEntry{
+		Memo:   "Expense: " + e.Category,
+		Source: "expense",
+		Lines: []repository.JournalLine{
+			{Account: expenseAccount(e.Category), Debit: e.Amount - itc},
+			{Account: accountInputTax, Debit: itc},
+			{Account: accountCash, Credit: e.Amount},
+		},
+	}
+}
+
+// creditNoteJournal reverses revenue and output tax, refunding cash for paid
+// invoices and reducing receivables for credit ones.
+func creditNoteJournal(inv *repository.Invoice, cn *repository.CreditNote) repository.JournalEntry {
+	credit := accountCash
+	if inv.Status == "CREDIT" {
+		credit = accountReceivables
+	}
+	return repository.JournalEntry{
+		Memo:   "Credit note to " + inv.CustomerName,
+		Source: "credit_note",
+		Lines: []repository.JournalLine{
+			{Account: accountRevenue, Debit: cn.TaxableValue},
+			{Account: accountTaxPayable, Debit: cn.TaxAmount},
+			{Account: credit, Credit: cn.TaxableValue + cn.TaxAmount},
+		},
+	}
+}
+
+func potDepositJournal(p *repository.Pot, amount float64) repository.JournalEntry {
+	return repository.JournalEntry{
+		Memo:   "Deposit to pot " + p.Name,
+		Source: "pot_deposit",
+		Lines: []repository.JournalLine{
+			{Account: potAccount(p), Debit: amount},
+			{Account: accountCash, Credit: amount},
+		},
+	}
+}
+
+// payoutJournal is the entry for a payout moving to status.
+func payoutJournal(p *repository.Payout, status string) repository.JournalEntry {
+	amount := float64(p.AmountCents) / 100
+	je := repository.JournalEntry{Source: "payout"}
+	switch status {
+	case repository.PayoutProcessing:
+		je.Memo = "Payout initiated"
+		je.Lines = []repository.JournalLine{
+			{Account: accountPayoutsClearing, Debit: amount},
+			{Account: accountBank, Credit: amount},
+		}
+	case repository.PayoutSuccess:
+		je.Memo = "Payout settled"
+		je.Lines = []repository.JournalLine{
+			{Account: accountPayoutsExpense, Debit: amount},
+			{Account: accountPayoutsClearing, Credit: amount},
+		}
+	case repository.PayoutFailed:
+		je.Memo = "Payout reversed"
+		je.Lines = []repository.JournalLine{
+			{Account: accountBank, Debit: amount},
+			{Account: accountPayoutsClearing, Credit: amount},
+		}
+	}
+	return je
+}
+
+// LedgerAccount is an account's totals for a report. Balance is on the
+// account's normal side: debit minus credit for assets and expenses, credit
+// minus debit for everything else.
+type LedgerAccount struct {
+	Code    string  `json:"code"`
+	Name    string  `json:"name"`
+	Type    string  `json:"type"`
+	Debit   float64 `json:"debit"`
+	Credit  float64 `json:"credit"`
+	Balance float64 `json:"balance"`
+}
+
+type TrialBalance struct {
+	AsOf        string          `json:"as_of"`
+	Accounts    []LedgerAccount `json:"accounts"`
+	TotalDebit  float64         `json:"total_debit"`
+	TotalCredit float64         `json:"total_credit"`
+	Balanced    bool            `json:"balanced"`
+}
+
+type ProfitAndLoss struct {
+	From          string          `json:"from"`
+	To            string          `json:"to"`
+	Income        []LedgerAccount `json:"income"`
+	Expenses      []LedgerAccount `json:"expenses"`
+	TotalIncome   float64         `json:"total_income"`
+	TotalExpenses float64         `json:"total_expenses"`
+	NetProfit     float64         `json:"net_profit"`
+}
+
+// BalanceSheet includes retained earnings (all income less all expenses to
+// date) in TotalEquity, so TotalAssets = TotalLiabilities + TotalEquity.
+type BalanceSheet struct {
+	AsOf             string          `json:"as_of"`
+	Assets           []LedgerAccount `json:"assets"`
+	Liabilities      []LedgerAccount `json:"liabilities"`
+	Equity           []LedgerAccount `json:"equity"`
+	RetainedEarnings float64         `json:"retained_earnings"`
+	TotalAssets      float64         `json:"total_assets"`
+	TotalLiabilities float64         `json:"total_liabilities"`
+	TotalEquity      float64         `json:"total_equity"`
+	Balanced         bool            `json:"balanced"`
+}
+
+// shopBook resolves a shop's ledger book and the timezone its report dates
+// are read in.
+func (s *Service) shopBook(ctx context.Context, shopIDStr string) (uuid.UUID, *time.Location, error) {
+	shopID, err := uuid.Parse(shopIDStr)
+	if err != nil {
+		return uuid.Nil, nil, invalidf("invalid shop_id")
+	}
+	shop, err := s.repo.GetShopByID(ctx, shopID)
+	if err != nil {
+		return uuid.Nil, nil, err
+	}
+	loc, err := time.LoadLocation(shop.Timezone)
+	if err != nil {
+		return uuid.Nil, nil, fmt.Errorf("shop has invalid timezone %s", shop.Timezone)
+	}
+	return shop.ID, loc, nil
+}
+
+// payoutBook resolves the payouts ledger book of the API key's owner.
+func (s *Service) payoutBook(ctx context.Context, apiKey string) (uuid.UUID, *time.Location, error) {
+	owner, err := s.keyOwner(ctx, apiKey)
+	if err != nil {
+		return uuid.Nil, nil, err
+	}
+	if owner == nil {
+		return uuid.Nil, time.UTC, nil
+	}
+	return owner.ID, time.UTC, nil
+}
+
+func (s *Service) GetShopTrialBalance(ctx context.Context, shopIDStr, asOf string) (*TrialBalance, error) {
+	ctx, span := tracing.Start(ctx, "Service.GetShopTrialBalance")
+	defer span.End()
+
+	book, loc, err := s.shopBook(ctx, shopIDStr)
+	if err != nil {
+		return nil, err
+	}
+	return s.trialBalance(ctx, book, loc, asOf)
+}
+
+func (s *Service) GetShopProfitAndLoss(ctx context.Context, shopIDStr, from, to string) (*ProfitAndLoss, error) {
+	ctx, span := tracing.Start(ctx, "Service.GetShopProfitAndLoss")
+	defer span.End()
+
+	book, loc, err := s.shopBook(ctx, shopIDStr)
+	if err != nil {
+		return nil, err
+	}
+	return s.profitAndLoss(ctx, book, loc, from, to)
+}
+
+func (s *Service) GetShopBalanceSheet(ctx context.Context, shopIDStr, asOf string) (*BalanceSheet, error) {
+	ctx, span := tracing.Start(ctx, "Service.GetShopBalanceSheet")
+	defer span.End()
+
+	book, loc, err := s.shopBook(ctx, shopIDStr)
+	if err != nil {
+		return nil, err
+	}
+	return s.balanceSheet(ctx, book, loc, asOf)
+}
+
+func (s *Service) GetPayoutTrialBalance(ctx context.Context, apiKey, asOf string) (*TrialBalance, error) {
+	ctx, span := tracing.Start(ctx, "Service.GetPayoutTrialBalance")
+	defer span.End()
+
+	book, loc, err := s.payoutBook(ctx, apiKey)
+	if err != nil {
+		return nil, err
+	}
+	return s.trialBalance(ctx, book, loc, asOf)
+}
+
+func (s *Service) GetPayoutProfitAndLoss(ctx context.Context, apiKey, from, to string) (*ProfitAndLoss, error) {
+	ctx, span := tracing.Start(ctx, "Service.GetPayoutProfitAndLoss")
+	defer span.End()
+
+	book, loc, err := s.payoutBook(ctx, apiKey)
+	if err != nil {
+		return nil, err
+	}
+	return s.profitAndLoss(ctx, book, loc, from, to)
+}
+
+func (s *Service) GetPayoutBalanceSheet(ctx context.Context, apiKey, asOf string) (*BalanceSheet, error) {
+	ctx, span := tracing.Start(ctx, "Service.GetPayoutBalanceSheet")
+	defer span.End()
+
+	book, loc, err := s.payoutBook(ctx, apiKey)
+	if err != nil {
+		return nil, err
+	}
+	return s.balanceSheet(ctx, book, loc, asOf)
+}
+
+func (s *Service) trialBalance(ctx context.Context, book uuid.UUID, loc *time.Location, asOfStr string) (*TrialBalance, error) {
+	asOf, end, err := reportEnd(asOfStr, loc)
+	if err != nil {
+		return nil, err
+	}
+	balances, err := s.repo.AccountBalances(ctx, book, nil, &end)
+	if err != nil {
+		return nil, err
+	}
+
+	tb := &TrialBalance{AsOf: asOf, Accounts: []LedgerAccount{}}
+	for _, b := range balances {
+		a := ledgerAccount(b)
+		tb.Accounts = append(tb.Accounts, a)
+		tb.TotalDebit += a.Debit
+		tb.TotalCredit += a.Credit
+	}
+	tb.TotalDebit, tb.TotalCredit = round2(tb.TotalDebit), round2(tb.TotalCredit)
+	tb.Balanced = tb.TotalDebit == tb.TotalCredit
+	return tb, nil
+}
+
+// profitAndLoss covers local dates [from, to]; both default to the current
+// month to date.
+func (s *Service) profitAndLoss(ctx context.Context, book uuid.UUID, loc *time.Location, fromStr, toStr string) (*ProfitAndLoss, error) {
+	now := time.Now().In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	to, err := parseLocalDate(toStr, today, loc)
+	if err != nil {
+		return nil, invalidf("invalid to, expected YYYY-MM-DD")
+	}
+	from, err := parseLocalDate(fromStr, time.Date(to.Year(), to.Month(), 1, 0, 0, 0, 0, loc), loc)
+	if err != nil {
+		return nil, invalidf("invalid from, expected YYYY-MM-DD")
+	}
+	if to.Before(from) {
+		return nil, invalidf("from must not be after to")
+	}
+	end := to.AddDate(0, 0, 1)
+
+	balances, err := s.repo.AccountBalances(ctx, book, &from, &end)
+	if err != nil {
+		return nil, err
+	}
+
+	pl := &ProfitAndLoss{
+		From:     from.Format(dateLayout),
+		To:       to.Format(dateLayout),
+		Income:   []LedgerAccount{},
+		Expenses: []LedgerAccount{},
+	}
+	for _, b := range balances {
+		a := ledgerAccount(b)
+		switch a.Type {
+		case repository.AccountIncome:
+			pl.Income = append(pl.Income, a)
+			pl.TotalIncome += a.Balance
+		case repository.AccountExpense:
+			pl.Expenses = append(pl.Expenses, a)
+			pl.TotalExpenses += a.Balance
+		}
+	}
+	pl.TotalIncome, pl.TotalExpenses = round2(pl.TotalIncome), round2(pl.TotalExpenses)
+	pl.NetProfit = round2(pl.TotalIncome - pl.TotalExpenses)
+	return pl, nil
+}
+
+func (s *Service) balanceSheet(ctx context.Context, book uuid.UUID, loc *time.Location, asOfStr string) (*BalanceSheet, error) {
+	asOf, end, err := reportEnd(asOfStr, loc)
+	if err != nil {
+		return nil, err
+	}
+	balances, err := s.repo.AccountBalances(ctx, book, nil, &end)
+	if err != nil {
+		return nil, err
+	}
+
+	bs := &BalanceSheet{
+		AsOf:        asOf,
+		Assets:      []LedgerAccount{},
+		Liabilities: []LedgerAccount{},
+		Equity:      []LedgerAccount{},
+	}
+	for _, b := range balances {
+		a := ledgerAccount(b)
+		switch a.Type {
+		case repository.AccountAsset:
+			bs.Assets = append(bs.Assets, a)
+			bs.TotalAssets += a.Balance
+		case repository.AccountLiability:
+			bs.Liabilities = append(bs.Liabilities, a)
+			bs.TotalLiabilities += a.Balance
+		case repository.AccountEquity:
+			bs.Equity = append(bs.Equity, a)
+			bs.TotalEquity += a.Balance
+		case repository.AccountIncome:
+			bs.RetainedEarnings += a.Balance
+		case repository.AccountExpense:
+			bs.RetainedEarnings -= a.Balance
+		}
+	}
+	bs.RetainedEarnings = round2(bs.RetainedEarnings)
+	bs.TotalAssets = round2(bs.TotalAssets)
+	bs.TotalLiabilities = round2(bs.TotalLiabilities)
+	bs.TotalEquity = round2(bs.TotalEquity + bs.RetainedEarnings)
+	bs.Balanced = bs.TotalAssets == round2(bs.TotalLiabilities+bs.TotalEquity)
+	return bs, nil
+}
+
+func ledgerAccount(b repository.AccountBalance) LedgerAccount {
+	a := LedgerAccount{
+		Code:   b.Code,
+		Name:   b.Name,
+		Type:   b.Type,
+		Debit:  round2(b.Debit),
+		Credit: round2(b.Credit),
+	}
+	switch b.Type {
+	case repository.AccountAsset, repository.AccountExpense:
+		a.Balance = round2(b.Debit - b.Credit)
+	default:
+		a.Balance = round2(b.Credit - b.Debit)
+	}
+	return a
+}
+
+// reportEnd turns an inclusive local as_of date (default today) into the
+// exclusive end instant for the report.
+func reportEnd(asOfStr string, loc *time.Location) (string, time.Time, error) {
+	now := time.Now().In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	asOf, err := parseLocalDate(asOfStr, today, loc)
+	if err != nil {
+		return "", time.Time{}, invalidf("invalid as_of, expected YYYY-MM-DD")
+	}
+	return asOf.Format(dateLayout), asOf.AddDate(0, 0, 1), nil
+}
+
+func parseLocalDate(s string, fallback time.Time, loc *time.Location) (time.Time, error) {
+	if s == "" {
+		return fallback, nil
+	}
+	return time.ParseInLocation(dateLayout, s, loc)
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
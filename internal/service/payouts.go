@@ -0,0 +1,377 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+
+	"fintech-backend/internal/dto"
+	"fintech-backend/internal/metrics"
+	"fintech-backend/internal/provider"
+	"fintech-backend/internal/repository"
+	"fintech-backend/internal/tracing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ========== PAYOUTS ==========
+
+const (
+	defaultLedgerLimit = 20
+	maxLedgerLimit     = 100
+)
+
+var (
+	vpaPattern     = regexp.MustCompile(`^[a-zA-Z0-9._-]{2,256}@[a-zA-Z][a-zA-Z0-9]{1,63}$`)
+	ifscPattern    = regexp.MustCompile(`^[A-Z]{4}0[A-Z0-9]{6}$`)
+	accountPattern = regexp.MustCompile(`^[0-9]{9,18}$`)
+)
+
+// CreatePayout records and initiates a payout. The API key's user, if any,
+// owns the payout and receives its webhooks.
+func (s *Service) CreatePayout(ctx context.Context, apiKey string, req dto.CreatePayoutRequest) (*repository.Payout, error) {
+	ctx, span := tracing.Start(ctx, "Service.CreatePayout")
+	defer span.End()
+
+	amount := req.AmountCents
+	if amount == 0 {
+		amount = int64(math.Round(req.Amount * 100))
+	}
+	if amount <= 0 {
+		return nil, invalidf("amount must be positive")
+	}
+
+	currency := strings.ToUpper(req.Currency)
+	if currency == "" {
+		currency = "INR"
+	}
+	if currency != "INR" {
+		return nil, invalidf("only INR payouts are supported")
+	}
+
+	p := repository.Payout{
+		ID:          repository.NewID("po_"),
+		AmountCents: amount,
+		Currency:    currency,
+		Method:      req.Method,
+		Status:      repository.PayoutProcessing,
+	}
+	if req.ReferenceID != "" {
+		ref := req.ReferenceID
+		p.ReferenceID = &ref
+	}
+
+	switch req.Method {
+	case "upi":
+		if req.UPI == nil || !vpaPattern.MatchString(req.UPI.VPA) {
+			return nil, invalidf("upi.vpa must be a valid VPA, e.g. name@bank")
+		}
+		vpa := strings.ToLower(req.UPI.VPA)
+		p.DestVPA = &vpa
+		p.DestName = optional(req.UPI.Name)
+	case "bank":
+		if req.Bank == nil {
+			return nil, invalidf("bank destination is required for method bank")
+		}
+		ifsc := strings.ToUpper(req.Bank.IFSC)
+		if !accountPattern.MatchString(req.Bank.Account) {
+			return nil, invalidf("bank.account must be 9 to 18 digits")
+		}
+		if !ifscPattern.MatchString(ifsc) {
+			return nil, invalidf("bank.ifsc must be a valid IFSC, e.g. HDFC0001234")
+		}
+		account := req.Bank.Account
+		p.DestAccount = &account
+		p.DestIFSC = &ifsc
+		p.DestName = optional(req.Bank.Name)
+	default:
+		return nil, invalidf("method must be upi or bank")
+	}
+
+	owner, err := s.keyOwner(ctx, apiKey)
+	if err != nil {
+		return nil, err
+	}
+	if owner != nil {
+		p.UserID = &owner.ID
+	}
+
+	created, err := s.repo.CreatePayout(ctx, p, payoutJournal(&p, repository.PayoutProcessing))
+	if err != nil {
+		return nil, err
+	}
+	metrics.Payouts.WithLabelValues(repository.PayoutProcessing).Inc()
+
+	res, err := s.payouts.Initiate(ctx, providerPayout(created))
+	if err != nil {
+		return s.setPayoutStatus(ctx, created, repository.PayoutFailed, nil, optional("PROVIDER_ERROR: "+err.Error()))
+	}
+	return s.applyProviderResult(ctx, created, res)
+}
+
+// CancelPayout asks the provider to stop a processing payout and records it
+// as failed with the provider's error code.
+func (s *Service) CancelPayout(ctx context.Context, apiKey, id string) (*repository.Payout, error) {
+	ctx, span := tracing.Start(ctx, "Service.CancelPayout")
+	defer span.End()
+
+	p, err := s.GetPayout(ctx, apiKey, id)
+	if err != nil {
+		return nil, err
+	}
+	if p.Status != repository.PayoutProcessing {
+		return nil, conflictf("payout is already %s", p.Status)
+	}
+	res, err := s.payouts.Cancel(ctx, providerPayout(p))
+	if errors.Is(err, provider.ErrNotCancellable) {
+		// It resolved at the provider after the check above; record the
+		// outcome now rather than waiting for the reconciler.
+		return nil, s.payoutResolved(ctx, p)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.applyProviderResult(ctx, p, res)
+}
+
+// payoutResolved records the provider's final status for p, which the
+// provider refused to cancel, and returns the conflict to report.
+func (s *Service) payoutResolved(ctx context.Context, p *repository.Payout) error {
+	res, err := s.payouts.Status(ctx, providerPayout(p))
+	if err != nil {
+		return err
+	}
+	updated, err := s.applyProviderResult(ctx, p, res)
+	if errors.Is(err, repository.ErrPayoutFinal) {
+		// The reconciler got there first.
+		updated, err = s.repo.GetPayout(ctx, p.ID, p.UserID)
+	}
+	if err != nil {
+		return err
+	}
+	if updated.Status == repository.PayoutProcessing {
+		return conflictf("payout can no longer be cancelled")
+	}
+	return conflictf("payout is already %s", updated.Status)
+}
+
+// ReconcilePayouts polls the provider for every processing payout and records
+// the ones that have resolved. It returns how many payouts changed.
+func (s *Service) ReconcilePayouts(ctx context.Context) (int, error) {
+	ctx, span := tracing.Start(ctx, "Service.ReconcilePayouts")
+	defer span.End()
+
+	pending, err := s.repo.ListProcessingPayouts(ctx, maxLedgerLimit)
+	if err != nil {
+		return 0, err
+	}
+
+	changed := 0
+	for i := range pending {
+		p := &pending[i]
+		res, err := s.payouts.Status(ctx, providerPayout(p))
+		if err != nil {
+			slog.WarnContext(ctx, "payouts: status", "payout_id", p.ID, "err", err)
+			continue
+		}
+		updated, err := s.applyProviderResult(ctx, p, res)
+		if errors.Is(err, repository.ErrPayoutFinal) {
+			continue // resolved concurrently, e.g. cancelled
+		}
+		if err != nil {
+			return changed, err
+		}
+		if updated.Status != repository.PayoutProcessing {
+			changed++
+		}
+	}
+	return changed, nil
+}
+
+// RunPayoutReconciler calls ReconcilePayouts every interval until ctx is done.
+func (s *Service) RunPayoutReconciler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.ReconcilePayouts(ctx); err != nil && ctx.Err() == nil {
+				slog.ErrorContext(ctx, "payouts: reconcile", "err", err)
+			}
+		}
+	}
+}
+
+func (s *Service) applyProviderResult(ctx context.Context, p *repository.Payout, res provider.Result) (*repository.Payout, error) {
+	switch res.Status {
+	case provider.StatusSuccess:
+		return s.setPayoutStatus(ctx, p, repository.PayoutSuccess, optional(res.UTR), nil)
+	case provider.StatusFailed:
+		return s.setPayoutStatus(ctx, p, repository.PayoutFailed, nil, optional(res.ErrorCode))
+	default:
+		return p, nil
+	}
+}
+
+// setPayoutStatus moves a processing payout to its final status and posts
+// the matching journal entry.
+func (s *Service) setPayoutStatus(ctx context.Context, p *repository.Payout, status string, utr, errMsg *string) (*repository.Payout, error) {
+	updated, err := s.repo.UpdatePayoutStatus(ctx, p.ID, status, utr, errMsg, payoutJournal(p, status))
+	if err != nil {
+		return nil, err
+	}
+	metrics.Payouts.WithLabelValues(status).Inc()
+	return updated, nil
+}
+
+func providerPayout(p *repository.Payout) provider.Payout {
+	return provider.Payout{
+		ID:          p.ID,
+		AmountCents: p.AmountCents,
+		Currency:    p.Currency,
+		Method:      p.Method,
+		VPA:         deref(p.DestVPA),
+		Account:     deref(p.DestAccount),
+		IFSC:        deref(p.DestIFSC),
+		Name:        deref(p.DestName),
+		CreatedAt:   p.CreatedAt,
+	}
+}
+
+// payoutOwnerID is the owner of the payouts apiKey may see: its user, or
+// nil for a key without one, which sees only payouts without an owner.
+func (s *Service) payoutOwnerID(ctx context.Context, apiKey string) (*uuid.UUID, error) {
+	owner, err := s.keyOwner(ctx, apiKey)
+	if err != nil || owner == nil {
+		return nil, err
+	}
+	return &owner.ID, nil
+}
+
+// GetPayout returns one of the caller's payouts; anyone else's is not found.
+func (s *Service) GetPayout(ctx context.Context, apiKey, id string) (*repository.Payout, error) {
+	ctx, span := tracing.Start(ctx, "Service.GetPayout")
+	defer span.End()
+
+	if !strings.HasPrefix(id, "po_") {
+		return nil, invalidf("invalid payout id")
+	}
+	ownerID, err := s.payoutOwnerID(ctx, apiKey)
+	if err != nil {
+		return nil, err
+	}
+	p, err := s.repo.GetPayout(ctx, id, ownerID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, notFoundf("payout not found")
+	}
+	return p, err
+}
+
+// PayoutLedger is one page of payouts, newest first. NextBefore is the cursor
+// for the following page, or empty on the last page.
+type PayoutLedger struct {
+	Data       []repository.Payout `json:"data"`
+	NextBefore string              `json:"next_before,omitempty"`
+}
+
+// ListPayouts returns a page of the caller's payouts.
+func (s *Service) ListPayouts(ctx context.Context, apiKey, status, beforeStr string, limit int) (*PayoutLedger, error) {
+	ctx, span := tracing.Start(ctx, "Service.ListPayouts")
+	defer span.End()
+
+	switch status {
+	case "", repository.PayoutProcessing, repository.PayoutSuccess, repository.PayoutFailed:
+	default:
+		return nil, invalidf("status must be processing, success or failed")
+	}
+	if limit <= 0 {
+		limit = defaultLedgerLimit
+	}
+	if limit > maxLedgerLimit {
+		limit = maxLedgerLimit
+	}
+
+	var before *time.Time
+	if beforeStr != "" {
+		t, err := time.Parse(time.RFC3339Nano, beforeStr)
+		if err != nil {
+			return nil, invalidf("invalid before, expected RFC 3339 timestamp")
+		}
+		before = &t
+	}
+
+	ownerID, err := s.payoutOwnerID(ctx, apiKey)
+	if err != nil {
+		return nil, err
+	}
+	payouts, err := s.repo.ListPayouts(ctx, ownerID, status, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	ledger := &PayoutLedger{Data: payouts}
+	if ledger.Data == nil {
+		ledger.Data = []repository.Payout{}
+	}
+	if len(payouts) == limit {
+		ledger.NextBefore = payouts[len(payouts)-1].CreatedAt.Format(time.RFC3339Nano)
+	}
+	return ledger, nil
+}
+
+// PayoutWebhookReplay is the re-sent event and how many endpoints it was
+// queued for.
+type PayoutWebhookReplay struct {
+	Event      repository.PayoutEvent `json:"event"`
+	Deliveries int64                  `json:"deliveries"`
+}
+
+// ReplayPayoutWebhook queues the latest event recorded for the payout again
+// for the owner's subscribed endpoints.
+func (s *Service) ReplayPayoutWebhook(ctx context.Context, apiKey, id string) (*PayoutWebhookReplay, error) {
+	ctx, span := tracing.Start(ctx, "Service.ReplayPayoutWebhook")
+	defer span.End()
+
+	p, err := s.GetPayout(ctx, apiKey, id)
+	if err != nil {
+		return nil, err
+	}
+	events, err := s.repo.ListPayoutEvents(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, notFoundf("payout has no events")
+	}
+
+	replay := &PayoutWebhookReplay{Event: events[len(events)-1]}
+	if p.UserID != nil {
+		n, err := s.repo.EnqueueWebhook(ctx, *p.UserID, replay.Event.Event, replay.Event.Payload)
+		if err != nil {
+			return nil, err
+		}
+		replay.Deliveries = n
+	}
+	return replay, nil
+}
+
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
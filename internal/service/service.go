@@ -2,34 +2,125 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
 
 	"fintech-backend/internal/dto"
+	"fintech-backend/internal/gst"
+	"fintech-backend/internal/metrics"
+	"fintech-backend/internal/provider"
 	"fintech-backend/internal/repository"
+	"fintech-backend/internal/tracing"
+	"fintech-backend/internal/validate"
+	"fintech-backend/internal/webhook"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 )
 
 type Service struct {
-	repo *repository.Repository
+	repo    *repository.Repository
+	coach   CoachEngine
+	payouts provider.Provider
+	hooks   *webhook.Sender
 }
 
-func New(repo *repository.Repository) *Service {
-	return &Service{repo: repo}
+// New builds the service. A nil coach engine selects the built-in rules; a
+// nil sender only reaches public https endpoints and times out after 10s.
+func New(repo *repository.Repository, coach CoachEngine, payouts provider.Provider, hooks *webhook.Sender) *Service {
+	if coach == nil {
+		coach = RulesEngine{}
+	}
+	if hooks == nil {
+		hooks = webhook.NewSender(10*time.Second, false)
+	}
+	return &Service{repo: repo, coach: coach, payouts: payouts, hooks: hooks}
 }
 
 // ========== SHOPS ==========
 
+// DefaultTimezone is used for shops created without one.
+const DefaultTimezone = "Asia/Kolkata"
+
 func (s *Service) CreateShop(ctx context.Context, req dto.CreateShopRequest) (*repository.Shop, error) {
+	ctx, span := tracing.Start(ctx, "Service.CreateShop")
+	defer span.End()
+
+	shop := repository.Shop{
+		Name:      req.Name,
+		Address:   req.Address,
+		GSTNumber: validate.NormalizeGSTIN(req.GSTNumber),
+		Timezone:  req.Timezone,
+	}
+	if shop.Timezone == "" {
+		shop.Timezone = DefaultTimezone
+	}
+	if err := checkShop(&shop); err != nil {
+		return nil, err
+	}
 	user, err := s.repo.GetUserByEmail(ctx, req.OwnerEmail)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, invalidf("owner not found")
+	}
 	if err != nil {
-		return nil, fmt.Errorf("owner not found: %w", err)
+		return nil, err
 	}
-	return s.repo.CreateShop(ctx, user.ID, req.Name, req.Address, req.GSTNumber)
+	return s.repo.CreateShop(ctx, user.ID, shop.Name, shop.Address, shop.GSTNumber, shop.Timezone)
+}
+
+func (s *Service) UpdateShop(ctx context.Context, shopIDStr string, req dto.UpdateShopRequest) (*repository.Shop, error) {
+	ctx, span := tracing.Start(ctx, "Service.UpdateShop")
+	defer span.End()
+
+	shopID, err := uuid.Parse(shopIDStr)
+	if err != nil {
+		return nil, invalidf("invalid shop_id")
+	}
+	shop, err := s.repo.GetShopByID(ctx, shopID)
+	if err != nil {
+		return nil, err
+	}
+	if req.Name != nil {
+		shop.Name = *req.Name
+	}
+	if req.Address != nil {
+		shop.Address = *req.Address
+	}
+	if req.GSTNumber != nil {
+		shop.GSTNumber = validate.NormalizeGSTIN(*req.GSTNumber)
+	}
+	if req.Timezone != nil {
+		shop.Timezone = *req.Timezone
+	}
+	if err := checkShop(shop); err != nil {
+		return nil, err
+	}
+	return s.repo.UpdateShop(ctx, *shop)
+}
+
+func checkShop(shop *repository.Shop) error {
+	var errs validate.Errors
+	if strings.TrimSpace(shop.Name) == "" {
+		errs.Add("name", "is required")
+	}
+	if shop.GSTNumber != "" {
+		errs.Check("gst_number", validate.GSTIN(shop.GSTNumber))
+	}
+	if _, err := time.LoadLocation(shop.Timezone); err != nil {
+		errs.Add("timezone", "is not a known timezone")
+	}
+	return errs.Err()
 }
 
 func (s *Service) ListShops(ctx context.Context, apiKey string) ([]repository.Shop, error) {
-	user, err := s.repo.GetUserByAPIKey(ctx, apiKey)
+	ctx, span := tracing.Start(ctx, "Service.ListShops")
+	defer span.End()
+
+	user, err := s.keyUser(ctx, apiKey)
 	if err != nil {
 		return nil, err
 	}
@@ -39,106 +130,281 @@ func (s *Service) ListShops(ctx context.Context, apiKey string) ([]repository.Sh
 // ========== PRODUCTS ==========
 
 func (s *Service) CreateProduct(ctx context.Context, req dto.CreateProductRequest) (*repository.Product, error) {
+	ctx, span := tracing.Start(ctx, "Service.CreateProduct")
+	defer span.End()
+
 	shopID, err := uuid.Parse(req.ShopID)
 	if err != nil {
-		return nil, fmt.Errorf("invalid shop_id")
-	}
-	if _, err := s.repo.GetShopByID(ctx, shopID); err != nil {
-		return nil, err
-	}
-	var sku *string
-	if req.SKU != "" {
-		sku = &req.SKU
+		return nil, invalidf("invalid shop_id")
 	}
 	p := repository.Product{
 		ShopID:            shopID,
 		Name:              req.Name,
-		SKU:               sku,
+		SKU:               optional(req.SKU),
 		Stock:             req.Stock,
 		CostPrice:         req.CostPrice,
 		SellingPrice:      req.SellingPrice,
 		LowStockThreshold: req.LowStockThreshold,
+		HSNCode:           optional(strings.TrimSpace(req.HSNCode)),
+		GSTRate:           req.GSTRate,
+	}
+	if err := checkProduct(&p); err != nil {
+		return nil, err
+	}
+	if _, err := s.repo.GetShopByID(ctx, shopID); err != nil {
+		return nil, err
 	}
 	return s.repo.CreateProduct(ctx, p)
 }
 
+func (s *Service) UpdateProduct(ctx context.Context, productIDStr string, req dto.UpdateProductRequest) (*repository.Product, error) {
+	ctx, span := tracing.Start(ctx, "Service.UpdateProduct")
+	defer span.End()
+
+	productID, err := uuid.Parse(productIDStr)
+	if err != nil {
+		return nil, invalidf("invalid product_id")
+	}
+	p, err := s.repo.GetProduct(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	if req.Name != nil {
+		p.Name = *req.Name
+	}
+	if req.SKU != nil {
+		p.SKU = optional(*req.SKU)
+	}
+	if req.CostPrice != nil {
+		p.CostPrice = *req.CostPrice
+	}
+	if req.SellingPrice != nil {
+		p.SellingPrice = *req.SellingPrice
+	}
+	if req.LowStockThreshold != nil {
+		p.LowStockThreshold = *req.LowStockThreshold
+	}
+	if req.HSNCode != nil {
+		p.HSNCode = optional(strings.TrimSpace(*req.HSNCode))
+	}
+	if req.GSTRate != nil {
+		p.GSTRate = *req.GSTRate
+	}
+	if err := checkProduct(p); err != nil {
+		return nil, err
+	}
+	return s.repo.UpdateProduct(ctx, *p)
+}
+
+func checkProduct(p *repository.Product) error {
+	var errs validate.Errors
+	if strings.TrimSpace(p.Name) == "" {
+		errs.Add("name", "is required")
+	}
+	if p.HSNCode != nil {
+		errs.Check("hsn_code", validate.HSN(*p.HSNCode))
+	}
+	if !gst.ValidRate(p.GSTRate) {
+		errs.Add("gst_rate", fmt.Sprintf("%g is not a GST rate", p.GSTRate))
+	}
+	return errs.Err()
+}
+
 func (s *Service) ListProducts(ctx context.Context, shopIDStr string) ([]repository.Product, error) {
+	ctx, span := tracing.Start(ctx, "Service.ListProducts")
+	defer span.End()
+
 	shopID, err := uuid.Parse(shopIDStr)
 	if err != nil {
-		return nil, fmt.Errorf("invalid shop_id")
+		return nil, invalidf("invalid shop_id")
 	}
 	return s.repo.ListProductsByShop(ctx, shopID)
 }
 
 // ========== INVOICES ==========
 
+// creditTermDays is the default due period for CREDIT invoices.
+const creditTermDays = 30
+
 func (s *Service) CreateInvoice(ctx context.Context, req dto.CreateInvoiceRequest) (*repository.Invoice, error) {
+	ctx, span := tracing.Start(ctx, "Service.CreateInvoice")
+	defer span.End()
+
 	shopID, err := uuid.Parse(req.ShopID)
 	if err != nil {
-		return nil, fmt.Errorf("invalid shop_id")
+		return nil, invalidf("invalid shop_id")
 	}
 	if len(req.Items) == 0 {
-		return nil, fmt.Errorf("invoice must have at least one item")
+		return nil, invalidf("invoice must have at least one item")
+	}
+	if err := checkInvoiceCustomer(req); err != nil {
+		return nil, err
+	}
+	shop, err := s.repo.GetShopByID(ctx, shopID)
+	if err != nil {
+		return nil, err
 	}
 
-	var total float64
-	items := make([]repository.InvoiceItem, 0, len(req.Items))
+	ids := make([]uuid.UUID, 0, len(req.Items))
 	for _, it := range req.Items {
 		pID, err := uuid.Parse(it.ProductID)
 		if err != nil {
-			return nil, fmt.Errorf("invalid product_id: %s", it.ProductID)
+			return nil, invalidf("invalid product_id: %s", it.ProductID)
+		}
+		if it.Quantity <= 0 {
+			return nil, invalidf("quantity must be positive")
+		}
+		ids = append(ids, pID)
+	}
+	products, err := s.repo.GetProductsByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	var total float64
+	items := make([]repository.InvoiceItem, 0, len(req.Items))
+	for i, it := range req.Items {
+		p, ok := products[ids[i]]
+		if !ok || p.ShopID != shopID {
+			return nil, invalidf("product %s not found in shop", it.ProductID)
 		}
-		lineTotal := it.UnitPrice * float64(it.Quantity)
-		total += lineTotal
+		total += it.UnitPrice * float64(it.Quantity)
 		items = append(items, repository.InvoiceItem{
-			ProductID: pID,
+			ProductID: p.ID,
 			Quantity:  it.Quantity,
 			UnitPrice: it.UnitPrice,
+			HSNCode:   p.HSNCode,
+			GSTRate:   p.GSTRate,
 		})
 	}
+	tax := allocateTax(items, req.TaxAmount)
 
 	inv := repository.Invoice{
 		ShopID:        shopID,
 		CustomerName:  req.CustomerName,
 		CustomerPhone: req.CustomerPhone,
-		TotalAmount:   total + req.TaxAmount,
-		TaxAmount:     req.TaxAmount,
+		TotalAmount:   round2(total + tax),
+		TaxAmount:     tax,
 		Status:        "PAID",
 	}
-	return s.repo.CreateInvoiceWithItems(ctx, inv, items)
+	inv.CustomerGSTIN = optional(validate.NormalizeGSTIN(req.CustomerGSTIN))
+	inv.PlaceOfSupply = placeOfSupply(strings.TrimSpace(req.PlaceOfSupply), inv.CustomerGSTIN, shop.GSTNumber)
+
+	switch req.Status {
+	case "", "PAID":
+	case "CREDIT":
+		inv.Status = "CREDIT"
+		due := time.Now().AddDate(0, 0, creditTermDays)
+		if req.DueDate != "" {
+			due, err = time.Parse("2006-01-02", req.DueDate)
+			if err != nil {
+				return nil, invalidf("invalid due_date, expected YYYY-MM-DD")
+			}
+		}
+		inv.DueDate = &due
+	default:
+		return nil, invalidf("status must be PAID or CREDIT")
+	}
+	created, err := s.repo.CreateInvoiceWithItems(ctx, inv, items, invoiceJournal(&inv))
+	if err != nil {
+		return nil, err
+	}
+	metrics.InvoicesCreated.Inc()
+	metrics.RevenuePaise.Add(math.Round(created.TotalAmount * 100))
+	return created, nil
+}
+
+func checkInvoiceCustomer(req dto.CreateInvoiceRequest) error {
+	var errs validate.Errors
+	if req.TaxAmount < 0 {
+		errs.Add("tax_amount", "must not be negative")
+	}
+	if g := validate.NormalizeGSTIN(req.CustomerGSTIN); g != "" {
+		errs.Check("customer_gstin", validate.GSTIN(g))
+	}
+	if pos := strings.TrimSpace(req.PlaceOfSupply); pos != "" {
+		errs.Check("place_of_supply", validate.State(pos))
+	}
+	return errs.Err()
 }
 
 func (s *Service) ListInvoices(ctx context.Context, shopIDStr string) ([]repository.Invoice, error) {
+	ctx, span := tracing.Start(ctx, "Service.ListInvoices")
+	defer span.End()
+
 	shopID, err := uuid.Parse(shopIDStr)
 	if err != nil {
-		return nil, fmt.Errorf("invalid shop_id")
+		return nil, invalidf("invalid shop_id")
 	}
 	return s.repo.ListInvoicesByShop(ctx, shopID)
 }
 
+// SettleInvoice records payment of a CREDIT invoice in full: it becomes PAID,
+// stops counting as overdue, and its outstanding balance moves from
+// receivables to cash.
+func (s *Service) SettleInvoice(ctx context.Context, idStr string) (*repository.Invoice, error) {
+	ctx, span := tracing.Start(ctx, "Service.SettleInvoice")
+	defer span.End()
+
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return nil, invalidf("invalid invoice_id")
+	}
+	inv, err := s.repo.SettleInvoice(ctx, id, settlementJournal)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, notFoundf("invoice not found")
+	}
+	if errors.Is(err, repository.ErrInvoiceNotCredit) {
+		return nil, conflictf("only CREDIT invoices can be settled")
+	}
+	return inv, err
+}
+
 // ========== EXPENSES ==========
 
 func (s *Service) CreateExpense(ctx context.Context, req dto.CreateExpenseRequest) (*repository.Expense, error) {
+	ctx, span := tracing.Start(ctx, "Service.CreateExpense")
+	defer span.End()
+
 	shopID, err := uuid.Parse(req.ShopID)
 	if err != nil {
-		return nil, fmt.Errorf("invalid shop_id")
+		return nil, invalidf("invalid shop_id")
 	}
 	e := repository.Expense{
-		ShopID:   shopID,
-		Category: req.Category,
-		Amount:   req.Amount,
+		ShopID:        shopID,
+		Category:      req.Category,
+		Amount:        req.Amount,
+		SupplierGSTIN: optional(validate.NormalizeGSTIN(req.SupplierGSTIN)),
+		InputTax:      req.InputTax,
+	}
+	var errs validate.Errors
+	if e.SupplierGSTIN != nil {
+		errs.Check("supplier_gstin", validate.GSTIN(*e.SupplierGSTIN))
+	}
+	if e.InputTax < 0 || e.InputTax > e.Amount {
+		errs.Add("input_tax", "must be between 0 and amount")
+	}
+	if err := errs.Err(); err != nil {
+		return nil, err
 	}
 	if req.Note != "" {
 		note := req.Note
 		e.Note = &note
 	}
-	return s.repo.CreateExpense(ctx, e)
+	if req.Mood != "" {
+		mood := req.Mood
+		e.Mood = &mood
+	}
+	return s.repo.CreateExpense(ctx, e, expenseJournal(&e))
 }
 
 func (s *Service) ListExpenses(ctx context.Context, shopIDStr string) ([]repository.Expense, error) {
+	ctx, span := tracing.Start(ctx, "Service.ListExpenses")
+	defer span.End()
+
 	shopID, err := uuid.Parse(shopIDStr)
 	if err != nil {
-		return nil, fmt.Errorf("invalid shop_id")
+		return nil, invalidf("invalid shop_id")
 	}
 	return s.repo.ListExpensesByShop(ctx, shopID)
 }
@@ -146,9 +412,12 @@ func (s *Service) ListExpenses(ctx context.Context, shopIDStr string) ([]reposit
 // ========== POTS ==========
 
 func (s *Service) CreatePot(ctx context.Context, req dto.CreatePotRequest) (*repository.Pot, error) {
+	ctx, span := tracing.Start(ctx, "Service.CreatePot")
+	defer span.End()
+
 	shopID, err := uuid.Parse(req.ShopID)
 	if err != nil {
-		return nil, fmt.Errorf("invalid shop_id")
+		return nil, invalidf("invalid shop_id")
 	}
 	p := repository.Pot{
 		ShopID:       shopID,
@@ -159,17 +428,30 @@ func (s *Service) CreatePot(ctx context.Context, req dto.CreatePotRequest) (*rep
 }
 
 func (s *Service) DepositPot(ctx context.Context, potIDStr string, amount float64) (*repository.Pot, error) {
+	ctx, span := tracing.Start(ctx, "Service.DepositPot")
+	defer span.End()
+
 	potID, err := uuid.Parse(potIDStr)
 	if err != nil {
-		return nil, fmt.Errorf("invalid pot_id")
+		return nil, invalidf("invalid pot_id")
+	}
+	if amount <= 0 {
+		return nil, invalidf("amount must be positive")
+	}
+	p, err := s.repo.GetPot(ctx, potID)
+	if err != nil {
+		return nil, err
 	}
-	return s.repo.DepositToPot(ctx, potID, amount)
+	return s.repo.DepositToPot(ctx, potID, amount, potDepositJournal(p, amount))
 }
 
 func (s *Service) ListPots(ctx context.Context, shopIDStr string) ([]repository.Pot, error) {
+	ctx, span := tracing.Start(ctx, "Service.ListPots")
+	defer span.End()
+
 	shopID, err := uuid.Parse(shopIDStr)
 	if err != nil {
-		return nil, fmt.Errorf("invalid shop_id")
+		return nil, invalidf("invalid shop_id")
 	}
 	return s.repo.ListPotsByShop(ctx, shopID)
 }
@@ -185,82 +467,143 @@ type DashboardSummary struct {
 }
 
 func (s *Service) GetDashboardSummary(ctx context.Context, shopIDStr string) (*DashboardSummary, error) {
+	ctx, span := tracing.Start(ctx, "Service.GetDashboardSummary")
+	defer span.End()
+
 	shopID, err := uuid.Parse(shopIDStr)
 	if err != nil {
-		return nil, fmt.Errorf("invalid shop_id")
+		return nil, invalidf("invalid shop_id")
 	}
 
-	r7, err := s.repo.SumRevenueLastDays(ctx, shopID, 7)
+	t, err := s.repo.RollingTotals(ctx, shopID)
 	if err != nil {
 		return nil, err
 	}
-	e7, err := s.repo.SumExpensesLastDays(ctx, shopID, 7)
+	return &DashboardSummary{
+		Last7DaysRevenue:   t.Revenue7,
+		Last7DaysExpenses:  t.Expenses7,
+		Last30DaysRevenue:  t.Revenue30,
+		Last30DaysExpenses: t.Expenses30,
+		NetLast30Days:      t.Revenue30 - t.Expenses30,
+	}, nil
+}
+
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+var severityRank = map[string]int{
+	SeverityCritical: 0,
+	SeverityWarning:  1,
+	SeverityInfo:     2,
+}
+
+func validSeverity(s string) bool {
+	_, ok := severityRank[s]
+	return ok
+}
+
+// CoachInsight is a single coach finding. ID identifies it within the shop's
+// stored set and is what dismissals and snoozes refer to; rule codes are
+// stable across releases so the app can key colours and icons on them.
+// Metrics holds the values that triggered the insight.
+type CoachInsight struct {
+	ID       uuid.UUID          `json:"id"`
+	Code     string             `json:"code"`
+	Severity string             `json:"severity"`
+	Message  string             `json:"message"`
+	Metrics  map[string]float64 `json:"metrics,omitempty"`
+	Action   *CoachAction       `json:"action,omitempty"`
+}
+
+// CoachAction is the suggested next step, pointing at the API endpoint the
+// app should open.
+type CoachAction struct {
+	Label    string `json:"label"`
+	Method   string `json:"method"`
+	Endpoint string `json:"endpoint"`
+}
+
+// GetCoachInsights returns the shop's insights ranked by severity, without
+// the ones the user has dismissed or snoozed.
+func (s *Service) GetCoachInsights(ctx context.Context, apiKey, shopIDStr string) ([]CoachInsight, error) {
+	ctx, span := tracing.Start(ctx, "Service.GetCoachInsights")
+	defer span.End()
+
+	shopID, err := uuid.Parse(shopIDStr)
+	if err != nil {
+		return nil, invalidf("invalid shop_id")
+	}
+	user, err := s.keyUser(ctx, apiKey)
 	if err != nil {
 		return nil, err
 	}
-	r30, err := s.repo.SumRevenueLastDays(ctx, shopID, 30)
+	insights, err := s.activeCoachInsights(ctx, shopIDStr)
 	if err != nil {
 		return nil, err
 	}
-	e30, err := s.repo.SumExpensesLastDays(ctx, shopID, 30)
+	states, err := s.repo.ListCoachInsightStates(ctx, user.ID, shopID)
 	if err != nil {
 		return nil, err
 	}
-	return &DashboardSummary{
-		Last7DaysRevenue:   r7,
-		Last7DaysExpenses:  e7,
-		Last30DaysRevenue:  r30,
-		Last30DaysExpenses: e30,
-		NetLast30Days:      r30 - e30,
-	}, nil
+	return filterCoachInsights(insights, states, time.Now()), nil
 }
 
-type CoachInsight struct {
-	Message string `json:"message"`
-}
+// coachInsightsMaxAge is how long a shop's stored insight set is served
+// before the engine is asked for a new one.
+const coachInsightsMaxAge = time.Hour
 
-func (s *Service) GetCoachInsights(ctx context.Context, shopIDStr string) ([]CoachInsight, error) {
-	summary, err := s.GetDashboardSummary(ctx, shopIDStr)
+// activeCoachInsights returns the shop's stored insight set, regenerating it
+// once it is older than coachInsightsMaxAge.
+func (s *Service) activeCoachInsights(ctx context.Context, shopIDStr string) ([]CoachInsight, error) {
+	shopID, err := uuid.Parse(shopIDStr)
 	if err != nil {
-		return nil, err
+		return nil, invalidf("invalid shop_id")
 	}
-
-	insights := []CoachInsight{}
-
-	if summary.Last30DaysRevenue == 0 {
-		insights = append(insights, CoachInsight{
-			Message: "No revenue in the last 30 days. Try recording invoices regularly.",
-		})
+	stored, err := s.repo.ListCoachInsights(ctx, shopID)
+	if err != nil {
+		return nil, err
 	}
-
-	if summary.Last30DaysExpenses > 0 && summary.Last30DaysRevenue > 0 {
-		expenseRatio := summary.Last30DaysExpenses / summary.Last30DaysRevenue
-		if expenseRatio > 0.7 {
-			insights = append(insights, CoachInsight{
-				Message: "Expenses are more than 70% of revenue in the last 30 days. Time to cut some costs.",
-			})
-		} else if expenseRatio < 0.3 {
-			insights = append(insights, CoachInsight{
-				Message: "Expenses are under 30% of revenue. Good profitability, consider reinvesting into growth.",
-			})
+	if coachInsightsStale(stored, time.Now()) {
+		if stored, err = s.generateCoachInsights(ctx, shopID); err != nil {
+			return nil, err
 		}
 	}
 
-	if summary.NetLast30Days > 0 {
-		insights = append(insights, CoachInsight{
-			Message: "You are net positive this month. Allocate part of your profits into a savings pot.",
-		})
-	} else if summary.NetLast30Days < 0 {
-		insights = append(insights, CoachInsight{
-			Message: "You are net negative this month. Review high-cost categories and low-margin products.",
-		})
+	insights := make([]CoachInsight, 0, len(stored))
+	for _, row := range stored {
+		insights = append(insights, coachInsightFromRow(row))
 	}
+	return insights, nil
+}
 
-	if len(insights) == 0 {
-		insights = append(insights, CoachInsight{
-			Message: "Data is limited. Add more invoices and expenses to unlock better insights.",
-		})
+func (s *Service) generateCoachInsights(ctx context.Context, shopID uuid.UUID) ([]repository.CoachInsight, error) {
+	facts, err := s.coachFacts(ctx, shopID.String())
+	if err != nil {
+		return nil, err
+	}
+	insights, err := s.coach.Insights(ctx, *facts)
+	if err != nil {
+		return nil, err
+	}
+	for i := range insights {
+		if a := insights[i].Action; a != nil {
+			a.Endpoint = strings.ReplaceAll(a.Endpoint, "{shop_id}", shopID.String())
+		}
 	}
+	sort.SliceStable(insights, func(i, j int) bool {
+		return severityRank[insights[i].Severity] < severityRank[insights[j].Severity]
+	})
 
-	return insights, nil
+	rows := make([]repository.CoachInsight, 0, len(insights))
+	for _, in := range insights {
+		row, err := coachInsightRow(in)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+	return s.repo.ReplaceCoachInsights(ctx, shopID, rows)
 }
@@ -0,0 +1,246 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"fintech-backend/internal/dto"
+	"fintech-backend/internal/metrics"
+	"fintech-backend/internal/repository"
+	"fintech-backend/internal/tracing"
+	"fintech-backend/internal/webhook"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ========== WEBHOOKS ==========
+
+const (
+	webhookMaxAttempts   = 8
+	webhookBatchSize     = 50
+	defaultDeliveryLimit = 50
+	maxDeliveryLimit     = 200
+)
+
+// webhookEvents are the subscription patterns an endpoint may use.
+var webhookEvents = map[string]bool{
+	"*":                 true,
+	"payout.*":          true,
+	"payout.processing": true,
+	"payout.success":    true,
+	"payout.failed":     true,
+	"invoice.*":         true,
+	"invoice.created":   true,
+	"stock.*":           true,
+	"stock.low":         true,
+}
+
+// CreateWebhookEndpoint registers an endpoint for the caller. Each endpoint
+// has its own signing secret, generated unless the request brings one; it is
+// returned only here and never listed again.
+func (s *Service) CreateWebhookEndpoint(ctx context.Context, apiKey string, req dto.CreateWebhookRequest) (*repository.WebhookEndpoint, error) {
+	ctx, span := tracing.Start(ctx, "Service.CreateWebhookEndpoint")
+	defer span.End()
+
+	user, err := s.keyUser(ctx, apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := s.hooks.CheckURL(req.URL)
+	if err != nil {
+		return nil, invalidf("%s", err)
+	}
+	if len(req.Events) == 0 {
+		return nil, invalidf("events must not be empty")
+	}
+	for _, e := range req.Events {
+		if !webhookEvents[e] {
+			return nil, invalidf("unknown event %q", e)
+		}
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		secret = repository.NewID("whsec_")
+	}
+
+	return s.repo.CreateWebhookEndpoint(ctx, repository.WebhookEndpoint{
+		ID:     repository.NewID("we_"),
+		UserID: user.ID,
+		URL:    u.String(),
+		Events: req.Events,
+		Secret: secret,
+	})
+}
+
+func (s *Service) ListWebhookEndpoints(ctx context.Context, apiKey string) ([]repository.WebhookEndpoint, error) {
+	ctx, span := tracing.Start(ctx, "Service.ListWebhookEndpoints")
+	defer span.End()
+
+	user, err := s.keyUser(ctx, apiKey)
+	if err != nil {
+		return nil, err
+	}
+	endpoints, err := s.repo.ListWebhookEndpoints(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if endpoints == nil {
+		endpoints = []repository.WebhookEndpoint{}
+	}
+	return endpoints, nil
+}
+
+func (s *Service) DeleteWebhookEndpoint(ctx context.Context, apiKey, id string) error {
+	ctx, span := tracing.Start(ctx, "Service.DeleteWebhookEndpoint")
+	defer span.End()
+
+	user, err := s.keyUser(ctx, apiKey)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.DeleteWebhookEndpoint(ctx, user.ID, id); errors.Is(err, pgx.ErrNoRows) {
+		return notFoundf("webhook endpoint not found")
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// ListWebhookDeliveries is the caller's delivery log, newest first.
+func (s *Service) ListWebhookDeliveries(ctx context.Context, apiKey, endpointID, status string, limit int) ([]repository.WebhookDelivery, error) {
+	ctx, span := tracing.Start(ctx, "Service.ListWebhookDeliveries")
+	defer span.End()
+
+	switch status {
+	case "", repository.DeliveryPending, repository.DeliveryDelivered, repository.DeliveryFailed:
+	default:
+		return nil, invalidf("status must be pending, delivered or failed")
+	}
+	if limit <= 0 {
+		limit = defaultDeliveryLimit
+	}
+	if limit > maxDeliveryLimit {
+		limit = maxDeliveryLimit
+	}
+
+	user, err := s.keyUser(ctx, apiKey)
+	if err != nil {
+		return nil, err
+	}
+	deliveries, err := s.repo.ListWebhookDeliveries(ctx, user.ID, endpointID, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	if deliveries == nil {
+		deliveries = []repository.WebhookDelivery{}
+	}
+	return deliveries, nil
+}
+
+// ReplayWebhookDelivery queues the same payload again as a new delivery.
+func (s *Service) ReplayWebhookDelivery(ctx context.Context, apiKey, id string) (*repository.WebhookDelivery, error) {
+	ctx, span := tracing.Start(ctx, "Service.ReplayWebhookDelivery")
+	defer span.End()
+
+	user, err := s.keyUser(ctx, apiKey)
+	if err != nil {
+		return nil, err
+	}
+	d, err := s.repo.ReplayWebhookDelivery(ctx, user.ID, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, notFoundf("webhook delivery not found")
+	}
+	return d, err
+}
+
+// DispatchWebhooks sends every due delivery once and records the outcome.
+// Failures are retried with exponential backoff until webhookMaxAttempts,
+// after which the delivery is marked failed. It returns how many were sent.
+func (s *Service) DispatchWebhooks(ctx context.Context) (int, error) {
+	ctx, span := tracing.Start(ctx, "Service.DispatchWebhooks")
+	defer span.End()
+
+	// The lease covers the send timeout so a crashed instance's claims
+	// become due again instead of being lost.
+	lease := s.hooks.Client.Timeout + 30*time.Second
+	due, err := s.repo.ClaimDueDeliveries(ctx, webhookBatchSize, lease)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, d := range due {
+		code, err := s.hooks.Send(ctx, webhook.Request{
+			URL:        d.URL,
+			Secret:     d.Secret,
+			DeliveryID: d.ID,
+			Event:      d.Event,
+			Body:       d.Payload,
+		})
+		if err == nil {
+			if err := s.repo.MarkDeliveryDelivered(ctx, d.ID, code); err != nil {
+				return sent, err
+			}
+			sent++
+			continue
+		}
+
+		var status *int
+		if code != 0 {
+			status = &code
+		}
+		var retryAt *time.Time
+		if attempt := d.Attempts + 1; attempt < webhookMaxAttempts {
+			t := time.Now().Add(webhook.Backoff(attempt))
+			retryAt = &t
+		}
+		if err := s.repo.MarkDeliveryAttemptFailed(ctx, d.ID, status, err.Error(), retryAt); err != nil {
+			return sent, err
+		}
+		metrics.WebhookFailures.WithLabelValues(strconv.FormatBool(retryAt == nil)).Inc()
+	}
+	return sent, nil
+}
+
+// RunWebhookDispatcher calls DispatchWebhooks every interval until ctx is done.
+func (s *Service) RunWebhookDispatcher(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.DispatchWebhooks(ctx); err != nil && ctx.Err() == nil {
+				slog.ErrorContext(ctx, "webhooks: dispatch", "err", err)
+			}
+		}
+	}
+}
+
+// keyUser resolves the user behind an API key; a key that belongs to no user
+// may not act on per-user resources.
+func (s *Service) keyUser(ctx context.Context, apiKey string) (*repository.User, error) {
+	user, err := s.repo.GetUserByAPIKey(ctx, apiKey)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, forbiddenf("API key is not linked to a user")
+	}
+	return user, err
+}
+
+// keyOwner resolves the user behind an API key, or nil when the key belongs
+// to no user (e.g. the shared server key). Events for objects without an
+// owner are recorded but not delivered.
+func (s *Service) keyOwner(ctx context.Context, apiKey string) (*repository.User, error) {
+	user, err := s.repo.GetUserByAPIKey(ctx, apiKey)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return user, err
+}
@@ -0,0 +1,95 @@
+package validate
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"fintech-backend/internal/gst"
+)
+
+// gstinChars is the base-36 alphabet of the GSTIN check digit.
+const gstinChars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+var (
+	gstinPattern = regexp.MustCompile(`^[0-9]{2}[A-Z]{5}[0-9]{4}[A-Z][1-9A-Z]Z[0-9A-Z]$`)
+	panPattern   = regexp.MustCompile(`^[A-Z]{3}[ABCFGHJLPT][A-Z][0-9]{4}[A-Z]$`)
+	hsnPattern   = regexp.MustCompile(`^([0-9]{4}|[0-9]{6}|[0-9]{8})$`)
+)
+
+// NormalizeGSTIN upper-cases s and strips surrounding spaces.
+func NormalizeGSTIN(s string) string {
+	return strings.ToUpper(strings.TrimSpace(s))
+}
+
+// GSTIN checks a normalized GSTIN: two-digit state code, the holder's PAN,
+// entity number, the fixed 'Z' and the mod-36 check digit.
+func GSTIN(s string) error {
+	if len(s) != 15 {
+		return errors.New("must be 15 characters")
+	}
+	if !gstinPattern.MatchString(s) {
+		return errors.New("is not a valid GSTIN")
+	}
+	if _, ok := gst.States[s[:2]]; !ok {
+		return errors.New("has an unknown state code " + s[:2])
+	}
+	if err := PAN(s[2:12]); err != nil {
+		return errors.New("contains an invalid PAN")
+	}
+	if checkDigit(s[:14]) != s[14] {
+		return errors.New("has an invalid check digit")
+	}
+	return nil
+}
+
+// checkDigit computes the GSTIN check character over the first 14
+// characters: alternate weights 1 and 2, with each product folded to base
+// 36 (quotient plus remainder).
+func checkDigit(s string) byte {
+	sum := 0
+	for i := 0; i < len(s); i++ {
+		v := strings.IndexByte(gstinChars, s[i]) * (i%2 + 1)
+		sum += v/36 + v%36
+	}
+	return gstinChars[(36-sum%36)%36]
+}
+
+// StateCode returns the place-of-supply state code of a valid GSTIN.
+func StateCode(gstin string) string {
+	return gstin[:2]
+}
+
+// PANOf returns the PAN embedded in a valid GSTIN.
+func PANOf(gstin string) string {
+	return gstin[2:12]
+}
+
+// PAN checks a PAN: five letters, the fourth being the holder type, four
+// digits and a letter.
+func PAN(s string) error {
+	if !panPattern.MatchString(s) {
+		return errors.New("is not a valid PAN")
+	}
+	return nil
+}
+
+// State checks a two-digit GST state code.
+func State(code string) error {
+	if _, ok := gst.States[code]; !ok {
+		return errors.New("is not a GST state code")
+	}
+	return nil
+}
+
+// HSN checks an HSN code (4, 6 or 8 digits) or a SAC for services (6
+// digits starting with 99).
+func HSN(code string) error {
+	if !hsnPattern.MatchString(code) {
+		return errors.New("must be a 4, 6 or 8 digit HSN code or a 6 digit SAC")
+	}
+	if strings.HasPrefix(code, "99") && len(code) != 6 {
+		return errors.New("SAC codes must be 6 digits")
+	}
+	return nil
+}
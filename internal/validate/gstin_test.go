@@ -0,0 +1,40 @@
+package validate
+
+import "testing"
+
+func TestGSTIN(t *testing.T) {
+	cases := []struct {
+		in string
+		ok bool
+	}{
+		{"27AAPFU0939F1ZV", true},
+		{"29AAGCB7383J1Z4", true},
+		{"27AAPFU0939F1ZW", false}, // wrong check digit
+		{"99AAPFU0939F1ZV", false}, // unknown state
+		{"27AAPXU0939F1ZV", false}, // bad PAN holder type
+		{"27AAPFU0939F1Z", false},
+		{"27aapfu0939f1zv", false}, // not normalized
+	}
+	for _, c := range cases {
+		if err := GSTIN(c.in); (err == nil) != c.ok {
+			t.Errorf("GSTIN(%q) = %v, want ok=%v", c.in, err, c.ok)
+		}
+	}
+	if got := StateCode("27AAPFU0939F1ZV"); got != "27" {
+		t.Errorf("StateCode = %q", got)
+	}
+	if got := PANOf("27AAPFU0939F1ZV"); got != "AAPFU0939F" {
+		t.Errorf("PANOf = %q", got)
+	}
+}
+
+func TestHSN(t *testing.T) {
+	for code, ok := range map[string]bool{
+		"1001": true, "100190": true, "10019010": true, "998314": true,
+		"10": false, "10019": false, "9983": false, "ABCD": false,
+	} {
+		if err := HSN(code); (err == nil) != ok {
+			t.Errorf("HSN(%q) = %v, want ok=%v", code, err, ok)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package validate
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"fintech-backend/internal/gst"
+
+	"github.com/google/uuid"
+)
+
+// Struct checks v, a pointer to a request struct, against the rules in its
+// `validate` tags and returns every failure as Errors. Fields are named by
+// their JSON keys, with nested structs and slice elements written as
+// "upi.vpa" and "items[0].quantity".
+//
+// Rules, comma separated:
+//
+//	required     non-blank string or non-empty slice
+//	min=N max=N  numeric bounds, or length bounds for strings and slices
+//	gt=N         number strictly greater than N
+//	oneof=A B    string is one of the listed values
+//	uuid email url date timezone gstin hsn state gstrate
+//
+// Empty strings skip every rule but required, so optional fields only have
+// to be valid when set. A nil pointer is an absent field and skips every
+// rule; on a pointer, required means "not blank when present".
+func Struct(v any) error {
+	var errs Errors
+	walk(&errs, "", reflect.ValueOf(v))
+	return errs.Err()
+}
+
+func walk(errs *Errors, prefix string, v reflect.Value) {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := prefix + jsonName(f)
+		fv := v.Field(i)
+		if tag := f.Tag.Get("validate"); tag != "" {
+			if msg := checkField(fv, tag); msg != "" {
+				errs.Add(name, msg)
+				continue
+			}
+		}
+		descend(errs, name, fv)
+	}
+}
+
+// descend validates structs nested in fv, directly or as slice elements.
+func descend(errs *Errors, name string, fv reflect.Value) {
+	switch elem := indirectType(fv.Type()); {
+	case elem.Kind() == reflect.Struct && elem != reflect.TypeOf(time.Time{}):
+		walk(errs, name+".", fv)
+	case fv.Kind() == reflect.Slice && indirectType(elem.Elem()).Kind() == reflect.Struct:
+		for j := 0; j < fv.Len(); j++ {
+			walk(errs, fmt.Sprintf("%s[%d].", name, j), fv.Index(j))
+		}
+	}
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+func jsonName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return f.Name
+	}
+	return name
+}
+
+// checkField returns the first rule fv breaks, or "".
+func checkField(fv reflect.Value, tag string) string {
+	if fv.Kind() == reflect.Pointer {
+		if fv.IsNil() {
+			return ""
+		}
+		fv = fv.Elem()
+	}
+	rules := strings.Split(tag, ",")
+	if fv.Kind() == reflect.String && strings.TrimSpace(fv.String()) == "" {
+		for _, r := range rules {
+			if r == "required" {
+				return "is required"
+			}
+		}
+		return ""
+	}
+	for _, r := range rules {
+		name, param, _ := strings.Cut(r, "=")
+		if msg := checkRule(fv, name, param); msg != "" {
+			return msg
+		}
+	}
+	return ""
+}
+
+func checkRule(fv reflect.Value, rule, param string) string {
+	switch rule {
+	case "required":
+		if fv.Kind() == reflect.Slice && fv.Len() == 0 {
+			return "is required"
+		}
+	case "min", "max", "gt":
+		return checkBound(fv, rule, param)
+	case "oneof":
+		for _, opt := range strings.Fields(param) {
+			if fv.String() == opt {
+				return ""
+			}
+		}
+		return "must be one of: " + strings.Join(strings.Fields(param), ", ")
+	case "uuid":
+		if _, err := uuid.Parse(fv.String()); err != nil {
+			return "must be a UUID"
+		}
+	case "email":
+		if _, err := mail.ParseAddress(fv.String()); err != nil {
+			return "must be an email address"
+		}
+	case "url":
+		if u, err := url.Parse(fv.String()); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "must be an absolute http(s) URL"
+		}
+	case "date":
+		if _, err := time.Parse("2006-01-02", fv.String()); err != nil {
+			return "must be a date in YYYY-MM-DD form"
+		}
+	case "timezone":
+		if _, err := time.LoadLocation(fv.String()); err != nil {
+			return "must be an IANA timezone, e.g. Asia/Kolkata"
+		}
+	case "gstin":
+		return message(GSTIN(NormalizeGSTIN(fv.String())))
+	case "hsn":
+		return message(HSN(strings.TrimSpace(fv.String())))
+	case "state":
+		return message(State(strings.TrimSpace(fv.String())))
+	case "gstrate":
+		if !gst.ValidRate(fv.Float()) {
+			return fmt.Sprintf("must be a GST rate (%s)", rateList())
+		}
+	default:
+		panic("validate: unknown rule " + rule)
+	}
+	return ""
+}
+
+func checkBound(fv reflect.Value, rule, param string) string {
+	n, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		panic("validate: bad " + rule + " parameter " + param)
+	}
+
+	var got float64
+	unit := ""
+	switch fv.Kind() {
+	case reflect.String:
+		got, unit = float64(len([]rune(fv.String()))), " characters"
+	case reflect.Slice:
+		got, unit = float64(fv.Len()), " items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		got = float64(fv.Int())
+	case reflect.Float32, reflect.Float64:
+		got = fv.Float()
+	default:
+		panic("validate: " + rule + " on " + fv.Kind().String())
+	}
+
+	switch {
+	case rule == "min" && got < n:
+		if unit != "" {
+			return fmt.Sprintf("must have at least %s%s", param, unit)
+		}
+		return "must be at least " + param
+	case rule == "max" && got > n:
+		if unit != "" {
+			return fmt.Sprintf("must have at most %s%s", param, unit)
+		}
+		return "must be at most " + param
+	case rule == "gt" && got <= n:
+		return "must be greater than " + param
+	}
+	return ""
+}
+
+func message(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func rateList() string {
+	rates := make([]string, len(gst.Rates))
+	for i, r := range gst.Rates {
+		rates[i] = strconv.FormatFloat(r, 'f', -1, 64)
+	}
+	return strings.Join(rates, ", ")
+}
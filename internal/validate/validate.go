@@ -0,0 +1,43 @@
+// Package validate checks Indian tax identifiers (GSTIN, PAN, HSN/SAC) and
+// collects per-field errors so a request can report every bad field at once.
+package validate
+
+import "strings"
+
+// FieldError is a problem with one request field, named by its JSON key.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Errors is a list of field errors. It implements error so services can
+// return it as is; use Err to get nil when nothing was added.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	parts := make([]string, len(e))
+	for i, fe := range e {
+		parts[i] = fe.Field + ": " + fe.Message
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Add records msg against field.
+func (e *Errors) Add(field, msg string) {
+	*e = append(*e, FieldError{Field: field, Message: msg})
+}
+
+// Check records err's message against field when err is non-nil.
+func (e *Errors) Check(field string, err error) {
+	if err != nil {
+		e.Add(field, err.Error())
+	}
+}
+
+// Err returns e as an error, or nil when it is empty.
+func (e Errors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
@@ -0,0 +1,243 @@
+// Package webhook signs and sends outbound webhook requests.
+//
+// Every request carries the headers below. The signature is
+// "v1=" + hex(HMAC-SHA256(secret, timestamp + "." + body)), so receivers can
+// reject both tampered bodies and replays older than their tolerance.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	HeaderSignature = "Vantro-Signature"
+	HeaderTimestamp = "Vantro-Timestamp"
+	HeaderEvent     = "Vantro-Event"
+	HeaderDelivery  = "Vantro-Delivery"
+
+	signatureVersion = "v1"
+)
+
+var (
+	ErrBadSignature = errors.New("webhook: signature mismatch")
+	ErrStale        = errors.New("webhook: timestamp outside tolerance")
+	// ErrForbiddenAddress is returned when an endpoint resolves to an
+	// address inside our own network.
+	ErrForbiddenAddress = errors.New("webhook: destination address not allowed")
+)
+
+// Sign returns the signature header value for body sent at timestamp
+// (Unix seconds).
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signatureVersion + "=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a received signature and timestamp header. It is what a
+// receiver would run; tolerance <= 0 skips the age check.
+func Verify(secret, signature, timestamp string, body []byte, tolerance time.Duration) error {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrStale
+	}
+	if tolerance > 0 {
+		age := time.Since(time.Unix(ts, 0))
+		if age > tolerance || age < -tolerance {
+			return ErrStale
+		}
+	}
+	if !hmac.Equal([]byte(Sign(secret, ts, body)), []byte(signature)) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// Backoff is the wait before retry number attempt (1-based): 30s doubling
+// each time, capped at 12h.
+func Backoff(attempt int) time.Duration {
+	const (
+		base = 30 * time.Second
+		max  = 12 * time.Hour
+	)
+	if attempt < 1 {
+		attempt = 1
+	}
+	if attempt > 20 {
+		return max
+	}
+	d := base << (attempt - 1)
+	if d > max {
+		return max
+	}
+	return d
+}
+
+// Request is one signed POST to an endpoint.
+type Request struct {
+	URL        string
+	Secret     string
+	DeliveryID string
+	Event      string
+	Body       []byte
+}
+
+// Sender posts signed requests. The zero value is not usable; use NewSender.
+type Sender struct {
+	Client *http.Client
+	// Insecure allows http URLs and loopback, private and link-local
+	// destinations, for receivers on a developer's machine. It must be off
+	// wherever the server can reach internal services.
+	Insecure bool
+}
+
+// NewSender returns a sender whose requests time out after timeout. Unless
+// insecure is set, its connections may only reach public addresses; the
+// check runs on the dialed address, so it also covers redirects and DNS
+// that changes after the endpoint was registered.
+func NewSender(timeout time.Duration, insecure bool) *Sender {
+	dialer := &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}
+	if !insecure {
+		dialer.Control = checkDial
+	}
+	transport := &http.Transport{
+		// No proxy: the dialer would then check the proxy, not the endpoint.
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+	return &Sender{
+		Client: &http.Client{
+			Timeout:   timeout,
+			Transport: transport,
+			// A redirect is reported as the endpoint's non-2xx answer.
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		Insecure: insecure,
+	}
+}
+
+// CheckURL parses raw as an endpoint URL: absolute https (or http when
+// insecure), without credentials, and not naming a host that is internal
+// on its face. Names resolving to internal addresses are refused when
+// dialed.
+func (s *Sender) CheckURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	switch {
+	case err != nil || u.Host == "" || u.Hostname() == "":
+		return nil, errors.New("url must be an absolute URL")
+	case u.Scheme != "https" && !(s.Insecure && u.Scheme == "http"):
+		if s.Insecure {
+			return nil, errors.New("url must be an http(s) URL")
+		}
+		return nil, errors.New("url must be an https URL")
+	case u.User != nil:
+		return nil, errors.New("url must not contain credentials")
+	}
+	if s.Insecure {
+		return u, nil
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") || strings.HasSuffix(host, ".internal") {
+		return nil, errors.New("url must name a public host")
+	}
+	if ip, err := netip.ParseAddr(host); err == nil && forbiddenAddr(ip) {
+		return nil, errors.New("url must name a public host")
+	}
+	return u, nil
+}
+
+// Send posts the request and returns the response status. Any non-2xx
+// status is returned as an error alongside the code.
+func (s *Sender) Send(ctx context.Context, r Request) (int, error) {
+	if r.Secret == "" {
+		return 0, errors.New("endpoint has no signing secret")
+	}
+
+	ts := time.Now().Unix()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.URL, bytes.NewReader(r.Body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Vantro-Webhooks/1")
+	req.Header.Set(HeaderSignature, Sign(r.Secret, ts, r.Body))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(ts, 10))
+	req.Header.Set(HeaderEvent, r.Event)
+	req.Header.Set(HeaderDelivery, r.DeliveryID)
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+		return resp.StatusCode, fmt.Errorf("endpoint returned %d: %s", resp.StatusCode, strings.TrimSpace(string(snippet)))
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	return resp.StatusCode, nil
+}
+
+// nonPublic are ranges outside netip's Is* helpers that still must not be
+// reached: "this network", carrier-grade NAT, IETF protocol assignments,
+// benchmarking and NAT64 (which can embed any IPv4 address).
+var nonPublic = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// forbiddenAddr reports whether ip is loopback, private, link-local
+// (including 169.254.169.254, the cloud metadata service) or otherwise not
+// a public unicast address.
+func forbiddenAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return true
+	}
+	for _, p := range nonPublic {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkDial is the dialer's Control hook. address is the resolved IP and
+// port about to be connected to.
+func checkDial(_, address string, _ syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, address)
+	}
+	if forbiddenAddr(ap.Addr()) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, ap.Addr())
+	}
+	return nil
+}
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS coach_plans;
+DROP TABLE IF EXISTS pots;
+DROP TABLE IF EXISTS expenses;
+DROP TABLE IF EXISTS invoice_items;
+DROP TABLE IF EXISTS invoices;
+DROP TABLE IF EXISTS products;
+DROP TABLE IF EXISTS shops;
+DROP TABLE IF EXISTS users;
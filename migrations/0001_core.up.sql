@@ -1,4 +1,12 @@
-﻿CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+-- Core shop schema. This history replaces the old top-level migrations.sql
+-- and migrations/001-003: 002's products (price_cents, no shop) and 003's
+-- per-user users/expenses/saving_pots/coach_plans redefined tables the API
+-- already owns with incompatible columns, so only these definitions, the
+-- ones the code reads, are kept. saving_pots became pots; expenses belong to
+-- a shop. Every statement is idempotent, so databases set up from the old
+-- files adopt this history without changes.
+
+CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
 
 CREATE TABLE IF NOT EXISTS users (
     id          UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
@@ -66,4 +74,17 @@ CREATE TABLE IF NOT EXISTS pots (
     target_amount  NUMERIC(12,2) NOT NULL,
     current_amount NUMERIC(12,2) NOT NULL DEFAULT 0,
     created_at     TIMESTAMPTZ NOT NULL DEFAULT now()
-);
\ No newline at end of file
+);
+
+ALTER TABLE expenses ADD COLUMN IF NOT EXISTS mood TEXT;
+
+CREATE TABLE IF NOT EXISTS coach_plans (
+    id            UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id       UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    week_start    DATE NOT NULL,
+    rules         JSONB NOT NULL,
+    daily_nudge   TEXT,
+    health_score  INT NOT NULL,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (user_id, week_start)
+);
@@ -25,3 +25,8 @@ CREATE TABLE IF NOT EXISTS payout_events (
 
 CREATE INDEX IF NOT EXISTS idx_payouts_ref ON payouts(reference_id);
 CREATE INDEX IF NOT EXISTS idx_payouts_status ON payouts(status);
+CREATE INDEX IF NOT EXISTS idx_payouts_created ON payouts(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_payout_events_payout ON payout_events(payout_id, created_at);
+
+-- owner of the payout, resolved from the API key that created it
+ALTER TABLE payouts ADD COLUMN IF NOT EXISTS user_id UUID;
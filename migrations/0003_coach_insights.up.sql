@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS coach_insight_states (
+    user_id        UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    shop_id        UUID NOT NULL REFERENCES shops(id) ON DELETE CASCADE,
+    code           TEXT NOT NULL,
+    status         TEXT NOT NULL,
+    snoozed_until  TIMESTAMPTZ,
+    metrics        JSONB NOT NULL DEFAULT '{}'::jsonb,
+    updated_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, shop_id, code)
+);
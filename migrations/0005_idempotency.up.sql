@@ -0,0 +1,15 @@
+-- Idempotency-Key replays, scoped by a hash of the caller's API key.
+-- A NULL status_code marks a request that is still being handled.
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    scope          TEXT NOT NULL,
+    key            TEXT NOT NULL,
+    request_hash   TEXT NOT NULL,
+    status_code    INT,
+    content_type   TEXT,
+    response_body  BYTEA,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at     TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (scope, key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires ON idempotency_keys(expires_at);
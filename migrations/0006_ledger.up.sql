@@ -0,0 +1,35 @@
+-- Double-entry ledger. A book is the shop for shop operations, or the payout
+-- owner's user id (nil UUID when unowned) for payouts. Entries are posted in
+-- the same transaction as the operation they record and must balance.
+CREATE TABLE IF NOT EXISTS ledger_accounts (
+    id          UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    book_id     UUID NOT NULL,
+    code        TEXT NOT NULL,
+    name        TEXT NOT NULL,
+    type        TEXT NOT NULL CHECK (type IN ('asset', 'liability', 'equity', 'income', 'expense')),
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (book_id, code)
+);
+
+CREATE TABLE IF NOT EXISTS journal_entries (
+    id          UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    book_id     UUID NOT NULL,
+    memo        TEXT NOT NULL,
+    source      TEXT NOT NULL,
+    source_id   TEXT NOT NULL,
+    posted_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS journal_lines (
+    id          BIGSERIAL PRIMARY KEY,
+    entry_id    UUID NOT NULL REFERENCES journal_entries(id) ON DELETE CASCADE,
+    account_id  UUID NOT NULL REFERENCES ledger_accounts(id),
+    debit       NUMERIC(14,2) NOT NULL DEFAULT 0,
+    credit      NUMERIC(14,2) NOT NULL DEFAULT 0,
+    CHECK (debit >= 0 AND credit >= 0 AND (debit = 0) <> (credit = 0))
+);
+
+CREATE INDEX IF NOT EXISTS idx_journal_entries_book_posted ON journal_entries(book_id, posted_at);
+CREATE INDEX IF NOT EXISTS idx_journal_entries_source ON journal_entries(source, source_id);
+CREATE INDEX IF NOT EXISTS idx_journal_lines_entry ON journal_lines(entry_id);
+CREATE INDEX IF NOT EXISTS idx_journal_lines_account ON journal_lines(account_id);
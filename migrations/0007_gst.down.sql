@@ -0,0 +1,14 @@
+DROP TABLE IF EXISTS credit_notes;
+
+ALTER TABLE expenses DROP COLUMN IF EXISTS input_tax;
+ALTER TABLE expenses DROP COLUMN IF EXISTS supplier_gstin;
+
+ALTER TABLE invoices DROP COLUMN IF EXISTS place_of_supply;
+ALTER TABLE invoices DROP COLUMN IF EXISTS customer_gstin;
+
+ALTER TABLE invoice_items DROP COLUMN IF EXISTS tax_amount;
+ALTER TABLE invoice_items DROP COLUMN IF EXISTS gst_rate;
+ALTER TABLE invoice_items DROP COLUMN IF EXISTS hsn_code;
+
+ALTER TABLE products DROP COLUMN IF EXISTS gst_rate;
+ALTER TABLE products DROP COLUMN IF EXISTS hsn_code;
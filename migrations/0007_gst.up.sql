@@ -0,0 +1,43 @@
+-- GST: HSN/SAC and rate on products, snapshotted onto invoice lines at sale
+-- time together with each line's share of the invoice tax.
+ALTER TABLE products ADD COLUMN IF NOT EXISTS hsn_code TEXT;
+ALTER TABLE products ADD COLUMN IF NOT EXISTS gst_rate NUMERIC(5,2) NOT NULL DEFAULT 0;
+
+ALTER TABLE invoice_items ADD COLUMN IF NOT EXISTS hsn_code TEXT;
+ALTER TABLE invoice_items ADD COLUMN IF NOT EXISTS gst_rate NUMERIC(5,2) NOT NULL DEFAULT 0;
+ALTER TABLE invoice_items ADD COLUMN IF NOT EXISTS tax_amount NUMERIC(12,2) NOT NULL DEFAULT 0;
+
+-- place_of_supply is a two-digit GST state code
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS customer_gstin TEXT;
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS place_of_supply TEXT;
+
+-- input_tax is the GST included in amount, claimable when supplier_gstin is set
+ALTER TABLE expenses ADD COLUMN IF NOT EXISTS supplier_gstin TEXT;
+ALTER TABLE expenses ADD COLUMN IF NOT EXISTS input_tax NUMERIC(12,2) NOT NULL DEFAULT 0;
+
+-- Spread the tax of invoices created before line-level tax existed across
+-- their lines by value, with the implied rate.
+UPDATE invoice_items ii
+SET tax_amount = ROUND(i.tax_amount * (ii.quantity * ii.unit_price) / t.taxable, 2),
+    gst_rate   = ROUND(i.tax_amount * 100 / t.taxable, 2)
+FROM invoices i,
+     (SELECT invoice_id, SUM(quantity * unit_price) AS taxable FROM invoice_items GROUP BY invoice_id) t
+WHERE ii.invoice_id = i.id
+  AND t.invoice_id = i.id
+  AND t.taxable > 0
+  AND i.tax_amount > 0
+  AND NOT EXISTS (SELECT 1 FROM invoice_items x WHERE x.invoice_id = i.id AND x.tax_amount <> 0);
+
+CREATE TABLE IF NOT EXISTS credit_notes (
+    id             UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    shop_id        UUID NOT NULL REFERENCES shops(id) ON DELETE CASCADE,
+    invoice_id     UUID NOT NULL REFERENCES invoices(id) ON DELETE CASCADE,
+    taxable_value  NUMERIC(12,2) NOT NULL CHECK (taxable_value > 0),
+    gst_rate       NUMERIC(5,2) NOT NULL DEFAULT 0,
+    tax_amount     NUMERIC(12,2) NOT NULL DEFAULT 0,
+    reason         TEXT,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_credit_notes_shop_created ON credit_notes(shop_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_credit_notes_invoice ON credit_notes(invoice_id);
@@ -0,0 +1,11 @@
+-- Token buckets shared by every API instance when RATE_LIMIT_STORE=postgres.
+-- Rows are disposable: a missing row is a full bucket, and full_at says when
+-- a row becomes one.
+CREATE UNLOGGED TABLE IF NOT EXISTS rate_limit_buckets (
+    key         TEXT PRIMARY KEY,
+    tokens      DOUBLE PRECISION NOT NULL,
+    updated_at  TIMESTAMPTZ NOT NULL,
+    full_at     TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_rate_limit_buckets_full ON rate_limit_buckets(full_at);
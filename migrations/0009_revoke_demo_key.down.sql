@@ -0,0 +1 @@
+-- The published key is deliberately not restored.
@@ -0,0 +1,7 @@
+-- 0001 used to seed admin@example.com with the API key "supersecretapikey",
+-- which the demo page published. Give that user a random key so the
+-- published one stops working; the user and its shops are kept. Demo data
+-- now comes from `vantro seed`, which only runs with ENV=dev.
+UPDATE users
+SET api_key = 'sk_revoked_' || md5(random()::text || clock_timestamp()::text)
+WHERE api_key = 'supersecretapikey';
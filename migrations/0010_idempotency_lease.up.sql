@@ -0,0 +1,6 @@
+-- An in-progress key (NULL status_code) is only held until locked_until, so
+-- a key abandoned by a crash or a lost release can be taken over by a retry
+-- instead of blocking it until expires_at.
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS locked_until TIMESTAMPTZ;
+
+UPDATE idempotency_keys SET locked_until = created_at WHERE status_code IS NULL;
@@ -0,0 +1,13 @@
+-- Endpoints registered without a secret were signed with the server-wide
+-- WEBHOOK_SECRET, which merchants never received and every tenant shared.
+-- That fallback is gone: such endpoints are switched off, their pending
+-- deliveries failed, and merchants re-register to get a secret of their own.
+UPDATE webhook_deliveries d
+SET status = 'failed', last_error = 'endpoint has no signing secret; re-register it', updated_at = now()
+FROM webhook_endpoints e
+WHERE e.id = d.endpoint_id AND d.status = 'pending' AND COALESCE(e.secret, '') = '';
+
+UPDATE webhook_endpoints SET active = FALSE WHERE COALESCE(secret, '') = '';
+
+ALTER TABLE webhook_endpoints
+    ADD CONSTRAINT webhook_endpoints_secret_required CHECK (NOT active OR COALESCE(secret, '') <> '');
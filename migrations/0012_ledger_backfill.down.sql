@@ -0,0 +1,2 @@
+-- Backfilled entries are real history and are kept; they cannot be told
+-- apart from entries posted since by the same operations.
@@ -0,0 +1,154 @@
+-- Post the journals of everything recorded before the ledger existed (0006),
+-- so trial balances, P&L and balance sheets cover a shop's whole history.
+-- Each row gets the entry the service would have posted, dated when the row
+-- was created; rows that already have an entry are skipped, so operations
+-- made since 0006 are not booked twice. Pot deposits were never recorded
+-- one by one, so each pot gets a single opening entry for the part of its
+-- balance the ledger does not already hold.
+--
+-- Lines are staged with a signed amount (debit > 0, credit < 0), the shape
+-- postJournal nets them to.
+CREATE TEMP TABLE ledger_backfill (
+    entry_id   UUID NOT NULL,
+    book_id    UUID NOT NULL,
+    memo       TEXT NOT NULL,
+    source     TEXT NOT NULL,
+    source_id  TEXT NOT NULL,
+    posted_at  TIMESTAMPTZ NOT NULL,
+    code       TEXT NOT NULL,
+    name       TEXT NOT NULL,
+    type       TEXT NOT NULL,
+    amount     NUMERIC(14,2) NOT NULL
+) ON COMMIT DROP;
+
+-- invoice: Dr cash (PAID) or receivables (CREDIT), Cr revenue, tax_payable
+WITH src AS MATERIALIZED (
+    SELECT uuid_generate_v4() AS entry_id, i.*
+    FROM invoices i
+    WHERE NOT EXISTS (SELECT 1 FROM journal_entries j WHERE j.source = 'invoice' AND j.source_id = i.id::text)
+)
+INSERT INTO ledger_backfill
+SELECT s.entry_id, s.shop_id, 'Invoice to ' || COALESCE(s.customer_name, ''), 'invoice', s.id::text, s.created_at,
+       l.code, l.name, l.type, l.amount
+FROM src s
+CROSS JOIN LATERAL (VALUES
+    (CASE WHEN s.status = 'CREDIT' THEN 'receivables' ELSE 'cash' END,
+     CASE WHEN s.status = 'CREDIT' THEN 'Accounts receivable' ELSE 'Cash' END,
+     'asset', s.total_amount),
+    ('revenue', 'Sales revenue', 'income', -(s.total_amount - s.tax_amount)),
+    ('tax_payable', 'GST payable', 'liability', -s.tax_amount)
+) AS l(code, name, type, amount);
+
+-- expense: Dr expense:<category>, gst_input (with a supplier GSTIN), Cr cash
+WITH src AS MATERIALIZED (
+    SELECT uuid_generate_v4() AS entry_id, e.*,
+           CASE WHEN e.supplier_gstin IS NOT NULL THEN e.input_tax ELSE 0 END AS itc,
+           COALESCE(NULLIF(btrim(e.category), ''), 'Uncategorized') AS account_name
+    FROM expenses e
+    WHERE NOT EXISTS (SELECT 1 FROM journal_entries j WHERE j.source = 'expense' AND j.source_id = e.id::text)
+)
+INSERT INTO ledger_backfill
+SELECT s.entry_id, s.shop_id, 'Expense: ' || s.category, 'expense', s.id::text, s.spent_at,
+       l.code, l.name, l.type, l.amount
+FROM src s
+CROSS JOIN LATERAL (VALUES
+    ('expense:' || lower(regexp_replace(s.account_name, '\s+', '_', 'g')), s.account_name, 'expense', s.amount - s.itc),
+    ('gst_input', 'GST input credit', 'asset', s.itc),
+    ('cash', 'Cash', 'asset', -s.amount)
+) AS l(code, name, type, amount);
+
+-- credit note: Dr revenue, tax_payable, Cr cash (PAID) or receivables (CREDIT)
+WITH src AS MATERIALIZED (
+    SELECT uuid_generate_v4() AS entry_id, cn.*, i.status AS invoice_status, i.customer_name
+    FROM credit_notes cn
+    JOIN invoices i ON i.id = cn.invoice_id
+    WHERE NOT EXISTS (SELECT 1 FROM journal_entries j WHERE j.source = 'credit_note' AND j.source_id = cn.id::text)
+)
+INSERT INTO ledger_backfill
+SELECT s.entry_id, s.shop_id, 'Credit note to ' || COALESCE(s.customer_name, ''), 'credit_note', s.id::text, s.created_at,
+       l.code, l.name, l.type, l.amount
+FROM src s
+CROSS JOIN LATERAL (VALUES
+    ('revenue', 'Sales revenue', 'income', s.taxable_value),
+    ('tax_payable', 'GST payable', 'liability', s.tax_amount),
+    (CASE WHEN s.invoice_status = 'CREDIT' THEN 'receivables' ELSE 'cash' END,
+     CASE WHEN s.invoice_status = 'CREDIT' THEN 'Accounts receivable' ELSE 'Cash' END,
+     'asset', -(s.taxable_value + s.tax_amount))
+) AS l(code, name, type, amount);
+
+-- pot opening balance: Dr pot:<id>, Cr cash, for current_amount less what
+-- deposits since 0006 already put in the pot's account
+WITH src AS MATERIALIZED (
+    SELECT uuid_generate_v4() AS entry_id, p.*,
+           p.current_amount - COALESCE((
+               SELECT SUM(jl.debit - jl.credit)
+               FROM ledger_accounts a
+               JOIN journal_lines jl ON jl.account_id = a.id
+               WHERE a.book_id = p.shop_id AND a.code = 'pot:' || p.id::text
+           ), 0) AS opening
+    FROM pots p
+    WHERE NOT EXISTS (SELECT 1 FROM journal_entries j WHERE j.source = 'pot_opening' AND j.source_id = p.id::text)
+)
+INSERT INTO ledger_backfill
+SELECT s.entry_id, s.shop_id, 'Opening balance of pot ' || s.name, 'pot_opening', s.id::text, s.created_at,
+       l.code, l.name, l.type, l.amount
+FROM src s
+CROSS JOIN LATERAL (VALUES
+    ('pot:' || s.id::text, 'Pot: ' || s.name, 'asset', s.opening),
+    ('cash', 'Cash', 'asset', -s.opening)
+) AS l(code, name, type, amount);
+
+-- payout: initiated (Dr payouts_clearing, Cr bank), then settled
+-- (Dr expense:payouts) or reversed (Dr bank) against payouts_clearing.
+-- Unowned payouts are booked to the nil UUID, as payoutBook does.
+WITH src AS MATERIALIZED (
+    SELECT p.*, p.amount_cents / 100.0 AS amount,
+           COALESCE(p.user_id, '00000000-0000-0000-0000-000000000000') AS book_id
+    FROM payouts p
+    WHERE NOT EXISTS (SELECT 1 FROM journal_entries j WHERE j.source = 'payout' AND j.source_id = p.id)
+),
+entries AS MATERIALIZED (
+    SELECT uuid_generate_v4() AS entry_id, s.*, 'Payout initiated' AS memo, s.created_at AS posted_at,
+           'payouts_clearing' AS debit_code, 'Payouts clearing' AS debit_name,
+           'bank' AS credit_code, 'Bank' AS credit_name
+    FROM src s
+    UNION ALL
+    SELECT uuid_generate_v4(), s.*,
+           CASE s.status WHEN 'success' THEN 'Payout settled' ELSE 'Payout reversed' END, s.updated_at,
+           CASE s.status WHEN 'success' THEN 'expense:payouts' ELSE 'bank' END,
+           CASE s.status WHEN 'success' THEN 'Payouts disbursed' ELSE 'Bank' END,
+           'payouts_clearing', 'Payouts clearing'
+    FROM src s
+    WHERE s.status IN ('success', 'failed')
+)
+INSERT INTO ledger_backfill
+SELECT e.entry_id, e.book_id, e.memo, 'payout', e.id, e.posted_at, l.code, l.name, l.type, l.amount
+FROM entries e
+CROSS JOIN LATERAL (VALUES
+    (e.debit_code, e.debit_name, CASE WHEN e.debit_code = 'expense:payouts' THEN 'expense' ELSE 'asset' END, e.amount),
+    (e.credit_code, e.credit_name, 'asset', -e.amount)
+) AS l(code, name, type, amount);
+
+DELETE FROM ledger_backfill WHERE amount = 0;
+
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM ledger_backfill GROUP BY entry_id HAVING SUM(amount) <> 0) THEN
+        RAISE EXCEPTION 'ledger backfill: unbalanced entry';
+    END IF;
+END $$;
+
+INSERT INTO ledger_accounts (book_id, code, name, type)
+SELECT DISTINCT ON (book_id, code) book_id, code, name, type
+FROM ledger_backfill
+ORDER BY book_id, code
+ON CONFLICT (book_id, code) DO NOTHING;
+
+INSERT INTO journal_entries (id, book_id, memo, source, source_id, posted_at)
+SELECT DISTINCT entry_id, book_id, memo, source, source_id, posted_at
+FROM ledger_backfill;
+
+INSERT INTO journal_lines (entry_id, account_id, debit, credit)
+SELECT b.entry_id, a.id, GREATEST(b.amount, 0), GREATEST(-b.amount, 0)
+FROM ledger_backfill b
+JOIN ledger_accounts a ON a.book_id = b.book_id AND a.code = b.code;
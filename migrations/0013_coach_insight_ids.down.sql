@@ -0,0 +1,14 @@
+ALTER TABLE coach_insight_states ADD COLUMN code TEXT;
+
+UPDATE coach_insight_states st
+SET code = ci.code
+FROM coach_insights ci
+WHERE ci.id = st.insight_id;
+
+ALTER TABLE coach_insight_states
+    DROP CONSTRAINT coach_insight_states_pkey,
+    DROP COLUMN insight_id,
+    ALTER COLUMN code SET NOT NULL,
+    ADD PRIMARY KEY (user_id, shop_id, code);
+
+DROP TABLE IF EXISTS coach_insights;
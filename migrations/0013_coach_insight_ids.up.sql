@@ -0,0 +1,38 @@
+-- Insights are stored per shop with an ID, and dismiss/snooze state hangs off
+-- that ID instead of the insight code: model-written codes change between
+-- generations, so a code alone cannot say which insight a user dismissed.
+-- Regenerating a shop's set keeps the row (and ID) of every code that comes
+-- back and deletes the rest, taking their state with them.
+CREATE TABLE IF NOT EXISTS coach_insights (
+    id            UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    shop_id       UUID NOT NULL REFERENCES shops(id) ON DELETE CASCADE,
+    code          TEXT NOT NULL,
+    position      INT NOT NULL DEFAULT 0,
+    severity      TEXT NOT NULL DEFAULT 'info',
+    message       TEXT NOT NULL DEFAULT '',
+    metrics       JSONB,
+    action        JSONB,
+    generated_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (shop_id, code)
+);
+
+-- Existing state keeps working: each dismissed or snoozed code gets an
+-- already-expired row, so the first request regenerates the set and a code
+-- that is still active keeps this row's ID.
+INSERT INTO coach_insights (shop_id, code, generated_at)
+SELECT DISTINCT shop_id, code, '-infinity'::timestamptz
+FROM coach_insight_states
+ON CONFLICT (shop_id, code) DO NOTHING;
+
+ALTER TABLE coach_insight_states ADD COLUMN insight_id UUID REFERENCES coach_insights(id) ON DELETE CASCADE;
+
+UPDATE coach_insight_states st
+SET insight_id = ci.id
+FROM coach_insights ci
+WHERE ci.shop_id = st.shop_id AND ci.code = st.code;
+
+ALTER TABLE coach_insight_states
+    DROP CONSTRAINT coach_insight_states_pkey,
+    DROP COLUMN code,
+    ALTER COLUMN insight_id SET NOT NULL,
+    ADD PRIMARY KEY (user_id, insight_id);
@@ -0,0 +1,3 @@
+-- Local timezone of a shop, used for report dates, GST periods and coach
+-- weeks. Databases that got it with 0003 before it was split out keep theirs.
+ALTER TABLE shops ADD COLUMN IF NOT EXISTS timezone TEXT NOT NULL DEFAULT 'Asia/Kolkata';
@@ -0,0 +1,3 @@
+-- When a CREDIT invoice is due; overdue credit is counted from it. Databases
+-- that got it with 0003 before it was split out keep theirs.
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS due_date DATE;
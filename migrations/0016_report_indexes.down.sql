@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_expenses_shop_spent;
+DROP INDEX IF EXISTS idx_invoices_shop_created;
+DROP INDEX IF EXISTS idx_invoice_items_product;
@@ -0,0 +1,6 @@
+-- Indexes for the per-shop date-range reads of the dashboard, coach and GST
+-- returns, and for finding a product's sales. Databases that got them with
+-- 0003 before they were split out keep theirs.
+CREATE INDEX IF NOT EXISTS idx_invoice_items_product ON invoice_items(product_id);
+CREATE INDEX IF NOT EXISTS idx_invoices_shop_created ON invoices(shop_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_expenses_shop_spent ON expenses(shop_id, spent_at);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_payouts_user_created;
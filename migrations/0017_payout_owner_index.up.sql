@@ -0,0 +1,2 @@
+-- Payout reads are scoped to the caller's user, newest first.
+CREATE INDEX IF NOT EXISTS idx_payouts_user_created ON payouts(user_id, created_at DESC);
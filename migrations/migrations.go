@@ -0,0 +1,9 @@
+// Package migrations embeds the ordered schema history. Each version is a
+// pair of files, NNNN_name.up.sql and NNNN_name.down.sql, applied by
+// internal/migrate.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS